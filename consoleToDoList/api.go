@@ -0,0 +1,514 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiMaxBody - максимальный размер тела запроса к API, байт
+const apiMaxBody = 1 << 20
+
+// api - HTTP JSON API над хранилищем задач (режим todo serve). Задачи передаются в том же виде,
+// в котором их выгружает export (taskRecord), даты без часового пояса относятся к поясу loc.
+type api struct {
+	store TaskStore
+	loc   *time.Location
+}
+
+// taskList - ответ на GET /tasks: страница задач и курсор следующей страницы (пустой, если страница последняя)
+type taskList struct {
+	Tasks []taskRecord `json:"tasks"`
+	Next  string       `json:"next,omitempty"`
+}
+
+// taskPatch - тело PATCH /tasks/{id}: изменяются только указанные поля
+type taskPatch struct {
+	Content  *string   `json:"content"`
+	Date     *string   `json:"date"`     // "yyyy.mm.dd" или "yyyy.mm.dd hh:mm"
+	Due      *string   `json:"due"`      // момент срока в RFC 3339, вместо date
+	Priority *string   `json:"priority"` // название уровня или 0-3
+	Repeat   *string   `json:"repeat"`   // правило повторения, "" или "none" - не повторять
+	Project  *string   `json:"project"`  // проект, "" или "none" - без проекта
+	Tags     *[]string `json:"tags"`     // новый набор меток целиком
+	Done     *bool     `json:"done"`     // true - выполнить (как complete), false - снова открыть
+	Parent   *int64    `json:"parent"`   // id родительской задачи, 0 - сделать задачей верхнего уровня
+	List     *string   `json:"list"`     // список, в который переносится задача с подзадачами
+}
+
+// apiError - тело ответа с ошибкой
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// newAPI возвращает обработчик HTTP API:
+// GET /tasks (параметры q, limit, after, open, by_priority, tag, not_tag, project, parent, list), POST /tasks,
+// GET, PATCH и DELETE /tasks/{id} (параметр children - что делать с подзадачами: delete или reparent)
+func newAPI(store TaskStore, loc *time.Location) http.Handler {
+
+	a := &api{store: store, loc: loc}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /tasks", a.list)
+	mux.HandleFunc("POST /tasks", a.create)
+	mux.HandleFunc("GET /tasks/{id}", a.get)
+	mux.HandleFunc("PATCH /tasks/{id}", a.update)
+	mux.HandleFunc("DELETE /tasks/{id}", a.remove)
+
+	return mux
+}
+
+// now возвращает текущий момент в часовом поясе пользователя
+func (a *api) now() time.Time {
+
+	return time.Now().In(a.loc)
+}
+
+// list выводит страницу задач, с параметром q - найденных полнотекстовым поиском
+func (a *api) list(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+
+	opts, err := listQuery(query)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	fetch := a.store.List
+	if q := strings.TrimSpace(query.Get("q")); q != "" {
+		fetch = func(opts ListOptions) ([]Task, error) {
+			return a.store.Search(q, opts)
+		}
+	}
+
+	p := newPager(fetch, opts)
+	allTasks, more, _, err := p.seek(0)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	page := taskList{Tasks: make([]taskRecord, len(allTasks))}
+	for i, task := range allTasks {
+		page.Tasks[i] = recordOf(task)
+	}
+	if more {
+		page.Next = formatCursor(*p.next())
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+// get выводит задачу по id
+func (a *api) get(w http.ResponseWriter, r *http.Request) {
+
+	id, err := pathID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	task, err := a.store.Get(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, recordOf(task))
+}
+
+// create добавляет задачу из тела запроса (проверяется так же, как запись import) и возвращает её с id
+func (a *api) create(w http.ResponseWriter, r *http.Request) {
+
+	var record taskRecord
+	err := decodeJSON(w, r, &record)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	task, err := record.toTask(a.now())
+	if err == nil && !task.done {
+		// в отличие от import, новая задача в API, как и при вводе, не может быть просроченной
+		err = deadlineOf(task).checkFuture(a.now())
+		if err != nil {
+			err = fmt.Errorf("date %q: %w", task.date, err)
+		}
+	}
+	if err != nil {
+		writeError(w, invalidInput(err))
+		return
+	}
+	task.parent = record.Parent
+
+	id, err := a.store.Create(task)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	task, err = a.store.Get(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/tasks/%d", id))
+	writeJSON(w, http.StatusCreated, recordOf(task))
+}
+
+// update изменяет поля задачи, указанные в теле запроса, и возвращает изменённую задачу.
+// Если выполнена повторяющаяся задача, id следующего повторения передаётся в заголовке X-Next-Task-Id.
+func (a *api) update(w http.ResponseWriter, r *http.Request) {
+
+	id, err := pathID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var patch taskPatch
+	err = decodeJSON(w, r, &patch)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	task, err := a.store.Get(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// все поля, включая список и родительскую задачу, проверяются до того, как что-либо изменится
+	err = patch.apply(&task, a.now())
+	if err == nil && patch.List != nil {
+		var list TaskList
+		list, err = lookupList(a.store, task.list)
+		task.list = list.name
+	}
+	if err == nil && patch.Parent != nil {
+		err = checkParent(a.store, task, *patch.Parent)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// правка применяется одной транзакцией: при ошибке задача остаётся прежней
+	edit := TaskEdit{Fields: &task, Parent: patch.Parent}
+	if patch.Tags != nil {
+		edit.Tags = &task.tags
+	}
+	if patch.List != nil {
+		edit.List = &task.list
+	}
+	if patch.Done != nil && *patch.Done != task.done {
+		edit.Done = patch.Done
+		edit.At = a.now()
+		if *patch.Done {
+			edit.Next, err = nextOccurrence(task, edit.At)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+	}
+
+	nextID, err := a.store.Edit(id, edit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if nextID != 0 {
+		w.Header().Set("X-Next-Task-Id", strconv.FormatInt(nextID, 10))
+	}
+
+	task, err = a.store.Get(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, recordOf(task))
+}
+
+// remove перемещает задачу в корзину, у задачи с подзадачами параметр children обязателен
+func (a *api) remove(w http.ResponseWriter, r *http.Request) {
+
+	id, err := pathID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	task, err := a.store.Get(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	children, err := parseChildPolicy(r.URL.Query().Get("children"), task)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	err = a.store.Delete(id, a.now(), children)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apply проверяет указанные поля и записывает их в задачу (метки - в task.tags, выполнение не трогается)
+func (p taskPatch) apply(task *Task, now time.Time) error {
+
+	var err error
+
+	if p.Content != nil {
+		task.content = strings.TrimSpace(*p.Content)
+		if task.content == "" {
+			return invalidInputf("content: task content is empty")
+		}
+	}
+
+	if p.List != nil {
+		task.list, err = normalizeListName(*p.List)
+		if err != nil {
+			return invalidInputf("list: %w", err)
+		}
+	}
+
+	if p.Date != nil && p.Due != nil {
+		return invalidInputf("date and due are mutually exclusive")
+	}
+	if p.Date != nil {
+		due, err := validateDeadline(*p.Date, now)
+		if err != nil {
+			return fmt.Errorf("date: %w", err)
+		}
+		due.apply(task)
+	}
+	if p.Due != nil {
+		due, err := taskRecord{Due: *p.Due}.deadline(now)
+		if err == nil {
+			err = due.checkFuture(now)
+		}
+		if err != nil {
+			return invalidInputf("due: %w", err)
+		}
+		due.apply(task)
+	}
+
+	if p.Priority != nil {
+		task.priority, err = parsePriority(*p.Priority)
+		if err != nil {
+			return fmt.Errorf("priority: %w", err)
+		}
+	}
+
+	if p.Repeat != nil {
+		task.recur = ""
+		if *p.Repeat != "" && !strings.EqualFold(*p.Repeat, "none") {
+			rule, err := parseRecurrence(*p.Repeat)
+			if err != nil {
+				return fmt.Errorf("repeat: %w", err)
+			}
+			task.recur = rule.String()
+		}
+	}
+
+	if p.Project != nil {
+		task.project = ""
+		if !strings.EqualFold(*p.Project, "none") {
+			task.project, err = normalizeProject(*p.Project)
+			if err != nil {
+				return fmt.Errorf("project: %w", err)
+			}
+		}
+	}
+
+	if p.Tags != nil {
+		task.tags, err = normalizeTags(*p.Tags)
+		if err != nil {
+			return fmt.Errorf("tags: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// checkParent проверяет, что задача task (со списком, в который она переносится) может стать подзадачей parent:
+// parent существует, находится в том же списке и не является самой задачей или её подзадачей. 0 - верхний уровень.
+func checkParent(store TaskStore, task Task, parent int64) error {
+
+	if parent == 0 {
+		return nil
+	}
+	if parent == task.id {
+		return invalidInputf("parent: task %d cannot be a subtask of itself", task.id)
+	}
+
+	parentTask, err := store.Get(parent)
+	if err != nil {
+		return err
+	}
+
+	for ancestor := parentTask; ancestor.parent != 0; {
+		if ancestor.parent == task.id {
+			return invalidInputf("parent: task %d cannot become a subtask of its own subtask %d", task.id, parent)
+		}
+		ancestor, err = store.Get(ancestor.parent)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !strings.EqualFold(parentTask.list, task.list) {
+		return invalidInputf("parent: task %d is in list %q, not in %q", parent, parentTask.list, task.list)
+	}
+
+	return nil
+}
+
+// listQuery разбирает параметры выборки GET /tasks, те же, что у флагов todo list
+func listQuery(query url.Values) (ListOptions, error) {
+
+	opts := ListOptions{Limit: pageSize}
+	var err error
+
+	if limit := query.Get("limit"); limit != "" {
+		opts.Limit, err = strconv.Atoi(limit)
+		if err != nil || opts.Limit < 1 {
+			return opts, invalidInputf("limit: bad number %q", limit)
+		}
+	}
+	if after := query.Get("after"); after != "" {
+		opts.After, err = parseCursor(after)
+		if err != nil {
+			return opts, err
+		}
+	}
+
+	opts.OnlyOpen, err = queryBool(query, "open")
+	if err != nil {
+		return opts, err
+	}
+	opts.ByPriority, err = queryBool(query, "by_priority")
+	if err != nil {
+		return opts, err
+	}
+
+	opts.Tags, err = normalizeTags(query["tag"])
+	if err != nil {
+		return opts, err
+	}
+	opts.ExcludeTags, err = normalizeTags(query["not_tag"])
+	if err != nil {
+		return opts, err
+	}
+
+	if project := query.Get("project"); project != "" {
+		opts.Project, err = normalizeProject(project)
+		if err != nil {
+			return opts, err
+		}
+	}
+
+	if parent := query.Get("parent"); parent != "" {
+		opts.Parent, err = strconv.ParseInt(parent, 10, 64)
+		if err != nil || opts.Parent < 1 {
+			return opts, invalidInputf("parent: bad task id %q", parent)
+		}
+	}
+
+	if list := query.Get("list"); list != "" {
+		opts.List, err = normalizeListName(list)
+		if err != nil {
+			return opts, err
+		}
+	}
+
+	return opts, nil
+}
+
+// queryBool разбирает логический параметр запроса: отсутствующий - false, пустой ("?open") - true
+func queryBool(query url.Values, name string) (bool, error) {
+
+	if !query.Has(name) {
+		return false, nil
+	}
+
+	value := query.Get(name)
+	if value == "" {
+		return true, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, invalidInputf("%s: bad boolean %q", name, value)
+	}
+
+	return b, nil
+}
+
+// pathID извлекает id задачи из пути запроса
+func pathID(r *http.Request) (int64, error) {
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return 0, invalidInputf("bad task id %q", r.PathValue("id"))
+	}
+
+	return id, nil
+}
+
+// decodeJSON читает из тела запроса ровно один объект JSON без неизвестных полей
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, apiMaxBody))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(v)
+	if err != nil {
+		return invalidInputf("bad JSON body: %v", err)
+	}
+
+	if dec.Decode(&struct{}{}) != io.EOF {
+		return invalidInputf("bad JSON body: unexpected data after the object")
+	}
+
+	return nil
+}
+
+// writeJSON отправляет ответ со статусом status и значением v в JSON
+func writeJSON(w http.ResponseWriter, status int, v any) {
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError отправляет ошибку в JSON, статус выбирается по виду ошибки
+func writeError(w http.ResponseWriter, err error) {
+
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, errNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, errInvalidInput):
+		status = http.StatusBadRequest
+	}
+
+	writeJSON(w, status, apiError{Error: err.Error()})
+}
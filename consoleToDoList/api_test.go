@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestAPI запускает HTTP API над store, сервер останавливается в конце теста
+func newTestAPI(t *testing.T, store TaskStore) *httptest.Server {
+
+	t.Helper()
+
+	srv := httptest.NewServer(newAPI(store, time.UTC))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// doJSON выполняет запрос method к path с телом body (пустое - без тела), проверяет статус
+// и разбирает ответ в out (nil - ответ не разбирается)
+func doJSON(t *testing.T, srv *httptest.Server, method, path, body string, status int, out any) {
+
+	t.Helper()
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, srv.URL+path, reader)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("%s %s: reading body: %v", method, path, err)
+	}
+	if resp.StatusCode != status {
+		t.Fatalf("%s %s: status %d, want %d, body %s", method, path, resp.StatusCode, status, data)
+	}
+	if out == nil {
+		return
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("%s %s: Content-Type %q", method, path, ct)
+	}
+	err = json.Unmarshal(data, out)
+	if err != nil {
+		t.Fatalf("%s %s: bad JSON %s: %v", method, path, data, err)
+	}
+}
+
+func TestAPICreateGetUpdateDelete(t *testing.T) {
+
+	store := newMemoryStore()
+	srv := newTestAPI(t, store)
+
+	var created taskRecord
+	doJSON(t, srv, "POST", "/tasks", `{"content":"buy milk","date":"`+tomorrow()+`","tags":["shop"]}`, http.StatusCreated, &created)
+	if created.ID != 1 || created.Content != "buy milk" || created.Date != tomorrow() {
+		t.Fatalf("created %+v", created)
+	}
+
+	var got taskRecord
+	doJSON(t, srv, "GET", "/tasks/1", "", http.StatusOK, &got)
+	if got.Content != "buy milk" || strings.Join(got.Tags, ",") != "shop" {
+		t.Errorf("got %+v", got)
+	}
+
+	var updated taskRecord
+	doJSON(t, srv, "PATCH", "/tasks/1", `{"content":"buy bread","priority":"high","done":true}`, http.StatusOK, &updated)
+	if updated.Content != "buy bread" || updated.Priority != "high" || !updated.Done {
+		t.Errorf("updated %+v", updated)
+	}
+
+	doJSON(t, srv, "DELETE", "/tasks/1", "", http.StatusNoContent, nil)
+	if _, err := store.Get(1); err == nil {
+		t.Error("deleted task is still there")
+	}
+}
+
+func TestAPIList(t *testing.T) {
+
+	store := newMemoryStore()
+	srv := newTestAPI(t, store)
+	for _, content := range []string{"Молоко и хлеб", "call mom", "pay rent"} {
+		doJSON(t, srv, "POST", "/tasks", `{"content":"`+content+`","date":"`+tomorrow()+`"}`, http.StatusCreated, nil)
+	}
+
+	var page taskList
+	doJSON(t, srv, "GET", "/tasks", "", http.StatusOK, &page)
+	if len(page.Tasks) != 3 || page.Next != "" {
+		t.Errorf("list: %d tasks, next %q", len(page.Tasks), page.Next)
+	}
+
+	doJSON(t, srv, "GET", "/tasks?q="+url.QueryEscape("мол"), "", http.StatusOK, &page)
+	if len(page.Tasks) != 1 || page.Tasks[0].Content != "Молоко и хлеб" {
+		t.Errorf("search: %+v", page.Tasks)
+	}
+}
+
+func TestAPIPaging(t *testing.T) {
+
+	store := newMemoryStore()
+	srv := newTestAPI(t, store)
+	for range 3 {
+		doJSON(t, srv, "POST", "/tasks", `{"content":"task","date":"`+tomorrow()+`"}`, http.StatusCreated, nil)
+	}
+
+	var first, second taskList
+	doJSON(t, srv, "GET", "/tasks?limit=2", "", http.StatusOK, &first)
+	if len(first.Tasks) != 2 || first.Next == "" {
+		t.Fatalf("first page: %d tasks, next %q", len(first.Tasks), first.Next)
+	}
+
+	doJSON(t, srv, "GET", "/tasks?limit=2&after="+url.QueryEscape(first.Next), "", http.StatusOK, &second)
+	if len(second.Tasks) != 1 || second.Next != "" {
+		t.Fatalf("second page: %d tasks, next %q", len(second.Tasks), second.Next)
+	}
+
+	seen := map[int64]bool{}
+	for _, record := range append(first.Tasks, second.Tasks...) {
+		seen[record.ID] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("pages repeat tasks: %v", seen)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+
+	srv := newTestAPI(t, newMemoryStore())
+
+	for _, c := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{"GET", "/tasks/42", "", http.StatusNotFound},
+		{"PATCH", "/tasks/42", `{"content":"x"}`, http.StatusNotFound},
+		{"DELETE", "/tasks/42", "", http.StatusNotFound},
+		{"GET", "/tasks/abc", "", http.StatusBadRequest},
+		{"GET", "/tasks?limit=0", "", http.StatusBadRequest},
+		{"GET", "/tasks?after=bad", "", http.StatusBadRequest},
+		{"POST", "/tasks", `{"content":`, http.StatusBadRequest},
+		{"POST", "/tasks", `{"content":"x","date":"` + tomorrow() + `","color":"red"}`, http.StatusBadRequest},
+		{"POST", "/tasks", `{"content":"late","date":"2000.01.01"}`, http.StatusBadRequest},
+	} {
+		var body apiError
+		doJSON(t, srv, c.method, c.path, c.body, c.status, &body)
+		if body.Error == "" {
+			t.Errorf("%s %s: empty error message", c.method, c.path)
+		}
+	}
+}
+
+func TestAPIUpdateParentAndList(t *testing.T) {
+
+	store := newMemoryStore()
+	srv := newTestAPI(t, store)
+	err := store.CreateList("work")
+	if err != nil {
+		t.Fatalf("CreateList: %v", err)
+	}
+	trip, _ := store.Create(Task{content: "trip", date: tomorrow(), list: inboxList})
+	store.Create(Task{content: "tickets", date: tomorrow(), parent: trip, list: inboxList})
+	deploy, _ := store.Create(Task{content: "deploy", date: tomorrow(), list: "work"})
+
+	// неверная родительская задача не даёт перенести задачу в другой список
+	var body apiError
+	doJSON(t, srv, "PATCH", "/tasks/1", `{"list":"work","parent":999}`, http.StatusNotFound, &body)
+	doJSON(t, srv, "PATCH", "/tasks/1", `{"list":"work","parent":2}`, http.StatusBadRequest, &body)
+	doJSON(t, srv, "PATCH", "/tasks/1", `{"content":"holiday","parent":3}`, http.StatusBadRequest, &body)
+	task, _ := store.Get(trip)
+	if task.list != inboxList || task.parent != 0 || task.content != "trip" {
+		t.Fatalf("rejected patch changed the task: %+v", task)
+	}
+
+	var updated taskRecord
+	doJSON(t, srv, "PATCH", "/tasks/1", `{"list":"WORK","parent":3}`, http.StatusOK, &updated)
+	if updated.List != "work" || updated.Parent != deploy {
+		t.Errorf("updated %+v", updated)
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupStamp - формат метки времени в имени файла резервной копии, копии сортируются по имени в порядке создания
+const backupStamp = "20060102-150405.000"
+
+// snapshotter - хранилище, которое умеет сохранить снимок своих данных в файл (хранилище в памяти не умеет)
+type snapshotter interface {
+	Snapshot(path string) error
+}
+
+// Snapshot сохраняет согласованную копию БД в новый файл path (VACUUM INTO)
+func (s *sqliteStore) Snapshot(path string) (err error) {
+
+	defer storageFailure(&err, "snapshot database")
+
+	_, err = s.db.Exec("VACUUM INTO :path", sql.Named("path", path))
+
+	return err
+}
+
+// backupPattern возвращает шаблон имён резервных копий файла БД dbPath в папке backupDir рядом с ним
+func backupPattern(dbPath string) string {
+
+	base := strings.TrimSuffix(filepath.Base(dbPath), filepath.Ext(dbPath))
+
+	return filepath.Join(filepath.Dir(dbPath), backupDir, base+"-*"+filepath.Ext(dbPath))
+}
+
+// listBackups возвращает пути резервных копий файла БД dbPath от старых к новым. Копия - только файл с именем БД
+// и меткой времени в формате backupStamp, копии другой БД с похожим именем ("tasksDB-work.db") сюда не попадают.
+func listBackups(dbPath string) ([]string, error) {
+
+	dir := filepath.Dir(backupPattern(dbPath))
+	ext := filepath.Ext(dbPath)
+	prefix := strings.TrimSuffix(filepath.Base(dbPath), ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		stamp, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		stamp, ok = strings.CutSuffix(stamp, ext)
+		if !ok {
+			continue
+		}
+		if _, err := time.Parse(backupStamp, stamp); err != nil {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// createBackup сохраняет снимок хранилища в папку резервных копий и удаляет самые старые копии сверх backupCount.
+// Возвращает путь новой копии.
+func createBackup(store TaskStore, dbPath string, now time.Time) (string, error) {
+
+	path, err := saveSnapshot(store, dbPath, now)
+	if err != nil {
+		return "", err
+	}
+
+	return path, rotateBackups(dbPath, backupCount)
+}
+
+// saveSnapshot сохраняет снимок хранилища в папку резервных копий (без удаления старых копий) и возвращает его путь
+func saveSnapshot(store TaskStore, dbPath string, now time.Time) (string, error) {
+
+	s, ok := store.(snapshotter)
+	if !ok {
+		return "", errors.New("this storage does not support backups")
+	}
+
+	pattern := backupPattern(dbPath)
+	err := os.MkdirAll(filepath.Dir(pattern), 0o755)
+	if err != nil {
+		return "", storageError{op: "create backup folder", err: err}
+	}
+
+	path := strings.Replace(pattern, "*", now.Format(backupStamp), 1)
+	err = s.Snapshot(path)
+	if err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// rotateBackups оставляет keep самых новых резервных копий файла БД dbPath
+func rotateBackups(dbPath string, keep int) error {
+
+	paths, err := listBackups(dbPath)
+	if err != nil {
+		return storageError{op: "rotate backups", err: err}
+	}
+
+	for len(paths) > keep {
+		err = os.Remove(paths[0])
+		if err != nil {
+			return storageError{op: "rotate backups", err: err}
+		}
+		paths = paths[1:]
+	}
+
+	return nil
+}
+
+// findBackup возвращает путь резервной копии по имени файла (в папке копий или путь целиком), пустое имя - самая новая копия
+func findBackup(dbPath, name string) (string, error) {
+
+	if name == "" {
+		paths, err := listBackups(dbPath)
+		if err != nil {
+			return "", storageError{op: "list backups", err: err}
+		}
+		if len(paths) == 0 {
+			return "", invalidInputf("there are no backups yet, use backup first")
+		}
+		return paths[len(paths)-1], nil
+	}
+
+	for _, path := range []string{name, filepath.Join(filepath.Dir(backupPattern(dbPath)), name)} {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	return "", invalidInputf("backup %q not found", name)
+}
+
+// checkBackup проверяет, что файл - целая БД SQLite (PRAGMA integrity_check) со схемой, которую понимает программа
+func checkBackup(path string) error {
+
+	// mode=ro: проверка не должна ничего менять в файле копии
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return invalidInputf("backup %s: %v", path, err)
+	}
+	defer db.Close()
+
+	var result string
+	err = db.QueryRow("PRAGMA integrity_check").Scan(&result)
+	if err != nil {
+		return invalidInputf("backup %s is not a valid database: %v", path, err)
+	}
+	if result != "ok" {
+		return invalidInputf("backup %s is damaged: %s", path, result)
+	}
+
+	version, err := schemaVersion(db)
+	if err != nil {
+		return invalidInputf("backup %s: reading schema version: %v", path, err)
+	}
+	if version > len(migrations) {
+		return invalidInputf("backup %s has schema version %d, newer than supported version %d", path, version, len(migrations))
+	}
+
+	return nil
+}
+
+// replaceDatabase заменяет файл БД dbPath копией backupPath. Хранилище над dbPath к этому моменту должно быть закрыто.
+// Копия сначала записывается во временный файл рядом с БД, поэтому сбой посередине не портит текущий файл.
+func replaceDatabase(dbPath, backupPath string) (err error) {
+
+	defer storageFailure(&err, "restore backup")
+
+	src, err := os.Open(backupPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dbPath), filepath.Base(dbPath)+".restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, src)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), dbPath)
+}
+
+// restoreBackup проверяет копию backupPath, сохраняет резервную копию текущей БД (чтобы восстановление тоже можно было
+// отменить), закрывает store, заменяет файл БД копией и открывает хранилище над dbPath заново.
+// Возвращает хранилище, с которым надо продолжать работу, и путь копии текущей БД. При ошибке это store (если он ещё
+// не закрыт) или заново открытая прежняя БД; nil - БД открыть не удалось, тогда ошибка содержит errStoreClosed.
+func restoreBackup(store TaskStore, dbPath, backupPath string, now time.Time) (TaskStore, string, error) {
+
+	err := checkBackup(backupPath)
+	if err != nil {
+		return store, "", err
+	}
+
+	// старые копии удаляются только после восстановления, иначе среди них может оказаться восстанавливаемая
+	saved, err := saveSnapshot(store, dbPath, now)
+	if err != nil {
+		return store, "", fmt.Errorf("saving current database before restore: %w", err)
+	}
+
+	err = store.Close()
+	if err == nil {
+		err = replaceDatabase(dbPath, backupPath)
+	}
+
+	// при сбое файл прежней БД не тронут (replaceDatabase подменяет его только целиком), и открывается он,
+	// иначе - восстановленная БД, миграции при этом обновят схему старой копии
+	reopened, openErr := openSQLiteStore(dbPath, now.Location())
+	if openErr != nil {
+		return nil, "", errors.Join(err, storageError{op: "reopen database", err: fmt.Errorf("%w: %w", errStoreClosed, openErr)})
+	}
+	if err != nil {
+		return reopened, "", err
+	}
+
+	return reopened, saved, rotateBackups(dbPath, backupCount)
+}
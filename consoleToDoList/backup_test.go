@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListBackupsOnlyOwn(t *testing.T) {
+
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "tasksDB.db")
+
+	paths, err := listBackups(dbPath)
+	if err != nil || len(paths) != 0 {
+		t.Fatalf("no backup folder: %q, %v", paths, err)
+	}
+
+	folder := filepath.Join(dir, backupDir)
+	err = os.MkdirAll(folder, 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		"tasksDB-20261018-115524.682.db",
+		"tasksDB-20261017-090000.000.db",
+		"tasksDB-work-20261018-115524.648.db", // копия БД tasksDB-work.db
+		"tasksDB-notes.db",
+		"tasksDB-20261018-115524.682.db.bak",
+	} {
+		err = os.WriteFile(filepath.Join(folder, name), nil, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths, err = listBackups(dbPath)
+	if err != nil {
+		t.Fatalf("listBackups: %v", err)
+	}
+	want := []string{"tasksDB-20261017-090000.000.db", "tasksDB-20261018-115524.682.db"}
+	if len(paths) != len(want) {
+		t.Fatalf("backups %q, want %q", paths, want)
+	}
+	for i, path := range paths {
+		if filepath.Base(path) != want[i] {
+			t.Errorf("backup %d is %s, want %s", i, path, want[i])
+		}
+	}
+
+	err = rotateBackups(dbPath, 1)
+	if err != nil {
+		t.Fatalf("rotateBackups: %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(folder, "tasksDB-work-20261018-115524.648.db")); err != nil {
+		t.Errorf("rotation removed a backup of another database: %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(folder, want[0])); err == nil {
+		t.Error("the oldest backup was kept")
+	}
+}
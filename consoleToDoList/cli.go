@@ -0,0 +1,1358 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// коды завершения программы в неинтерактивном режиме
+const (
+	exitOK       = 0 // команда выполнена
+	exitError    = 1 // внутренняя ошибка (например, при работе с БД)
+	exitUsage    = 2 // неверные аргументы или данные
+	exitNotFound = 3 // задача с указанным id не найдена
+)
+
+// cliUsage - справка по неинтерактивному режиму
+const cliUsage = `Usage:
+  todo                                       start interactive mode
+  todo [--db PATH] [--page-size N] [--date-format F] [--lang L] [--list NAME] [--time-zone Z] [--trash-days N]
+       [--backup-keep N] <command>
+                                             run a command (or the interactive mode) with these settings instead of
+                                             the ones from the config file (~/.config/todo/config or $TODO_CONFIG)
+                                             and TODO_DB, TODO_PAGE_SIZE, TODO_DATE_FORMAT, TODO_LANG, TODO_LIST,
+                                             TODO_TIME_ZONE, TODO_TRASH_DAYS, TODO_BACKUP_KEEP;
+                                             --list NAME selects the task list add, list, search, trash,
+                                             import and tui work with (the inbox by default),
+                                             --date-format dd.mm.yyyy sets the date format for input and output
+  todo add <content> --date "yyyy.mm.dd[ hh:mm]" [--priority P] [--repeat R] [--project P] [--tags a,b]
+           [--remind 15m,1d] [--parent ID]
+                                             add a task, prints its id; time is optional, in the user's time zone;
+                                             the date may be relative: today, tomorrow, next fri, +3d, in 2 weeks,
+                                             завтра, через неделю (the resolved date is printed to stderr)
+  todo list [--limit N] [--page N | --after C] [--open] [--by-priority] [--upcoming DAYS]
+            [--tag T] [--not-tag T] [--project P]
+                                             list a page of top-level tasks sorted by due time (or by priority, then
+                                             due time), each followed by its subtasks as an indented tree
+  todo overdue                               list open tasks whose due time has passed, the oldest first
+  todo reschedule <id>...|all --to DATE | --by N
+                                             move the given overdue tasks of the list (all - every overdue task) to a date,
+                                             keeping their time of day, or shift them by N days; all of them or none are moved
+  todo update <id> [--content C] [--date D] [--priority P] [--repeat R] [--project P] [--parent ID]
+                                             change a task, --repeat none and --project none clear the value,
+                                             --parent none makes a subtask a top-level task
+  todo tag <id> <tag>...                     add tags to a task
+  todo untag <id> <tag>...                   remove tags from a task
+  todo remind <id> [<offset>...]             add reminders (15m, 2h, 1d, 0 - at due) and list reminders of a task
+  todo unremind <id> <offset>...             remove reminders from a task
+  todo remind                                print reminders that are due now, each reminder is sent once
+  todo remind --watch [--interval D] [--command CMD]
+                                             check reminders every D until interrupted, print them or run
+                                             CMD with sh -c (reminder text in $1, task in TODO_TASK_* variables)
+  todo complete <id>                         mark a task as done, prints id of the next occurrence of a repeating task
+  todo reopen <id>                           mark a done task as open again
+  todo lists                                 print task lists with their task counts, the current one marked *
+  todo lists new NAME | rename NAME NEW | delete NAME [--into OTHER]
+                                             add, rename or delete a list; tasks of a deleted list (with the trash)
+                                             are moved to OTHER, the inbox cannot be deleted
+  todo move <id> <list>                      move a task with its subtasks to another list
+  todo history <id>                          print changes of a task, numbered by version
+  todo revert <id> <version>                 bring back the content, due date, priority, status, repeat rule,
+                                             project and tags of a task from a version in its history
+  todo rm <id> [--children delete|reparent]  move a task to the trash; a task with subtasks needs --children:
+                                             delete moves them to the trash too, reparent moves them up a level
+  todo trash [--limit N] [--page N | --after C]
+                                             list a page of tasks in the trash, they are purged after the retention period
+  todo restore <id>                          bring a task back from the trash with the subtasks deleted along with it
+  todo backup [list]                         save a backup of the database and print its path, or list backups
+  todo restore backup [FILE]                 check and restore the newest or the given backup,
+                                             the current database is backed up first
+  todo sync <other.db> [--policy newer|local|other|skip]
+                                             merge tasks with another database file both ways by task uuid; a task
+                                             changed in both since the last sync is a conflict, resolved by the
+                                             policy (skip by default: both versions stay until the next sync);
+                                             tasks purged from the trash are not remembered, so a purged task comes
+                                             back if the other database still has it
+  todo stats [--json]                        print counts of open, overdue and done tasks, open tasks per week for
+                                             the coming month, completion rate over the last 30 days and average
+                                             time from creation to completion
+  todo search <query> [--limit N] [--page N | --after C] [--open] [--by-priority]
+            [--tag T] [--not-tag T] [--project P]
+                                             list a page of tasks containing the query
+  todo export [--format json|csv|ics] [--output FILE]
+                                             write all tasks to stdout or FILE (format defaults to FILE extension or json)
+  todo import <FILE|-> [--format json|csv|ics]
+                                             add tasks from FILE or stdin, invalid records are reported and skipped
+  todo serve [--addr HOST:PORT]              serve the HTTP JSON API until interrupted:
+                                             GET /tasks?q=&limit=&after=&open&by_priority&tag=&not_tag=&project=&parent=&list=,
+                                             POST /tasks, GET, PATCH and DELETE /tasks/{id}[?children=delete|reparent]
+  todo tui                                   open the full-screen mode: task list by due date, details of the
+                                             selected task, keys a add, e edit, x done, d delete, / search, q quit
+  todo config show                           print the effective settings and where each one comes from
+  todo help                                  show this help`
+
+// cli описывает неинтерактивный режим: одна команда из аргументов командной строки
+type cli struct {
+	store    TaskStore      // хранилище задач
+	stdin    io.Reader      // откуда читаются данные команды import -
+	stdout   io.Writer      // куда выводится результат команды
+	stderr   io.Writer      // куда выводятся ошибки
+	loc      *time.Location // часовой пояс пользователя для ввода и вывода сроков
+	listName string         // текущий список задач (todo --list NAME): с ним работают add, list, search, trash и tui
+	cfg      config         // действующие настройки (todo config show)
+}
+
+// runCLI выполняет команду из args с настройками cfg (список задач - из настройки list, пустое имя - входящие)
+// и возвращает код завершения программы, сроки вводятся и выводятся в часовом поясе loc
+func runCLI(store TaskStore, cfg config, args []string, stdin io.Reader, stdout, stderr io.Writer, loc *time.Location) int {
+
+	c := &cli{
+		store:  store,
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+		loc:    loc,
+		cfg:    cfg,
+	}
+
+	current, err := lookupList(store, cfg.value("list"))
+	if err != nil {
+		return c.fail(err)
+	}
+	c.listName = current.name
+
+	var command func([]string) int
+
+	switch args[0] {
+	case "add":
+		command = c.add
+	case "list", "ls":
+		command = c.list
+	case "update":
+		command = c.update
+	case "overdue":
+		command = c.overdue
+	case "reschedule":
+		command = c.reschedule
+	case "complete", "done":
+		command = c.complete
+	case "reopen":
+		command = c.reopen
+	case "tag":
+		command = c.tag
+	case "untag":
+		command = c.untag
+	case "remind":
+		command = c.remind
+	case "unremind":
+		command = c.unremind
+	case "lists":
+		command = c.lists
+	case "move":
+		command = c.move
+	case "history":
+		command = c.history
+	case "revert":
+		command = c.revert
+	case "rm", "delete":
+		command = c.remove
+	case "trash":
+		command = c.trash
+	case "restore":
+		command = c.restore
+	case "backup":
+		command = c.backup
+	case "sync":
+		command = c.sync
+	case "search":
+		command = c.search
+	case "stats":
+		command = c.stats
+	case "export":
+		command = c.export
+	case "import":
+		command = c.importFile
+	case "serve":
+		command = c.serve
+	case "tui":
+		command = c.tui
+	case "config":
+		command = c.config
+	case "help", "-h", "--help":
+		fmt.Fprintln(stdout, cliUsage)
+		return exitOK
+	default:
+		return c.usageError(usageHelp{fmt.Errorf("unknown command %q", args[0])})
+	}
+
+	return command(args[1:])
+}
+
+// add добавляет задачу: todo add <content> --date "yyyy.mm.dd[ hh:mm]" [--priority P] [--repeat R] [--project P] [--tags a,b] [--remind 15m,1d] [--parent ID]
+func (c *cli) add(args []string) int {
+
+	fs := newFlagSet("add")
+	date := fs.String("date", "", "task due date yyyy.mm.dd or relative (tomorrow, next fri, +3d, in 2 weeks), optionally with time: \"yyyy.mm.dd hh:mm\"")
+	priority := fs.String("priority", "", "task priority: none, low, medium, high or 0-3")
+	repeat := fs.String("repeat", "", "repeat rule: daily, \"weekly mon,thu\", \"monthly 15\" or \"every 3 days\"")
+	project := fs.String("project", "", "project of the task")
+	tags := fs.String("tags", "", "comma separated tags")
+	remind := fs.String("remind", "", "comma separated reminders before due: 15m, 2h, 1d or 0")
+	parent := fs.String("parent", "", "id of the parent task, the new task becomes its subtask")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return c.usageError(err)
+	}
+
+	task := Task{content: strings.Join(positional, " "), list: c.listName}
+	if task.content == "" {
+		return c.usageError(errors.New("add: task content is required"))
+	}
+
+	due, err := validateDeadline(*date, c.now())
+	if err != nil {
+		return c.usageError(fmt.Errorf("add: %w", err))
+	}
+	due.apply(&task)
+	c.echoDeadline(*date, due)
+
+	task.priority, err = parsePriority(*priority)
+	if err != nil {
+		return c.usageError(fmt.Errorf("add: %w", err))
+	}
+
+	if *repeat != "" {
+		rule, err := parseRecurrence(*repeat)
+		if err != nil {
+			return c.usageError(fmt.Errorf("add: %w", err))
+		}
+		task.recur = rule.String()
+	}
+
+	task.project, err = normalizeProject(*project)
+	if err != nil {
+		return c.usageError(fmt.Errorf("add: %w", err))
+	}
+
+	if *tags != "" {
+		task.tags, err = normalizeTags(strings.Split(*tags, ","))
+		if err != nil {
+			return c.usageError(fmt.Errorf("add: %w", err))
+		}
+	}
+
+	offsets, err := parseOffsets(strings.Split(*remind, ","))
+	if err != nil {
+		return c.usageError(fmt.Errorf("add: %w", err))
+	}
+
+	task.parent, err = parseParent(*parent)
+	if err != nil {
+		return c.usageError(fmt.Errorf("add: %w", err))
+	}
+
+	id, err := c.store.Create(task)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	if len(offsets) > 0 {
+		err = c.store.AddReminders(id, offsets)
+		if err != nil {
+			return c.fail(err)
+		}
+	}
+
+	fmt.Fprintln(c.stdout, id)
+
+	return exitOK
+}
+
+// list выводит задачи: todo list [--limit N] [--page N | --after C] [--open] [--by-priority] [--upcoming DAYS] [--tag T] [--not-tag T] [--project P]
+func (c *cli) list(args []string) int {
+
+	fs := newFlagSet("list")
+	opts := listFlags(fs)
+	page := fs.Int("page", 1, "page number, pages are --limit tasks long")
+	upcoming := fs.Int("upcoming", 0, "show open tasks and occurrences of repeating tasks for this many days ahead")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return c.usageError(err)
+	}
+	if len(positional) != 0 {
+		return c.usageError(fmt.Errorf("list: unexpected arguments %q", positional))
+	}
+
+	if *upcoming > 0 {
+		occurrences, err := upcomingTasks(c.store, c.listName, c.now(), *upcoming)
+		if err != nil {
+			return c.fail(err)
+		}
+		printOccurrences(c.stdout, occurrences, c.loc)
+		return exitOK
+	}
+
+	opts.TopLevel = true
+	opts.List = c.listName
+
+	return c.printPage(c.store.List, *opts, *page)
+}
+
+// update изменяет задачу: todo update <id> [--content C] [--date D] [--priority P] [--repeat R] [--project P] [--parent ID]
+func (c *cli) update(args []string) int {
+
+	fs := newFlagSet("update")
+	content := fs.String("content", "", "new task content")
+	date := fs.String("date", "", "new task due date: yyyy.mm.dd or relative (tomorrow, next fri, +3d), optionally with time hh:mm")
+	priority := fs.String("priority", "", "new task priority: none, low, medium, high or 0-3")
+	repeat := fs.String("repeat", "", "new repeat rule, none - stop repeating")
+	project := fs.String("project", "", "new project, none - remove from project")
+	parentFlag := fs.String("parent", "", "id of the new parent task, none - make a top-level task")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return c.usageError(err)
+	}
+
+	id, err := parseID(positional)
+	if err != nil {
+		return c.usageError(fmt.Errorf("update: %w", err))
+	}
+
+	set := flagsSet(fs)
+	if len(set) == 0 {
+		return c.usageError(errors.New("update: nothing to change, use --content, --date, --priority, --repeat, --project and/or --parent"))
+	}
+
+	parent, err := parseParent(*parentFlag)
+	if err != nil {
+		return c.usageError(fmt.Errorf("update: %w", err))
+	}
+
+	task, err := c.store.Get(id)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	if set["content"] {
+		task.content = *content
+	}
+	if set["date"] {
+		due, err := validateDeadline(*date, c.now())
+		if err != nil {
+			return c.usageError(fmt.Errorf("update: %w", err))
+		}
+		due.apply(&task)
+		c.echoDeadline(*date, due)
+	}
+	if set["priority"] {
+		task.priority, err = parsePriority(*priority)
+		if err != nil {
+			return c.usageError(fmt.Errorf("update: %w", err))
+		}
+	}
+	if set["repeat"] {
+		task.recur = ""
+		if !strings.EqualFold(*repeat, "none") && *repeat != "" {
+			rule, err := parseRecurrence(*repeat)
+			if err != nil {
+				return c.usageError(fmt.Errorf("update: %w", err))
+			}
+			task.recur = rule.String()
+		}
+	}
+	if set["project"] {
+		task.project = ""
+		if !strings.EqualFold(*project, "none") {
+			task.project, err = normalizeProject(*project)
+			if err != nil {
+				return c.usageError(fmt.Errorf("update: %w", err))
+			}
+		}
+	}
+
+	// родительская задача меняется первой: если её нельзя назначить (получится цикл), остальные поля не изменятся
+	if set["parent"] {
+		err = c.store.SetParent(id, parent)
+		if err != nil {
+			return c.fail(err)
+		}
+	}
+
+	err = c.store.Update(task)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	return exitOK
+}
+
+// overdue выводит просроченные задачи текущего списка: todo overdue
+func (c *cli) overdue(args []string) int {
+
+	if len(args) > 0 {
+		return c.usageError(fmt.Errorf("overdue: unexpected arguments %q", args))
+	}
+
+	overdue, err := overdueTasks(c.store, c.listName, c.now())
+	if err != nil {
+		return c.fail(err)
+	}
+
+	printTasks(c.stdout, overdue, c.loc, time.Time{})
+
+	return exitOK
+}
+
+// reschedule переносит сроки задач: todo reschedule <id>...|all --to DATE | --by N. Выводит перенесённые задачи.
+func (c *cli) reschedule(args []string) int {
+
+	fs := newFlagSet("reschedule")
+	to := fs.String("to", "", "new date, time of day of the tasks is kept unless given")
+	by := fs.String("by", "", "number of days to shift the tasks by")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return c.usageError(err)
+	}
+
+	var r rescheduling
+	r.ids, err = parseSelection(positional)
+	switch {
+	case err != nil:
+	case (*to == "") == (*by == ""):
+		err = errors.New("reschedule: exactly one of --to and --by is expected")
+	case *by != "":
+		r.days, err = parseShift(*by)
+	default:
+		var due deadline
+		due, err = parseDeadline(*to, c.now())
+		r.to = &due
+		if err == nil {
+			c.echoDeadline(*to, due)
+		}
+	}
+	if err != nil {
+		return c.usageError(err)
+	}
+
+	rescheduled, err := r.apply(c.store, c.listName, c.now())
+	if err != nil {
+		return c.fail(err)
+	}
+
+	printTasks(c.stdout, rescheduled, c.loc, time.Time{})
+
+	return exitOK
+}
+
+// complete отмечает задачу выполненной: todo complete <id>
+func (c *cli) complete(args []string) int {
+
+	id, err := parseID(args)
+	if err != nil {
+		return c.usageError(fmt.Errorf("complete: %w", err))
+	}
+
+	nextID, err := completeTask(c.store, id, c.now())
+	if err != nil {
+		return c.fail(err)
+	}
+
+	if nextID != 0 {
+		fmt.Fprintln(c.stdout, nextID)
+	}
+
+	return exitOK
+}
+
+// reopen снимает с задачи отметку о выполнении: todo reopen <id>
+func (c *cli) reopen(args []string) int {
+
+	id, err := parseID(args)
+	if err != nil {
+		return c.usageError(fmt.Errorf("reopen: %w", err))
+	}
+
+	err = reopenTask(c.store, id)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	return exitOK
+}
+
+// tag добавляет задаче метки: todo tag <id> <tag>...
+func (c *cli) tag(args []string) int {
+
+	id, tags, err := parseTagArgs(args)
+	if err != nil {
+		return c.usageError(fmt.Errorf("tag: %w", err))
+	}
+
+	err = c.store.AddTags(id, tags)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	return exitOK
+}
+
+// untag снимает с задачи метки: todo untag <id> <tag>...
+func (c *cli) untag(args []string) int {
+
+	id, tags, err := parseTagArgs(args)
+	if err != nil {
+		return c.usageError(fmt.Errorf("untag: %w", err))
+	}
+
+	err = c.store.RemoveTags(id, tags)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	return exitOK
+}
+
+// lists выводит списки задач (todo lists) или меняет их: todo lists new NAME, todo lists rename NAME NEW,
+// todo lists delete NAME [--into OTHER]
+func (c *cli) lists(args []string) int {
+
+	if len(args) == 0 {
+		lists, err := c.store.Lists()
+		if err != nil {
+			return c.fail(err)
+		}
+		printLists(c.stdout, lists, c.listName)
+		return exitOK
+	}
+
+	fs := newFlagSet("lists")
+	into := fs.String("into", "", "list to move tasks of the deleted list to, required if it has tasks (with the trash)")
+
+	positional, err := parseArgs(fs, args[1:])
+	if err != nil {
+		return c.usageError(err)
+	}
+
+	names := make([]string, len(positional))
+	for i, in := range positional {
+		names[i], err = normalizeListName(in)
+		if err != nil {
+			return c.usageError(fmt.Errorf("lists: %w", err))
+		}
+	}
+
+	switch {
+	case args[0] == "new" && len(names) == 1 && *into == "":
+		err = c.store.CreateList(names[0])
+	case args[0] == "rename" && len(names) == 2 && *into == "":
+		err = c.store.RenameList(names[0], names[1])
+	case args[0] == "delete" && len(names) == 1:
+		err = c.store.DeleteList(names[0], *into)
+	default:
+		return c.usageError(fmt.Errorf("lists: expected nothing, new NAME, rename NAME NEW or delete NAME [--into OTHER]"))
+	}
+	if err != nil {
+		return c.fail(err)
+	}
+
+	return exitOK
+}
+
+// move переносит задачу вместе с подзадачами в другой список: todo move <id> <list>
+func (c *cli) move(args []string) int {
+
+	if len(args) != 2 {
+		return c.usageError(fmt.Errorf("move: task id and list name are expected"))
+	}
+
+	id, err := parseID(args[:1])
+	if err != nil {
+		return c.usageError(fmt.Errorf("move: %w", err))
+	}
+
+	list, err := normalizeListName(args[1])
+	if err != nil {
+		return c.usageError(fmt.Errorf("move: %w", err))
+	}
+
+	err = c.store.MoveTask(id, list)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	return exitOK
+}
+
+// history выводит историю изменений задачи: todo history <id>
+func (c *cli) history(args []string) int {
+
+	id, err := parseID(args)
+	if err != nil {
+		return c.usageError(fmt.Errorf("history: %w", err))
+	}
+
+	changes, err := c.store.History(id)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	printHistory(c.stdout, changes, c.loc)
+
+	return exitOK
+}
+
+// revert возвращает задаче прежнюю версию: todo revert <id> <version>
+func (c *cli) revert(args []string) int {
+
+	if len(args) != 2 {
+		return c.usageError(fmt.Errorf("revert: task id and version are expected"))
+	}
+
+	id, err := parseID(args[:1])
+	if err != nil {
+		return c.usageError(fmt.Errorf("revert: %w", err))
+	}
+
+	version, err := strconv.Atoi(args[1])
+	if err != nil {
+		return c.usageError(fmt.Errorf("revert: bad version %q", args[1]))
+	}
+
+	err = c.store.Revert(id, version)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	return exitOK
+}
+
+// remind работает с напоминаниями: todo remind <id> [<offset>...] добавляет их задаче и выводит её напоминания,
+// todo remind без аргументов выводит напоминания, время которых настало, а с флагом --watch проверяет их до прерывания
+func (c *cli) remind(args []string) int {
+
+	fs := newFlagSet("remind")
+	watch := fs.Bool("watch", false, "keep checking reminders until interrupted")
+	interval := fs.Duration("interval", reminderInterval, "how often to check reminders with --watch")
+	command := fs.String("command", reminderCommand, "shell command to run for each reminder instead of printing it")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return c.usageError(err)
+	}
+
+	if *watch {
+		if len(positional) > 0 {
+			return c.usageError(fmt.Errorf("remind: unexpected arguments %q with --watch", positional))
+		}
+		if *interval <= 0 {
+			return c.usageError(errors.New("remind: --interval must be positive"))
+		}
+		return c.watchReminders(*interval, *command)
+	}
+
+	if len(positional) == 0 {
+		due, err := fireReminders(c.store, c.now())
+		if err != nil {
+			return c.fail(err)
+		}
+		c.notify(due, *command)
+		return exitOK
+	}
+
+	id, err := parseID(positional[:1])
+	if err != nil {
+		return c.usageError(fmt.Errorf("remind: %w", err))
+	}
+
+	offsets, err := parseOffsets(positional[1:])
+	if err != nil {
+		return c.usageError(fmt.Errorf("remind: %w", err))
+	}
+
+	if len(offsets) > 0 {
+		err = c.store.AddReminders(id, offsets)
+		if err != nil {
+			return c.fail(err)
+		}
+	}
+
+	reminders, err := c.store.Reminders(id)
+	if err != nil {
+		return c.fail(err)
+	}
+	printReminders(c.stdout, reminders, c.loc)
+
+	return exitOK
+}
+
+// unremind убирает у задачи напоминания: todo unremind <id> <offset>...
+func (c *cli) unremind(args []string) int {
+
+	if len(args) < 2 {
+		return c.usageError(errors.New("unremind: task id and at least one reminder are expected"))
+	}
+
+	id, err := parseID(args[:1])
+	if err != nil {
+		return c.usageError(fmt.Errorf("unremind: %w", err))
+	}
+
+	offsets, err := parseOffsets(args[1:])
+	if err != nil {
+		return c.usageError(fmt.Errorf("unremind: %w", err))
+	}
+
+	err = c.store.RemoveReminders(id, offsets)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	return exitOK
+}
+
+// watchReminders проверяет напоминания раз в interval, пока программу не прервут (Ctrl+C или SIGTERM).
+// Сбои проверки и команды оповещения выводятся в stderr и работу не прерывают.
+func (c *cli) watchReminders(interval time.Duration, command string) int {
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Fprintf(c.stderr, "watching reminders every %v, press Ctrl+C to stop\n", interval)
+
+	watchReminders(c.store, interval, c.now, func(due []Reminder, err error) {
+		if err != nil {
+			fmt.Fprintf(c.stderr, "error: checking reminders: %v\n", err)
+		}
+		c.notify(due, command)
+	}, ctx.Done())
+
+	return exitOK
+}
+
+// notify выводит напоминания в stdout или, если задана команда оповещения, выполняет её для каждого напоминания
+func (c *cli) notify(due []Reminder, command string) {
+
+	for _, r := range due {
+		text := formatReminder(r, c.now(), c.loc)
+		if command == "" {
+			fmt.Fprintln(c.stdout, text)
+			continue
+		}
+		err := runReminderCommand(command, r, text, c.stdout, c.stderr)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "error: %v\n", err)
+		}
+	}
+}
+
+// remove перемещает задачу в корзину: todo rm <id> [--children delete|reparent]
+func (c *cli) remove(args []string) int {
+
+	fs := newFlagSet("rm")
+	childrenFlag := fs.String("children", "", "what to do with subtasks: delete - move them to the trash too, reparent - move them up a level")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return c.usageError(err)
+	}
+
+	id, err := parseID(positional)
+	if err != nil {
+		return c.usageError(fmt.Errorf("rm: %w", err))
+	}
+
+	task, err := c.store.Get(id)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	children, err := parseChildPolicy(*childrenFlag, task)
+	if err != nil {
+		return c.usageError(fmt.Errorf("rm: %w", err))
+	}
+
+	err = c.store.Delete(id, c.now(), children)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	return exitOK
+}
+
+// trash выводит задачи из корзины: todo trash [--limit N] [--page N | --after C]
+func (c *cli) trash(args []string) int {
+
+	fs := newFlagSet("trash")
+	opts := listFlags(fs)
+	page := fs.Int("page", 1, "page number, pages are --limit tasks long")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return c.usageError(err)
+	}
+	if len(positional) > 0 {
+		return c.usageError(fmt.Errorf("trash: unexpected arguments %q", positional))
+	}
+
+	opts.Trash = true
+	opts.List = c.listName
+
+	return c.printPage(c.store.List, *opts, *page)
+}
+
+// restore возвращает задачу из корзины (todo restore <id>) или восстанавливает БД из копии (todo restore backup [FILE])
+func (c *cli) restore(args []string) int {
+
+	if len(args) > 0 && args[0] == "backup" {
+		return c.restoreBackup(args[1:])
+	}
+
+	id, err := parseID(args)
+	if err != nil {
+		return c.usageError(fmt.Errorf("restore: %w", err))
+	}
+
+	err = c.store.Restore(id)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	return exitOK
+}
+
+// search ищет задачи: todo search <query> [--limit N] [--page N | --after C] [--open] [--by-priority] [--tag T] [--not-tag T] [--project P]
+func (c *cli) search(args []string) int {
+
+	fs := newFlagSet("search")
+	opts := listFlags(fs)
+	page := fs.Int("page", 1, "page number, pages are --limit tasks long")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return c.usageError(err)
+	}
+
+	query := strings.Join(positional, " ")
+	if query == "" {
+		return c.usageError(errors.New("search: query is required"))
+	}
+	opts.List = c.listName
+
+	return c.printPage(func(opts ListOptions) ([]Task, error) {
+		return c.store.Search(query, opts)
+	}, *opts, *page)
+}
+
+// stats выводит отчёт по задачам текущего списка: todo stats [--json]
+func (c *cli) stats(args []string) int {
+
+	fs := newFlagSet("stats")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return c.usageError(err)
+	}
+	if len(positional) > 0 {
+		return c.usageError(fmt.Errorf("stats: unexpected arguments %q", positional))
+	}
+
+	stats, err := collectStats(c.store, c.listName, c.now())
+	if err != nil {
+		return c.fail(err)
+	}
+
+	if *asJSON {
+		err = writeStatsJSON(c.stdout, stats)
+		if err != nil {
+			return c.fail(err)
+		}
+		return exitOK
+	}
+
+	printStats(c.stdout, stats)
+
+	return exitOK
+}
+
+// export выгружает задачи: todo export [--format json|csv|ics] [--output FILE]
+func (c *cli) export(args []string) int {
+
+	fs := newFlagSet("export")
+	format := fs.String("format", "", "json, csv or ics, by default taken from --output extension or json")
+	output := fs.String("output", "", "file to write, by default stdout")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return c.usageError(err)
+	}
+	if len(positional) > 0 {
+		return c.usageError(errors.New("export: unexpected arguments, use --output FILE"))
+	}
+
+	switch {
+	case *format != "":
+		*format = strings.ToLower(*format)
+		err = checkFormat(*format)
+	case *output != "":
+		*format, err = formatFromName(*output)
+	default:
+		*format = formatJSON
+	}
+	if err != nil {
+		return c.usageError(err)
+	}
+
+	if *output == "" {
+		_, err = exportTasks(c.store, c.stdout, *format, c.now())
+		if err != nil {
+			return c.fail(err)
+		}
+		return exitOK
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	_, err = exportTasks(c.store, file, *format, c.now())
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return c.fail(err)
+	}
+
+	return exitOK
+}
+
+// importFile загружает задачи: todo import <FILE|-> [--format json|csv|ics].
+// Ошибки отдельных записей выводятся в stderr, если они были - возвращается exitUsage.
+func (c *cli) importFile(args []string) int {
+
+	fs := newFlagSet("import")
+	format := fs.String("format", "", "json, csv or ics, by default taken from the file extension")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return c.usageError(err)
+	}
+	if len(positional) != 1 {
+		return c.usageError(errors.New("import: exactly one file name is expected (- for stdin)"))
+	}
+	name := positional[0]
+
+	switch {
+	case *format != "":
+		*format = strings.ToLower(*format)
+		err = checkFormat(*format)
+	case name == "-":
+		err = errors.New("import: --format is required when reading stdin")
+	default:
+		*format, err = formatFromName(name)
+	}
+	if err != nil {
+		return c.usageError(err)
+	}
+
+	r := c.stdin
+	if name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return c.fail(err)
+		}
+		defer file.Close()
+		r = file
+	}
+
+	imported, rowErrs, err := importTasks(c.store, c.listName, r, *format, c.now())
+	for _, rowErr := range rowErrs {
+		fmt.Fprintf(c.stderr, "skipped %v\n", rowErr)
+	}
+	if err != nil {
+		return c.fail(err)
+	}
+
+	fmt.Fprintf(c.stdout, "%d tasks imported, %d skipped\n", imported, len(rowErrs))
+
+	if len(rowErrs) > 0 {
+		return exitUsage
+	}
+
+	return exitOK
+}
+
+// backup сохраняет резервную копию БД и выводит её путь (todo backup) или выводит пути копий (todo backup list)
+func (c *cli) backup(args []string) int {
+
+	switch strings.Join(args, " ") {
+	case "":
+		path, err := createBackup(c.store, dbPath, c.now())
+		if err != nil {
+			return c.fail(err)
+		}
+		fmt.Fprintln(c.stdout, path)
+		return exitOK
+	case "list":
+		paths, err := listBackups(dbPath)
+		if err != nil {
+			return c.fail(err)
+		}
+		for _, path := range paths {
+			fmt.Fprintln(c.stdout, path)
+		}
+		return exitOK
+	}
+
+	return c.usageError(fmt.Errorf("backup: unexpected arguments %q", args))
+}
+
+// restoreBackup восстанавливает БД из копии: todo restore backup [FILE], без FILE - из самой новой копии
+func (c *cli) restoreBackup(args []string) int {
+
+	if len(args) > 1 {
+		return c.usageError(fmt.Errorf("restore backup: unexpected arguments %q", args[1:]))
+	}
+
+	path, err := findBackup(dbPath, strings.Join(args, ""))
+	if err != nil {
+		return c.fail(err)
+	}
+
+	store, saved, err := restoreBackup(c.store, dbPath, path, c.now())
+	if store != nil && store != c.store {
+		// main закрывает хранилище, с которым запускалась команда, а заново открытое закрывается здесь
+		defer store.Close()
+	}
+	if err != nil {
+		return c.fail(err)
+	}
+
+	fmt.Fprintf(c.stderr, "restored from %s, previous database saved to %s\n", path, saved)
+
+	return exitOK
+}
+
+// sync синхронизирует задачи с другой БД: todo sync <other.db> [--policy newer|local|other|skip].
+// Конфликты выводятся в stdout вместе с принятым решением.
+func (c *cli) sync(args []string) int {
+
+	fs := newFlagSet("sync")
+	policy := fs.String("policy", "skip", "how to resolve conflicts: newer, local, other or skip")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return c.usageError(err)
+	}
+	if len(positional) != 1 {
+		return c.usageError(errors.New("sync: exactly one database file is expected"))
+	}
+
+	resolve, err := parseSyncPolicy(*policy)
+	if err != nil {
+		return c.usageError(err)
+	}
+
+	plan, err := syncDatabases(c.store, dbPath, positional[0], resolve, c.now())
+	if err != nil {
+		return c.fail(err)
+	}
+
+	printSyncReport(c.stdout, plan, positional[0], c.loc)
+
+	return exitOK
+}
+
+// serve запускает HTTP JSON API: todo serve [--addr HOST:PORT]. Сервер работает, пока программу не прервут
+// (Ctrl+C или SIGTERM), начатые запросы при этом успевают завершиться.
+func (c *cli) serve(args []string) int {
+
+	fs := newFlagSet("serve")
+	addr := fs.String("addr", serveAddr, "address to listen on")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return c.usageError(err)
+	}
+	if len(positional) > 0 {
+		return c.usageError(fmt.Errorf("serve: unexpected arguments %q", positional))
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	srv := &http.Server{
+		Handler:           newAPI(c.store, c.loc),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(listener)
+	}()
+
+	fmt.Fprintf(c.stderr, "serving the API on http://%s, press Ctrl+C to stop\n", listener.Addr())
+
+	select {
+	case err = <-served:
+		return c.fail(err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = srv.Shutdown(shutdownCtx)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	return exitOK
+}
+
+// config выводит действующие настройки и откуда взято каждое значение: todo config show
+func (c *cli) config(args []string) int {
+
+	if len(args) > 1 || len(args) == 1 && args[0] != "show" {
+		return c.usageError(fmt.Errorf("config: expected show"))
+	}
+
+	printConfig(c.stdout, c.cfg)
+
+	return exitOK
+}
+
+// tui открывает полноэкранный режим: todo tui
+func (c *cli) tui(args []string) int {
+
+	if len(args) > 0 {
+		return c.usageError(fmt.Errorf("tui: unexpected arguments %q", args))
+	}
+
+	in, inFile := c.stdin.(*os.File)
+	out, outFile := c.stdout.(*os.File)
+	if !inFile || !outFile {
+		return c.fail(invalidInputf("tui needs an interactive terminal"))
+	}
+
+	err := runTUI(c.store, c.listName, c.loc, in, out)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	return exitOK
+}
+
+// printPage выводит страницу page (с единицы) выборки, начинающейся после opts.After.
+// Если есть следующая страница, в stderr выводится подсказка, как её получить.
+func (c *cli) printPage(fetch func(opts ListOptions) ([]Task, error), opts ListOptions, page int) int {
+
+	p := newPager(fetch, opts)
+
+	allTasks, more, ok, err := p.seek(page - 1)
+	if err != nil {
+		return c.fail(err)
+	}
+	if !ok {
+		fmt.Fprintf(c.stderr, "error: page %d does not exist\n", page)
+		return exitNotFound
+	}
+
+	if opts.TopLevel {
+		err = printTree(c.stdout, c.store, allTasks, opts, c.loc, time.Time{})
+		if err != nil {
+			return c.fail(err)
+		}
+	} else {
+		printTasks(c.stdout, allTasks, c.loc, time.Time{})
+	}
+
+	if more {
+		fmt.Fprintf(c.stderr, "more tasks: --page %d or --after %s\n", page+1, formatCursor(*p.next()))
+	}
+
+	return exitOK
+}
+
+// echoDeadline выводит в stderr срок, введённый относительной записью, чтобы было видно, как он понят
+func (c *cli) echoDeadline(in string, due deadline) {
+
+	if isRelativeDate(in) {
+		fmt.Fprintf(c.stderr, "due %s\n", due.describe(c.loc))
+	}
+}
+
+// now возвращает текущий момент в часовом поясе пользователя
+func (c *cli) now() time.Time {
+
+	return time.Now().In(c.loc)
+}
+
+// usageHelp - ошибка вызова, после которой выводится вся справка: неизвестная команда или неверный флаг
+type usageHelp struct {
+	err error
+}
+
+// Error возвращает текст исходной ошибки
+func (e usageHelp) Error() string {
+
+	return e.err.Error()
+}
+
+// Unwrap возвращает исходную ошибку
+func (e usageHelp) Unwrap() error {
+
+	return e.err
+}
+
+// usageError сообщает о неверном вызове и возвращает соответствующий код. Вся справка выводится только
+// для ошибок usageHelp, иначе одна строка ошибки не теряется в справке, и её проще разобрать скрипту.
+func (c *cli) usageError(err error) int {
+
+	var help usageHelp
+	if errors.As(err, &help) {
+		fmt.Fprintf(c.stderr, "error: %v\n\n%s\n", err, cliUsage)
+	} else {
+		fmt.Fprintf(c.stderr, "error: %v, see todo help\n", err)
+	}
+
+	return exitUsage
+}
+
+// fail сообщает об ошибке выполнения команды и возвращает соответствующий код
+func (c *cli) fail(err error) int {
+
+	fmt.Fprintf(c.stderr, "error: %v\n", err)
+
+	switch {
+	case errors.Is(err, errNotFound):
+		return exitNotFound
+	case errors.Is(err, errInvalidInput):
+		return exitUsage
+	}
+
+	return exitError
+}
+
+// newFlagSet создаёт набор флагов подкоманды, ошибки разбора возвращаются, а не печатаются
+func newFlagSet(name string) *flag.FlagSet {
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	return fs
+}
+
+// parseArgs разбирает флаги, перемешанные с позиционными аргументами, и возвращает позиционные
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+
+	var positional []string
+
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, usageHelp{fmt.Errorf("%s: %w", fs.Name(), err)}
+		}
+
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// listFlags добавляет в fs флаги выборки, общие для list и search
+func listFlags(fs *flag.FlagSet) *ListOptions {
+
+	opts := &ListOptions{}
+	fs.IntVar(&opts.Limit, "limit", pageSize, "number of tasks on a page")
+	fs.Func("after", "show tasks after the cursor printed by the previous page", func(value string) error {
+		var err error
+		opts.After, err = parseCursor(value)
+		return err
+	})
+	fs.BoolVar(&opts.OnlyOpen, "open", false, "show only tasks that are not done")
+	fs.BoolVar(&opts.ByPriority, "by-priority", false, "sort by priority, then by date")
+	fs.Func("tag", "show only tasks with this tag (repeatable)", func(value string) error {
+		tags, err := normalizeTags(strings.Split(value, ","))
+		opts.Tags = append(opts.Tags, tags...)
+		return err
+	})
+	fs.Func("not-tag", "show only tasks without this tag (repeatable)", func(value string) error {
+		tags, err := normalizeTags(strings.Split(value, ","))
+		opts.ExcludeTags = append(opts.ExcludeTags, tags...)
+		return err
+	})
+	fs.Func("project", "show only tasks of this project", func(value string) error {
+		var err error
+		opts.Project, err = normalizeProject(value)
+		return err
+	})
+
+	return opts
+}
+
+// flagsSet возвращает имена флагов, явно указанных в командной строке
+func flagsSet(fs *flag.FlagSet) map[string]bool {
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	return set
+}
+
+// parseTagArgs извлекает id задачи и метки из аргументов команд tag и untag
+func parseTagArgs(args []string) (int64, []string, error) {
+
+	if len(args) < 2 {
+		return 0, nil, invalidInputf("task id and at least one tag are expected")
+	}
+
+	id, err := parseID(args[:1])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	tags, err := normalizeTags(args[1:])
+
+	return id, tags, err
+}
+
+// parseID извлекает id задачи из единственного позиционного аргумента
+func parseID(args []string) (int64, error) {
+
+	if len(args) != 1 {
+		return 0, invalidInputf("exactly one task id is expected")
+	}
+
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return 0, invalidInputf("bad task id %q", args[0])
+	}
+
+	return id, nil
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testConfig возвращает настройки по умолчанию: файл настроек и переменные окружения пользователя не читаются
+func testConfig(t *testing.T) config {
+
+	t.Helper()
+
+	env := map[string]string{configFileEnv: filepath.Join(t.TempDir(), "config")}
+	cfg, _, err := loadConfig(nil, func(name string) string { return env[name] })
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+
+	return cfg
+}
+
+// runTestCLI выполняет команду неинтерактивного режима над store и возвращает код завершения, stdout и stderr
+func runTestCLI(t *testing.T, store TaskStore, args ...string) (int, string, string) {
+
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := runCLI(store, testConfig(t), args, strings.NewReader(""), &stdout, &stderr, time.UTC)
+
+	return code, stdout.String(), stderr.String()
+}
+
+// tomorrow возвращает завтрашнюю дату в формате ввода
+func tomorrow() string {
+
+	return time.Now().UTC().AddDate(0, 0, 1).Format(dateFormfat)
+}
+
+func TestCLIAddAndList(t *testing.T) {
+
+	store := newMemoryStore()
+
+	code, out, errOut := runTestCLI(t, store, "add", "buy", "milk", "--date", tomorrow(), "--priority", "high", "--tags", "shop,home")
+	if code != exitOK || strings.TrimSpace(out) != "1" {
+		t.Fatalf("add: code %d, stdout %q, stderr %q", code, out, errOut)
+	}
+
+	task, err := store.Get(1)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if task.content != "buy milk" || task.date != tomorrow() || task.priority != priorityHigh {
+		t.Errorf("stored task %+v", task)
+	}
+	if strings.Join(task.tags, ",") != "home,shop" {
+		t.Errorf("tags %q, want home,shop", task.tags)
+	}
+
+	code, out, _ = runTestCLI(t, store, "list")
+	if code != exitOK || !strings.Contains(out, "buy milk +home +shop") {
+		t.Errorf("list: code %d, stdout %q", code, out)
+	}
+}
+
+func TestCLIAddValidation(t *testing.T) {
+
+	store := newMemoryStore()
+
+	for _, args := range [][]string{
+		{"add", "--date", tomorrow()},
+		{"add", "past", "--date", "2000.01.01"},
+		{"add", "bad", "--date", tomorrow(), "--priority", "urgent"},
+		{"add", "bad", "--date", tomorrow(), "--repeat", "sometimes"},
+		{"add", "bad", "--date", tomorrow(), "--unknown"},
+	} {
+		code, _, errOut := runTestCLI(t, store, args...)
+		if code != exitUsage || !strings.HasPrefix(errOut, "error: ") {
+			t.Errorf("%q: code %d, stderr %q", args, code, errOut)
+		}
+	}
+
+	if tasks, _ := store.List(ListOptions{}); len(tasks) != 0 {
+		t.Errorf("invalid tasks were stored: %+v", tasks)
+	}
+}
+
+func TestCLIUpdateCompleteReopen(t *testing.T) {
+
+	store := newMemoryStore()
+	runTestCLI(t, store, "add", "report", "--date", tomorrow())
+
+	code, _, errOut := runTestCLI(t, store, "update", "1", "--content", "weekly report", "--project", "work")
+	if code != exitOK {
+		t.Fatalf("update: code %d, stderr %q", code, errOut)
+	}
+	code, _, _ = runTestCLI(t, store, "complete", "1")
+	if code != exitOK {
+		t.Fatalf("complete: code %d", code)
+	}
+
+	task, _ := store.Get(1)
+	if task.content != "weekly report" || task.project != "work" || !task.done {
+		t.Errorf("after update and complete: %+v", task)
+	}
+
+	runTestCLI(t, store, "reopen", "1")
+	if task, _ = store.Get(1); task.done {
+		t.Error("reopen left the task done")
+	}
+
+	code, _, _ = runTestCLI(t, store, "history", "1")
+	if code != exitOK {
+		t.Errorf("history: code %d", code)
+	}
+}
+
+func TestCLINotFound(t *testing.T) {
+
+	store := newMemoryStore()
+
+	for _, args := range [][]string{
+		{"update", "7", "--content", "x"},
+		{"complete", "7"},
+		{"rm", "7"},
+		{"history", "7"},
+	} {
+		code, _, _ := runTestCLI(t, store, args...)
+		if code != exitNotFound {
+			t.Errorf("%q: code %d, want %d", args, code, exitNotFound)
+		}
+	}
+}
+
+func TestCLIRemoveWithSubtasks(t *testing.T) {
+
+	store := newMemoryStore()
+	runTestCLI(t, store, "add", "trip", "--date", tomorrow())
+	runTestCLI(t, store, "add", "tickets", "--date", tomorrow(), "--parent", "1")
+
+	code, _, _ := runTestCLI(t, store, "rm", "1")
+	if code != exitUsage {
+		t.Errorf("rm without --children: code %d, want %d", code, exitUsage)
+	}
+
+	code, _, _ = runTestCLI(t, store, "rm", "1", "--children", "reparent")
+	if code != exitOK {
+		t.Fatalf("rm --children reparent: code %d", code)
+	}
+	child, err := store.Get(2)
+	if err != nil || child.parent != 0 {
+		t.Errorf("subtask after reparent: %+v, %v", child, err)
+	}
+
+	code, out, _ := runTestCLI(t, store, "trash")
+	if code != exitOK || !strings.Contains(out, "trip") {
+		t.Errorf("trash: code %d, stdout %q", code, out)
+	}
+
+	runTestCLI(t, store, "restore", "1")
+	if _, err = store.Get(1); err != nil {
+		t.Errorf("restore: %v", err)
+	}
+}
+
+func TestCLITagsAndSearch(t *testing.T) {
+
+	store := newMemoryStore()
+	runTestCLI(t, store, "add", "Молоко и хлеб", "--date", tomorrow())
+	runTestCLI(t, store, "add", "call mom", "--date", tomorrow())
+	runTestCLI(t, store, "tag", "2", "family")
+
+	code, out, _ := runTestCLI(t, store, "search", "мол")
+	if code != exitOK || !strings.Contains(out, "Молоко") || strings.Contains(out, "call mom") {
+		t.Errorf("search: code %d, stdout %q", code, out)
+	}
+
+	code, out, _ = runTestCLI(t, store, "list", "--tag", "family")
+	if code != exitOK || !strings.Contains(out, "call mom") || strings.Contains(out, "хлеб") {
+		t.Errorf("list --tag: code %d, stdout %q", code, out)
+	}
+
+	runTestCLI(t, store, "untag", "2", "family")
+	if task, _ := store.Get(2); len(task.tags) != 0 {
+		t.Errorf("untag left tags %q", task.tags)
+	}
+}
+
+func TestCLIUnknownCommand(t *testing.T) {
+
+	code, _, errOut := runTestCLI(t, newMemoryStore(), "frobnicate")
+	if code != exitUsage || !strings.Contains(errOut, `unknown command "frobnicate"`) {
+		t.Errorf("code %d, stderr %q", code, errOut)
+	}
+}
+
+func TestCLIUsageErrors(t *testing.T) {
+
+	store := newMemoryStore()
+	runTestCLI(t, store, "add", "trip", "--date", tomorrow())
+	runTestCLI(t, store, "add", "tickets", "--date", tomorrow(), "--parent", "1")
+
+	// ошибки в данных - одна строка без справки
+	for _, args := range [][]string{
+		{"add", "past", "--date", "2000.01.01"},
+		{"rm", "1"},
+	} {
+		code, _, errOut := runTestCLI(t, store, args...)
+		if code != exitUsage || strings.Count(errOut, "\n") != 1 || !strings.HasSuffix(errOut, ", see todo help\n") {
+			t.Errorf("%q: code %d, stderr %q", args, code, errOut)
+		}
+	}
+
+	// неизвестная команда и неверный флаг - со справкой
+	for _, args := range [][]string{
+		{"frobnicate"},
+		{"list", "--frobnicate"},
+	} {
+		code, _, errOut := runTestCLI(t, store, args...)
+		if code != exitUsage || !strings.Contains(errOut, cliUsage) {
+			t.Errorf("%q: code %d, usage is not printed", args, code)
+		}
+	}
+}
+
+func TestCLIRescheduleOnlyOverdue(t *testing.T) {
+
+	store := newMemoryStore()
+	store.CreateList("work")
+
+	past := Task{content: "late report", list: inboxList}
+	dayDeadline(time.Now().UTC().AddDate(0, 0, -2), time.UTC).apply(&past)
+	late, _ := store.Create(past)
+	past.list = "work"
+	store.Create(past)
+	future := Task{content: "future", list: inboxList}
+	dayDeadline(time.Now().UTC().AddDate(0, 0, 1), time.UTC).apply(&future)
+	store.Create(future)
+
+	// задача не просрочена или из другого списка - не переносится ни одна задача
+	for _, ids := range []string{"3", "2"} {
+		code, _, errOut := runTestCLI(t, store, "reschedule", "1", ids, "--by", "7")
+		if code != exitUsage {
+			t.Errorf("reschedule 1 %s: code %d, stderr %q", ids, code, errOut)
+		}
+	}
+	if task, _ := store.Get(late); task.date != past.date {
+		t.Fatalf("rejected reschedule moved task 1 to %s", task.date)
+	}
+
+	code, _, errOut := runTestCLI(t, store, "reschedule", "1", "--to", tomorrow())
+	if task, _ := store.Get(late); code != exitOK || task.date != tomorrow() {
+		t.Errorf("reschedule 1: code %d, date %s, stderr %q", code, task.date, errOut)
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// значения настроек в этом запуске программы: main заполняет их из loadConfig до начала работы
+var (
+	dbPath      = dbFile          // файл базы данных
+	pageSize    = Limit           // количество задач на странице выборки
+	dateLayout  = dateFormfat     // формат ввода и вывода дат (в БД даты всегда хранятся в формате dateFormfat)
+	language    = defaultLanguage // язык сообщений
+	trashPeriod = trashDays       // сколько дней задача хранится в корзине
+	backupCount = backupKeep      // сколько последних резервных копий хранится
+)
+
+// defaultLanguage - язык сообщений по умолчанию
+const defaultLanguage = "en"
+
+// configSetting описывает настройку: имя в файле настроек, переменную окружения, флаг и значение по умолчанию
+type configSetting struct {
+	name  string // имя в файле настроек и в выводе config show
+	env   string // переменная окружения
+	flag  string // флаг командной строки, указывается перед командой: todo --page-size 20 list
+	def   string // значение по умолчанию
+	usage string // описание для справки
+}
+
+// configSettings - все настройки в порядке вывода config show
+var configSettings = []configSetting{
+	{"db", "TODO_DB", "db", dbFile, "path of the database file"},
+	{"page_size", "TODO_PAGE_SIZE", "page-size", strconv.Itoa(Limit), "number of tasks on a page"},
+	{"date_format", "TODO_DATE_FORMAT", "date-format", "yyyy.mm.dd", "date format for input and output: yyyy, mm and dd with separators"},
+	{"language", "TODO_LANG", "lang", defaultLanguage, "language of interactive mode messages: en or ru, defaults to the locale"},
+	{"list", "TODO_LIST", "list", "", "task list to start with, empty - the inbox"},
+	{"time_zone", "TODO_TIME_ZONE", "time-zone", timeZone, "IANA time zone like Europe/Moscow, empty - the system one"},
+	{"trash_days", "TODO_TRASH_DAYS", "trash-days", strconv.Itoa(trashDays), "days a task stays in the trash before it is purged"},
+	{"backup_keep", "TODO_BACKUP_KEEP", "backup-keep", strconv.Itoa(backupKeep), "number of the latest backups to keep, older ones are removed"},
+}
+
+// configFileEnv - переменная окружения с путём к файлу настроек (вместо пути по умолчанию)
+const configFileEnv = "TODO_CONFIG"
+
+// configValue - действующее значение настройки и то, откуда оно взято
+type configValue struct {
+	setting configSetting
+	value   string
+	source  string // default, file PATH:LINE, env NAME или flag --NAME
+}
+
+// config - действующие настройки программы
+type config struct {
+	file   string        // путь к файлу настроек, пустой - каталог настроек пользователя неизвестен
+	found  bool          // файл настроек существует и прочитан
+	values []configValue // в порядке configSettings
+}
+
+// loadConfig собирает настройки по слоям, каждый следующий перекрывает предыдущий: значения по умолчанию,
+// файл настроек (~/.config/todo/config или путь из TODO_CONFIG), переменные окружения и флаги из начала args.
+// Возвращает настройки и аргументы после флагов (команду неинтерактивного режима). Значения здесь не проверяются,
+// это делает apply.
+func loadConfig(args []string, getenv func(string) string) (cfg config, rest []string, err error) {
+
+	for _, s := range configSettings {
+		cfg.values = append(cfg.values, configValue{setting: s, value: s.def, source: "default"})
+	}
+
+	// язык по умолчанию определяется локалью, а файл настроек, TODO_LANG и --lang его перекрывают
+	if lang, env := localeLanguage(getenv); lang != "" {
+		i := cfg.index("language")
+		cfg.values[i].value = lang
+		cfg.values[i].source = "env " + env
+	}
+
+	cfg.file = getenv(configFileEnv)
+	if cfg.file == "" {
+		dir, err := os.UserConfigDir()
+		if err == nil {
+			cfg.file = filepath.Join(dir, "todo", "config")
+		}
+	}
+	if cfg.file != "" {
+		err = cfg.readFile()
+		if err != nil {
+			return cfg, nil, err
+		}
+	}
+
+	for i, v := range cfg.values {
+		if value := getenv(v.setting.env); value != "" {
+			cfg.values[i].value = value
+			cfg.values[i].source = "env " + v.setting.env
+		}
+	}
+
+	// флаги разбираются до первого аргумента, который не флаг, - это команда со своими флагами
+	fs := newFlagSet("todo")
+	for i, v := range cfg.values {
+		fs.Func(v.setting.flag, v.setting.usage, func(value string) error {
+			cfg.values[i].value = value
+			cfg.values[i].source = "flag --" + v.setting.flag
+			return nil
+		})
+	}
+	err = fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return cfg, []string{"help"}, nil
+	}
+	if err != nil {
+		return cfg, nil, invalidInputf("%v, see todo help", err)
+	}
+
+	return cfg, fs.Args(), nil
+}
+
+// readFile читает файл настроек: строки "имя = значение", пустые строки и строки с # пропускаются.
+// Отсутствие файла ошибкой не считается.
+func (cfg *config) readFile() error {
+
+	file, err := os.Open(cfg.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+	defer file.Close()
+	cfg.found = true
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		name, value, ok := strings.Cut(text, "=")
+		name = strings.TrimSpace(name)
+		i := cfg.index(name)
+		if !ok || i < 0 {
+			return invalidInputf("%s:%d: expected setting = value, settings are %s", cfg.file, line, settingNames())
+		}
+		cfg.values[i].value = strings.Trim(strings.TrimSpace(value), `"`)
+		cfg.values[i].source = fmt.Sprintf("file %s:%d", cfg.file, line)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+
+	return nil
+}
+
+// index возвращает индекс настройки name в cfg.values, -1 - такой настройки нет
+func (cfg config) index(name string) int {
+
+	for i, v := range cfg.values {
+		if v.setting.name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// value возвращает действующее значение настройки name
+func (cfg config) value(name string) string {
+
+	return cfg.values[cfg.index(name)].value
+}
+
+// apply проверяет значения настроек и заполняет ими dbPath, pageSize, dateLayout, language, trashPeriod и backupCount.
+// Возвращает часовой пояс пользователя, в ошибке указано, откуда взято неверное значение.
+func (cfg config) apply() (*time.Location, error) {
+
+	var loc *time.Location
+
+	for _, v := range cfg.values {
+		var err error
+
+		switch v.setting.name {
+		case "db":
+			if v.value == "" {
+				err = errors.New("database path is empty")
+			}
+			dbPath = v.value
+		case "page_size":
+			pageSize, err = strconv.Atoi(v.value)
+			if err != nil || pageSize < 1 {
+				err = errors.New("expected a positive number")
+			}
+		case "date_format":
+			dateLayout, err = parseDateFormat(v.value)
+		case "language":
+			language, err = parseLanguage(v.value)
+		case "list":
+			if v.value != "" {
+				_, err = normalizeListName(v.value)
+			}
+		case "time_zone":
+			loc, err = loadZone(v.value)
+		case "trash_days":
+			trashPeriod, err = strconv.Atoi(v.value)
+			if err != nil || trashPeriod < 1 {
+				err = errors.New("expected a positive number")
+			}
+		case "backup_keep":
+			backupCount, err = strconv.Atoi(v.value)
+			if err != nil || backupCount < 1 {
+				err = errors.New("expected a positive number")
+			}
+		}
+
+		if err != nil {
+			return nil, invalidInputf("bad %s %q from %s: %w", v.setting.name, v.value, v.source, err)
+		}
+	}
+
+	return loc, nil
+}
+
+// parseDateFormat переводит формат даты из записи с yyyy, mm и dd ("dd.mm.yyyy") в формат пакета time.
+// Каждая часть должна встретиться один раз, остальные символы - разделители (не буквы и не цифры).
+func parseDateFormat(in string) (string, error) {
+
+	format := strings.ToLower(strings.TrimSpace(in))
+	layout := format
+	for _, part := range []struct{ name, layout string }{{"yyyy", "2006"}, {"mm", "01"}, {"dd", "02"}} {
+		if strings.Count(format, part.name) != 1 {
+			return "", fmt.Errorf("expected yyyy, mm and dd once each, like dd.mm.yyyy")
+		}
+		layout = strings.Replace(layout, part.name, part.layout, 1)
+	}
+
+	for _, r := range strings.NewReplacer("2006", "", "01", "", "02", "").Replace(layout) {
+		if r >= '0' && r <= '9' || r >= 'a' && r <= 'z' {
+			return "", fmt.Errorf("only separators are allowed between yyyy, mm and dd")
+		}
+	}
+
+	return layout, nil
+}
+
+// parseLanguage проверяет язык сообщений: en или ru, можно в виде локали ("ru_RU.UTF-8")
+func parseLanguage(in string) (string, error) {
+
+	lang, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(in)), "_")
+	lang, _, _ = strings.Cut(lang, ".")
+	if _, ok := catalogs[lang]; !ok {
+		return "", errors.New("expected en or ru")
+	}
+
+	return lang, nil
+}
+
+// localeLanguage возвращает язык сообщений по локали из первой заданной переменной LC_ALL, LC_MESSAGES или LANG
+// и имя этой переменной; пустая строка - локаль не задана или сообщений на её языке нет ("C", "de_DE.UTF-8")
+func localeLanguage(getenv func(string) string) (lang, env string) {
+
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		locale := getenv(env)
+		if locale == "" {
+			continue
+		}
+		lang, err := parseLanguage(locale)
+		if err != nil {
+			return "", ""
+		}
+		return lang, env
+	}
+
+	return "", ""
+}
+
+// settingNames перечисляет имена настроек через запятую
+func settingNames() string {
+
+	names := make([]string, len(configSettings))
+	for i, s := range configSettings {
+		names[i] = s.name
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// printConfig выводит действующие настройки: имя, значение и откуда оно взято, а также путь к файлу настроек
+func printConfig(w io.Writer, cfg config) {
+
+	switch {
+	case cfg.file == "":
+		fmt.Fprintln(w, "config file: unknown, set "+configFileEnv)
+	case cfg.found:
+		fmt.Fprintf(w, "config file: %s\n", cfg.file)
+	default:
+		fmt.Fprintf(w, "config file: %s (not found)\n", cfg.file)
+	}
+
+	for _, v := range cfg.values {
+		fmt.Fprintf(w, "%-12s %-24q %s\n", v.setting.name, v.value, v.source)
+	}
+}
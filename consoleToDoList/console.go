@@ -0,0 +1,1669 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// console описывает интерактивный режим работы с планировщиком
+type console struct {
+	store TaskStore      // хранилище задач
+	in    *bufio.Scanner // источник вводимых команд и данных
+	out   io.Writer      // куда выводятся сообщения
+	loc   *time.Location // часовой пояс пользователя для ввода и вывода сроков
+	pages *pager         // постраничный просмотр последней выборки read или search, nil - выборок ещё не было
+	list  string         // текущий список задач: с ним работают create, read, search и trash
+	cfg   config         // действующие настройки (команда config)
+	color bool           // выделять просроченные задачи цветом: вывод идёт в терминал и переменная NO_COLOR не задана
+
+	reminders *reminderWatch // фоновая проверка напоминаний, nil - не запущена
+}
+
+// newConsole создаёт интерактивный режим поверх хранилища store с настройками cfg, начинающий работу со списком задач
+// из настройки list (пустое имя - входящие), сроки вводятся и выводятся в часовом поясе loc
+func newConsole(store TaskStore, cfg config, in *bufio.Scanner, out io.Writer, loc *time.Location) *console {
+
+	file, isFile := out.(*os.File)
+
+	return &console{
+		store: store,
+		list:  cfg.value("list"),
+		cfg:   cfg,
+		in:    in,
+		out:   out,
+		loc:   loc,
+		color: isFile && term.IsTerminal(int(file.Fd())) && os.Getenv("NO_COLOR") == "",
+	}
+}
+
+// now возвращает текущий момент в часовом поясе пользователя
+func (c *console) now() time.Time {
+
+	return time.Now().In(c.loc)
+}
+
+// highlightAt возвращает момент, к которому просроченные задачи выделяются в таблице, нулевой - без выделения
+func (c *console) highlightAt() time.Time {
+
+	if !c.color {
+		return time.Time{}
+	}
+
+	return c.now()
+}
+
+// run запускает цикл обработки команд. Ошибка команды выводится пользователю, после чего цикл продолжается;
+// завершают его только команды exit и basedelete и конец ввода. Пока цикл работает, в фоне проверяются напоминания,
+// сработавшие выводятся перед приглашением ввести команду.
+func (c *console) run() {
+
+	fmt.Fprintln(c.out, welcomeMessage)
+
+	err := c.switchList(c.list)
+	if err != nil {
+		c.report(err)
+		return
+	}
+
+	c.startReminders()
+	defer c.stopReminders()
+
+	for {
+		c.printReminders()
+		fmt.Fprintf(c.out, "[%s] %s\n", c.list, commandMessage)
+		input, err := c.scanInput()
+		if err != nil {
+			c.report(err)
+			return
+		}
+		command, args := splitCommand(input)
+		command = localCommand(command)
+
+		switch {
+		case command == "create" || command == "c" || command == "с": // на всякий случай и в кириллице
+			err = c.create(args)
+		case command == "read" || command == "r":
+			err = c.read(args)
+		case command == "overdue":
+			err = c.overdue()
+		case command == "reschedule":
+			err = c.reschedule(args)
+		case command == "update" || command == "u":
+			err = c.update()
+		case command == "delete" || command == "d":
+			err = c.delTask(args)
+		case command == "trash":
+			err = c.trash()
+		case command == "restore":
+			err = c.restore(args)
+		case command == "backup":
+			err = c.backup(args)
+		case command == "complete" || command == "x":
+			err = c.complete(args)
+		case command == "reopen" || command == "o":
+			err = c.reopen(args)
+		case command == "tag" || command == "t":
+			err = c.tag(args)
+		case command == "untag":
+			err = c.untag(args)
+		case command == "parent":
+			err = c.setParent(args)
+		case command == "lists":
+			err = c.lists(args)
+		case command == "switch":
+			err = c.switchTo(args)
+		case command == "move":
+			err = c.move(args)
+		case command == "history" || command == "h":
+			err = c.history(args)
+		case command == "revert":
+			err = c.revert(args)
+		case command == "remind":
+			err = c.remind(args)
+		case command == "unremind":
+			err = c.unremind(args)
+		case command == "basedelete" || command == "b":
+			err = c.basedelete()
+			if err == nil {
+				return
+			}
+		case command == "search" || command == "s":
+			err = c.search(args)
+		case command == "stats":
+			err = c.stats(args)
+		case command == "next" || command == "n":
+			err = c.turnPage(1)
+		case command == "prev" || command == "p":
+			err = c.turnPage(-1)
+		case command == "goto" || command == "g":
+			err = c.gotoPage(args)
+		case command == "export":
+			err = c.export(args)
+		case command == "import":
+			err = c.importFile(args)
+		case command == "sync":
+			err = c.sync(args)
+		case command == "config":
+			err = c.config(args)
+		case command == "exit" || command == "e":
+			fmt.Fprintln(c.out, byeMessage)
+			return
+		default:
+			fmt.Fprintln(c.out, errorCommandMessage)
+		}
+
+		c.report(err)
+		if errors.Is(err, errInputClosed) || errors.Is(err, errStoreClosed) {
+			return
+		}
+	}
+}
+
+// config выводит действующие настройки и откуда взято каждое значение: "config" или "config show"
+func (c *console) config(args []string) error {
+
+	if len(args) > 1 || len(args) == 1 && args[0] != "show" {
+		return invalidInputf("config: expected show")
+	}
+
+	printConfig(c.out, c.cfg)
+
+	return nil
+}
+
+// startReminders запускает фоновую проверку напоминаний текущего хранилища
+func (c *console) startReminders() {
+
+	c.reminders = startReminderWatch(c.store, c.loc)
+}
+
+// stopReminders останавливает фоновую проверку напоминаний (если она запущена), после этого хранилище можно закрывать
+func (c *console) stopReminders() {
+
+	if c.reminders == nil {
+		return
+	}
+
+	c.reminders.Stop()
+	c.reminders = nil
+}
+
+// printReminders выводит сработавшие с прошлого приглашения напоминания
+func (c *console) printReminders() {
+
+	if c.reminders == nil {
+		return
+	}
+
+	for _, note := range c.reminders.take() {
+		fmt.Fprintln(c.out, note)
+	}
+}
+
+// report выводит пользователю сообщение об ошибке команды в зависимости от её вида
+func (c *console) report(err error) {
+
+	switch {
+	case err == nil:
+	case errors.Is(err, errInputClosed):
+		fmt.Fprintln(c.out, byeMessage)
+	case errors.Is(err, errNotFound):
+		fmt.Fprintln(c.out, errorIdMessage)
+	case errors.Is(err, errStorage):
+		fmt.Fprintf(c.out, errorStorageMessage.String()+"\n", err)
+	default:
+		fmt.Fprintf(c.out, errorMessage.String()+"\n", err)
+	}
+}
+
+// splitCommand отделяет команду от аргументов, введённых в той же строке
+func splitCommand(input string) (string, []string) {
+
+	fields := strings.Fields(input)
+	if len(fields) == 0 {
+		return "", nil
+	}
+
+	return fields[0], fields[1:]
+}
+
+// scanInput сканирует введённые данные, errInputClosed - ввод закончился
+func (c *console) scanInput() (string, error) {
+
+	if !c.in.Scan() {
+		err := c.in.Err()
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", errInputClosed, err)
+		}
+		return "", errInputClosed
+	}
+
+	return strings.TrimSpace(c.in.Text()), nil
+}
+
+// scanDeadline запрашивает срок (дату и необязательное время) до тех пор, пока не будет введён корректный
+func (c *console) scanDeadline() (deadline, error) {
+
+	for {
+		fmt.Fprintf(c.out, inputDateMessage.String()+"\n", datePattern())
+		in, err := c.scanInput()
+		if err != nil {
+			return deadline{}, err
+		}
+		d, ok := c.checkDeadline(in)
+		if ok {
+			return d, nil
+		}
+	}
+}
+
+// scanPriority запрашивает важность задачи до тех пор, пока не будет введена корректная, пустой ввод оставляет current
+func (c *console) scanPriority(current int) (int, error) {
+
+	for {
+		fmt.Fprintln(c.out, inputPriorityMessage)
+		in, err := c.scanInput()
+		if err != nil || in == "" {
+			return current, err
+		}
+
+		level, err := parsePriority(in)
+		if err == nil {
+			return level, nil
+		}
+		c.report(err)
+	}
+}
+
+// scanRecurrence запрашивает правило повторения до тех пор, пока не будет введено корректное.
+// Пустой ввод оставляет current, "none" отменяет повторение.
+func (c *console) scanRecurrence(current string) (string, error) {
+
+	for {
+		fmt.Fprintln(c.out, inputRepeatMessage)
+		in, err := c.scanInput()
+		if err != nil || in == "" {
+			return current, err
+		}
+		if strings.EqualFold(in, "none") {
+			return "", nil
+		}
+
+		rule, err := parseRecurrence(in)
+		if err == nil {
+			return rule.String(), nil
+		}
+		c.report(err)
+	}
+}
+
+// scanProject запрашивает проект до тех пор, пока не будет введено корректное название.
+// Пустой ввод оставляет current, "none" убирает задачу из проекта.
+func (c *console) scanProject(current string) (string, error) {
+
+	for {
+		fmt.Fprintln(c.out, inputProjectMessage)
+		in, err := c.scanInput()
+		if err != nil || in == "" {
+			return current, err
+		}
+		if strings.EqualFold(in, "none") {
+			return "", nil
+		}
+
+		project, err := normalizeProject(in)
+		if err == nil {
+			return project, nil
+		}
+		c.report(err)
+	}
+}
+
+// scanTags берёт метки из аргументов команды, а если их нет - запрашивает их до тех пор, пока не будут введены корректные
+func (c *console) scanTags(args []string) ([]string, error) {
+
+	for {
+		if len(args) == 0 {
+			fmt.Fprintln(c.out, inputTagsMessage)
+			in, err := c.scanInput()
+			if err != nil {
+				return nil, err
+			}
+			args = strings.Fields(in)
+		}
+
+		tags, err := normalizeTags(args)
+		if err == nil {
+			return tags, nil
+		}
+		c.report(err)
+		args = nil
+	}
+}
+
+// scanOffsets берёт отступы напоминаний из аргументов команды, а если их нет - запрашивает их до тех пор,
+// пока не будут введены корректные. Пустой ввод - без напоминаний.
+func (c *console) scanOffsets(args []string) ([]time.Duration, error) {
+
+	for {
+		if len(args) == 0 {
+			fmt.Fprintln(c.out, inputRemindMessage)
+			in, err := c.scanInput()
+			if err != nil {
+				return nil, err
+			}
+			args = strings.Fields(in)
+		}
+
+		offsets, err := parseOffsets(args)
+		if err == nil {
+			return offsets, nil
+		}
+		c.report(err)
+		args = nil
+	}
+}
+
+// scanID берёт id задачи из аргументов команды, а если их нет - запрашивает его сообщением prompt
+func (c *console) scanID(args []string, prompt message) (int64, error) {
+
+	in := strings.Join(args, " ")
+	if in == "" {
+		fmt.Fprintln(c.out, prompt)
+		var err error
+		in, err = c.scanInput()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	id, err := strconv.ParseInt(in, 10, 64)
+	if err != nil {
+		return 0, invalidInputf("bad task id %q", in)
+	}
+
+	return id, nil
+}
+
+// checkDeadline проверяет корректность введённого срока, срок, введённый относительной записью, выводится для подтверждения
+func (c *console) checkDeadline(in string) (deadline, bool) {
+
+	d, err := validateDeadline(in, c.now())
+	if errors.Is(err, errPastDate) {
+		fmt.Fprintln(c.out, dateInvTimeMessage)
+		return d, false
+	}
+	if err != nil {
+		c.report(err)
+		return d, false
+	}
+
+	if isRelativeDate(in) {
+		fmt.Fprintf(c.out, resolvedDateMessage.String()+"\n", d.describe(c.loc))
+	}
+
+	return d, true
+}
+
+// create добавляет задачу в БД, "create 5" - подзадачу задачи 5
+func (c *console) create(args []string) error {
+
+	task := Task{list: c.list}
+	var err error
+
+	// родительская задача проверяется сразу, чтобы не вводить все поля напрасно
+	if len(args) > 0 {
+		task.parent, err = parseParent(strings.Join(args, " "))
+		if err != nil {
+			return err
+		}
+		if task.parent != 0 {
+			_, err = c.store.Get(task.parent)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Fprintln(c.out, inputContentMessage)
+	task.content, err = c.scanInput()
+	if err != nil {
+		return err
+	}
+
+	due, err := c.scanDeadline()
+	if err != nil {
+		return err
+	}
+	due.apply(&task)
+
+	task.priority, err = c.scanPriority(priorityNone)
+	if err != nil {
+		return err
+	}
+
+	task.recur, err = c.scanRecurrence("")
+	if err != nil {
+		return err
+	}
+
+	task.project, err = c.scanProject("")
+	if err != nil {
+		return err
+	}
+
+	task.tags, err = c.scanTags(nil)
+	if err != nil {
+		return err
+	}
+
+	offsets, err := c.scanOffsets(nil)
+	if err != nil {
+		return err
+	}
+
+	id, err := c.store.Create(task)
+	if err != nil {
+		return err
+	}
+
+	if len(offsets) > 0 {
+		err = c.store.AddReminders(id, offsets)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(c.out, addedMessage.String()+"\n", id)
+
+	return nil
+}
+
+// read выводит список всех задач, отсортированных по дате в максимальном количестве limit на странице,
+// деревом: на странице - задачи верхнего уровня, под каждой - её подзадачи с отступом.
+// Аргументы команды задают фильтры: open - только невыполненные, priority - сортировка по важности, затем по дате,
+// upcoming[:N] - невыполненные задачи и повторения повторяющихся задач на N ближайших дней.
+func (c *console) read(args []string) error {
+
+	opts, window, err := parseListArgs(args)
+	if err != nil {
+		return err
+	}
+
+	if window > 0 {
+		upcoming, err := upcomingTasks(c.store, c.list, c.now(), window)
+		if err != nil {
+			return err
+		}
+		printOccurrences(c.out, upcoming, c.loc)
+		return nil
+	}
+
+	opts.TopLevel = true
+	opts.List = c.list
+	c.pages = newPager(c.store.List, opts)
+
+	return c.showPage(0)
+}
+
+// overdue выводит просроченные задачи текущего списка, самые давние - первыми, и подсказку о переносе их сроков
+func (c *console) overdue() error {
+
+	overdue, err := overdueTasks(c.store, c.list, c.now())
+	if err != nil {
+		return err
+	}
+	if len(overdue) == 0 {
+		fmt.Fprintln(c.out, noOverdueMessage)
+		return nil
+	}
+
+	printTasks(c.out, overdue, c.loc, c.highlightAt())
+	fmt.Fprintln(c.out, rescheduleHintMessage)
+
+	return nil
+}
+
+// reschedule переносит сроки задач в одной транзакции: "reschedule 3 5 to tomorrow" - на день (время суток задач
+// сохраняется) или на момент, "reschedule all by 7" - сдвигает сроки всех просроченных задач текущего списка на 7 дней.
+// Без аргументов задачи и срок запрашиваются.
+func (c *console) reschedule(args []string) error {
+
+	if len(args) == 0 {
+		fmt.Fprintln(c.out, rescheduleMessage)
+		in, err := c.scanInput()
+		if err != nil {
+			return err
+		}
+		args = strings.Fields(in)
+	}
+
+	r, err := parseRescheduling(args, c.now())
+	if err != nil {
+		return err
+	}
+
+	rescheduled, err := r.apply(c.store, c.list, c.now())
+	if err != nil {
+		return err
+	}
+	if len(rescheduled) == 0 {
+		fmt.Fprintln(c.out, noOverdueMessage)
+		return nil
+	}
+
+	fmt.Fprintf(c.out, rescheduledMessage.String()+"\n", len(rescheduled))
+	printTasks(c.out, rescheduled, c.loc, time.Time{})
+
+	return nil
+}
+
+// update позволяет обновить задание по введённому id задачи
+func (c *console) update() error {
+
+	fmt.Fprintln(c.out, updateMassage)
+	in, err := c.scanInput()
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseInt(in, 10, 64)
+	if err != nil {
+		fmt.Fprintln(c.out, errorIdUpdateMassage)
+		return nil
+	}
+
+	task, err := c.store.Get(id)
+	if errors.Is(err, errNotFound) {
+		fmt.Fprintln(c.out, errorIdUpdateMassage)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.out, inputContentMessage)
+	task.content, err = c.scanInput()
+	if err != nil {
+		return err
+	}
+
+	due, err := c.scanDeadline()
+	if err != nil {
+		return err
+	}
+	due.apply(&task)
+
+	task.priority, err = c.scanPriority(task.priority)
+	if err != nil {
+		return err
+	}
+
+	task.recur, err = c.scanRecurrence(task.recur)
+	if err != nil {
+		return err
+	}
+
+	task.project, err = c.scanProject(task.project)
+	if err != nil {
+		return err
+	}
+
+	return c.store.Update(task)
+}
+
+// complete отмечает задачу выполненной
+func (c *console) complete(args []string) error {
+
+	id, err := c.scanID(args, completeMessage)
+	if err != nil {
+		return err
+	}
+
+	nextID, err := completeTask(c.store, id, c.now())
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, completedMessage.String()+"\n", id)
+	if nextID != 0 {
+		next, err := c.store.Get(nextID)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, nextAddedMessage.String()+"\n", next.id, formatDue(next, c.loc))
+	}
+
+	return nil
+}
+
+// reopen снимает с задачи отметку о выполнении
+func (c *console) reopen(args []string) error {
+
+	id, err := c.scanID(args, reopenMessage)
+	if err != nil {
+		return err
+	}
+
+	err = reopenTask(c.store, id)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, reopenedMessage.String()+"\n", id)
+
+	return nil
+}
+
+// tag добавляет задаче метки: "tag 5 work urgent", недостающие id и метки запрашиваются
+func (c *console) tag(args []string) error {
+
+	id, err := c.scanID(firstArg(args), tagMessage)
+	if err != nil {
+		return err
+	}
+
+	tags, err := c.scanTags(restArgs(args))
+	if err != nil {
+		return err
+	}
+
+	return c.store.AddTags(id, tags)
+}
+
+// untag снимает с задачи метки: "untag 5 work", недостающие id и метки запрашиваются
+func (c *console) untag(args []string) error {
+
+	id, err := c.scanID(firstArg(args), untagMessage)
+	if err != nil {
+		return err
+	}
+
+	tags, err := c.scanTags(restArgs(args))
+	if err != nil {
+		return err
+	}
+
+	return c.store.RemoveTags(id, tags)
+}
+
+// remind добавляет задаче напоминания: "remind 5 15m 1d"; "remind 5" выводит напоминания задачи,
+// недостающий id запрашивается
+func (c *console) remind(args []string) error {
+
+	id, err := c.scanID(firstArg(args), remindMessage)
+	if err != nil {
+		return err
+	}
+
+	if len(restArgs(args)) > 0 {
+		offsets, err := parseOffsets(restArgs(args))
+		if err != nil {
+			return err
+		}
+		err = c.store.AddReminders(id, offsets)
+		if err != nil {
+			return err
+		}
+	}
+
+	reminders, err := c.store.Reminders(id)
+	if err != nil {
+		return err
+	}
+	if len(reminders) == 0 {
+		fmt.Fprintf(c.out, noRemindersMessage.String()+"\n", id, id)
+		return nil
+	}
+
+	fmt.Fprintf(c.out, remindersMessage.String()+"\n", id)
+	printReminders(c.out, reminders, c.loc)
+
+	return nil
+}
+
+// unremind убирает у задачи напоминания: "unremind 5 15m", недостающие id и отступы запрашиваются
+func (c *console) unremind(args []string) error {
+
+	id, err := c.scanID(firstArg(args), unremindMessage)
+	if err != nil {
+		return err
+	}
+
+	offsets, err := c.scanOffsets(restArgs(args))
+	if err != nil {
+		return err
+	}
+
+	return c.store.RemoveReminders(id, offsets)
+}
+
+// history выводит историю изменений задачи: "history 5"
+func (c *console) history(args []string) error {
+
+	id, err := c.scanID(args, historyMessage)
+	if err != nil {
+		return err
+	}
+
+	changes, err := c.store.History(id)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Fprintf(c.out, noHistoryMessage.String()+"\n", id)
+		return nil
+	}
+
+	fmt.Fprintf(c.out, historyTitleMessage.String()+"\n", id, id)
+	printHistory(c.out, changes, c.loc)
+
+	return nil
+}
+
+// revert возвращает задаче одну из прежних версий: "revert 5 3", без номера версии выводится история и он запрашивается
+func (c *console) revert(args []string) error {
+
+	id, err := c.scanID(firstArg(args), revertMessage)
+	if err != nil {
+		return err
+	}
+
+	in := strings.Join(restArgs(args), " ")
+	if in == "" {
+		err = c.history([]string{strconv.FormatInt(id, 10)})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(c.out, inputVersionMessage)
+		in, err = c.scanInput()
+		if err != nil {
+			return err
+		}
+	}
+
+	version, err := strconv.Atoi(in)
+	if err != nil {
+		return invalidInputf("bad version %q, expected a number from the history", in)
+	}
+
+	err = c.store.Revert(id, version)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, revertedMessage.String()+"\n", id, version)
+
+	return nil
+}
+
+// lists выводит списки задач ("lists") или меняет их: "lists new shopping", "lists rename shopping groceries",
+// "lists delete groceries [home]" - задачи удаляемого списка переносятся в home (если список не пуст, он запрашивается)
+func (c *console) lists(args []string) error {
+
+	if len(args) == 0 {
+		lists, err := c.store.Lists()
+		if err != nil {
+			return err
+		}
+		printLists(c.out, lists, c.list)
+		fmt.Fprintln(c.out, switchHintMessage)
+		return nil
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "new":
+		name, err := c.scanListName(args[1:], newListMessage)
+		if err != nil {
+			return err
+		}
+		err = c.store.CreateList(name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, listAddedMessage.String()+"\n", name, name)
+		return nil
+	case "rename":
+		return c.renameList(args[1:])
+	case "delete":
+		return c.deleteList(args[1:])
+	}
+
+	return invalidInputf("unknown lists argument %q, expected nothing, new, rename or delete", args[0])
+}
+
+// renameList переименовывает список: "lists rename shopping groceries", недостающие имена запрашиваются
+func (c *console) renameList(args []string) error {
+
+	list, err := c.scanList(firstArg(args), renameListMessage)
+	if err != nil {
+		return err
+	}
+
+	name, err := c.scanListName(restArgs(args), inputListNameMessage)
+	if err != nil {
+		return err
+	}
+
+	err = c.store.RenameList(list.name, name)
+	if err != nil {
+		return err
+	}
+
+	if list.name == c.list {
+		c.list = name
+	}
+	fmt.Fprintf(c.out, listRenamedMessage.String()+"\n", list.name, name)
+
+	return nil
+}
+
+// deleteList удаляет список: "lists delete groceries [home]". Задачи непустого списка переносятся в указанный
+// список (в том числе задачи из корзины), если он не указан - запрашивается (пустой ввод отменяет удаление).
+func (c *console) deleteList(args []string) error {
+
+	list, err := c.scanList(firstArg(args), deleteListMessage)
+	if err != nil {
+		return err
+	}
+
+	into := strings.Join(restArgs(args), " ")
+	if into == "" && list.tasks+list.trashed > 0 {
+		fmt.Fprintf(c.out, moveTasksMessage.String()+"\n", list.tasks, list.trashed)
+		into, err = c.scanInput()
+		if err != nil {
+			return err
+		}
+		if into == "" {
+			fmt.Fprintln(c.out, listNotDeletedMessage)
+			return nil
+		}
+	}
+
+	err = c.store.DeleteList(list.name, into)
+	if err != nil {
+		return err
+	}
+
+	// из удалённого списка работа продолжается в списке, куда перенесены задачи (или во входящих)
+	if list.name == c.list {
+		err = c.switchList(into)
+		if err != nil {
+			return err
+		}
+	}
+
+	if into == "" {
+		fmt.Fprintf(c.out, listDeletedMessage.String()+"\n", list.name)
+	} else {
+		fmt.Fprintf(c.out, listMovedMessage.String()+"\n", list.name, into)
+	}
+
+	return nil
+}
+
+// switchTo делает текущим другой список: "switch work"
+func (c *console) switchTo(args []string) error {
+
+	list, err := c.scanList(args, switchMessage)
+	if err != nil {
+		return err
+	}
+
+	err = c.switchList(list.name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, switchedMessage.String()+"\n", c.list, list.tasks)
+
+	return nil
+}
+
+// switchList делает текущим список name (пустое имя - входящие), постраничный просмотр прежнего списка сбрасывается
+func (c *console) switchList(name string) error {
+
+	list, err := lookupList(c.store, name)
+	if err != nil {
+		return err
+	}
+
+	if list.name != c.list {
+		c.pages = nil
+	}
+	c.list = list.name
+
+	return nil
+}
+
+// move переносит задачу вместе с подзадачами в другой список: "move 5 home"
+func (c *console) move(args []string) error {
+
+	id, err := c.scanID(firstArg(args), moveMessage)
+	if err != nil {
+		return err
+	}
+
+	list, err := c.scanList(restArgs(args), inputMoveListMessage)
+	if err != nil {
+		return err
+	}
+
+	err = c.store.MoveTask(id, list.name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, movedMessage.String()+"\n", id, list.name)
+
+	return nil
+}
+
+// scanList берёт имя существующего списка из аргументов команды, а если их нет - запрашивает его сообщением prompt
+func (c *console) scanList(args []string, prompt message) (TaskList, error) {
+
+	name, err := c.scanListName(args, prompt)
+	if err != nil {
+		return TaskList{}, err
+	}
+
+	return lookupList(c.store, name)
+}
+
+// scanListName берёт имя списка из аргументов команды, а если их нет - запрашивает его сообщением prompt
+func (c *console) scanListName(args []string, prompt message) (string, error) {
+
+	in := strings.Join(args, " ")
+	if in == "" {
+		fmt.Fprintln(c.out, prompt)
+		var err error
+		in, err = c.scanInput()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return normalizeListName(in)
+}
+
+// firstArg возвращает первый аргумент команды (если он есть) для scanID
+func firstArg(args []string) []string {
+
+	if len(args) == 0 {
+		return nil
+	}
+
+	return args[:1]
+}
+
+// restArgs возвращает аргументы команды после первого
+func restArgs(args []string) []string {
+
+	if len(args) < 2 {
+		return nil
+	}
+
+	return args[1:]
+}
+
+// delTask перемещает в корзину задачу по введённому id ("delete 5"), несуществующий id выводит предупреждение.
+// Если у задачи есть подзадачи, пользователь выбирает, удалить их вместе с ней или поднять на её уровень.
+func (c *console) delTask(args []string) error {
+
+	id, err := c.scanID(args, deleteMessage)
+	if err != nil {
+		return err
+	}
+
+	task, err := c.store.Get(id)
+	if err != nil {
+		return err
+	}
+
+	children := childrenDelete
+	if task.subtasks > 0 {
+		fmt.Fprintf(c.out, deleteSubtasksMessage.String()+"\n", task.subtasks)
+		in, err := c.scanInput()
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(in) {
+		case "d", "delete":
+		case "r", "reparent":
+			children = childrenReparent
+		default:
+			fmt.Fprintln(c.out, notDeletedMessage)
+			return nil
+		}
+	}
+
+	err = c.store.Delete(id, c.now(), children)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case task.subtasks > 0 && children == childrenDelete:
+		fmt.Fprintf(c.out, trashedAllMessage.String()+"\n", id, id)
+	case task.subtasks > 0:
+		fmt.Fprintf(c.out, trashedUpMessage.String()+"\n", id, id)
+	default:
+		fmt.Fprintf(c.out, trashedMessage.String()+"\n", id, id)
+	}
+
+	return nil
+}
+
+// setParent делает задачу подзадачей другой ("parent 7 5") или задачей верхнего уровня ("parent 7 none")
+func (c *console) setParent(args []string) error {
+
+	id, err := c.scanID(firstArg(args), parentMessage)
+	if err != nil {
+		return err
+	}
+
+	in := strings.Join(restArgs(args), " ")
+	if in == "" {
+		fmt.Fprintln(c.out, inputParentMessage)
+		in, err = c.scanInput()
+		if err != nil {
+			return err
+		}
+	}
+
+	parent, err := parseParent(in)
+	if err != nil {
+		return err
+	}
+
+	err = c.store.SetParent(id, parent)
+	if err != nil {
+		return err
+	}
+
+	if parent == 0 {
+		fmt.Fprintf(c.out, topLevelMessage.String()+"\n", id)
+	} else {
+		fmt.Fprintf(c.out, subtaskMessage.String()+"\n", id, parent)
+	}
+
+	return nil
+}
+
+// trash выводит задачи из корзины постранично (листаются next, prev и goto)
+func (c *console) trash() error {
+
+	fmt.Fprintf(c.out, trashPurgeMessage.String()+"\n", trashPeriod)
+
+	c.pages = newPager(c.store.List, ListOptions{Limit: pageSize, Trash: true, List: c.list})
+
+	return c.showPage(0)
+}
+
+// restore возвращает задачу из корзины ("restore 5") или восстанавливает БД из резервной копии ("restore backup [файл]")
+func (c *console) restore(args []string) error {
+
+	if len(args) > 0 && args[0] == "backup" {
+		return c.restoreBackup(strings.Join(args[1:], " "))
+	}
+
+	id, err := c.scanID(args, restoreMessage)
+	if err != nil {
+		return err
+	}
+
+	err = c.store.Restore(id)
+	if errors.Is(err, errNotFound) {
+		fmt.Fprintln(c.out, errorTrashIdMessage)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, restoredMessage.String()+"\n", id)
+
+	return nil
+}
+
+// basedelete сохраняет резервную копию БД, удаляет файл базы данных и запускает ракету к Марсу
+func (c *console) basedelete() error {
+
+	_, err := os.Stat(dbPath)
+	if err != nil {
+		return storageError{op: "delete database", err: err}
+	}
+
+	path, err := createBackup(c.store, dbPath, c.now())
+	if err != nil {
+		return fmt.Errorf("database is not deleted, backup failed: %w", err)
+	}
+	fmt.Fprintf(c.out, backupMessage.String()+"\n", path)
+
+	c.stopReminders()
+	err = c.store.Close()
+	if err == nil {
+		err = os.Remove(dbPath)
+	}
+	if err != nil {
+		// файл БД остался на месте, и работа продолжается с ним
+		return c.reopenStore(storageError{op: "delete database", err: err})
+	}
+
+	fmt.Fprintln(c.out, deleteBaseMessage)
+
+	return nil
+}
+
+// reopenStore открывает хранилище над dbPath заново после операции, которая закрыла его и завершилась ошибкой cause,
+// и снова запускает напоминания. Возвращает cause, а если БД открыть не удалось - ещё и ошибку с errStoreClosed,
+// по которой run завершает работу.
+func (c *console) reopenStore(cause error) error {
+
+	store, err := openSQLiteStore(dbPath, c.loc)
+	if err != nil {
+		return errors.Join(cause, storageError{op: "reopen database", err: fmt.Errorf("%w: %w", errStoreClosed, err)})
+	}
+
+	c.store = store
+	c.pages = nil
+	c.startReminders()
+
+	return cause
+}
+
+// backup сохраняет резервную копию БД ("backup") или выводит список копий ("backup list")
+func (c *console) backup(args []string) error {
+
+	switch strings.Join(args, " ") {
+	case "":
+		path, err := createBackup(c.store, dbPath, c.now())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, backupKeepMessage.String()+"\n", path, backupCount)
+		return nil
+	case "list":
+		paths, err := listBackups(dbPath)
+		if err != nil {
+			return storageError{op: "list backups", err: err}
+		}
+		if len(paths) == 0 {
+			fmt.Fprintln(c.out, noBackupsMessage)
+		}
+		for _, path := range paths {
+			fmt.Fprintln(c.out, filepath.Base(path))
+		}
+		return nil
+	}
+
+	return invalidInputf("unknown backup argument %q, expected nothing or list", strings.Join(args, " "))
+}
+
+// restoreBackup заменяет БД резервной копией name (пустое имя - самая новая копия) после проверки её целостности.
+// Текущая БД перед этим тоже сохраняется в копию, так что восстановление можно отменить.
+func (c *console) restoreBackup(name string) error {
+
+	path, err := findBackup(dbPath, name)
+	if err != nil {
+		return err
+	}
+
+	// фоновая проверка напоминаний не должна обращаться к закрываемому хранилищу
+	c.stopReminders()
+
+	// хранилище заменяется и при сбое: прежнее к этому моменту может быть закрыто и открыто заново.
+	// Если БД открыть не удалось, напоминания не запускаются, а run завершает работу по errStoreClosed.
+	store, saved, err := restoreBackup(c.store, dbPath, path, c.now())
+	if store == nil {
+		return err
+	}
+	c.store = store
+	c.pages = nil
+	c.startReminders()
+	if err != nil {
+		return err
+	}
+
+	// в восстановленной БД текущего списка может не быть, тогда работа продолжается во входящих
+	err = c.switchList(c.list)
+	if errors.Is(err, errInvalidInput) {
+		err = c.switchList("")
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, restoredBackupMessage.String()+"\n", filepath.Base(path), filepath.Base(saved))
+
+	return nil
+}
+
+// search позволяет найти задачи по словам из описания или даты, самые релевантные выводятся первыми,
+// совпадения выделяются звёздочками. Аргументы команды задают те же фильтры, что и у read.
+func (c *console) search(args []string) error {
+
+	opts, window, err := parseListArgs(args)
+	if err == nil && window > 0 {
+		err = invalidInputf("upcoming filter is supported only by read")
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.out, searchMessage)
+	searching, err := c.scanInput()
+	if err != nil {
+		return err
+	}
+	opts.List = c.list
+
+	c.pages = newPager(func(opts ListOptions) ([]Task, error) {
+		return c.store.Search(searching, opts)
+	}, opts)
+
+	return c.showPage(0)
+}
+
+// stats выводит отчёт по задачам текущего списка ("stats json" - в JSON)
+func (c *console) stats(args []string) error {
+
+	asJSON := false
+	switch strings.ToLower(strings.Join(args, " ")) {
+	case "":
+	case "json":
+		asJSON = true
+	default:
+		return invalidInputf("stats: expected nothing or json")
+	}
+
+	stats, err := collectStats(c.store, c.list, c.now())
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		return writeStatsJSON(c.out, stats)
+	}
+	printStats(c.out, stats)
+
+	return nil
+}
+
+// export выгружает все задачи в файл: "export tasks.ics", формат определяется по расширению
+// или задаётся перед именем файла ("export csv tasks.txt"), недостающие аргументы запрашиваются
+func (c *console) export(args []string) error {
+
+	format, name, err := c.scanExchangeArgs(args, exportMessage)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	count, err := exportTasks(c.store, file, format, c.now())
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, exportedMessage.String()+"\n", count, name)
+
+	return nil
+}
+
+// importFile загружает задачи из файла: "import tasks.csv" или "import json tasks.txt".
+// Записи с ошибками пропускаются, для каждой выводится номер и причина.
+func (c *console) importFile(args []string) error {
+
+	format, name, err := c.scanExchangeArgs(args, importMessage)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	imported, rowErrs, err := importTasks(c.store, c.list, file, format, c.now())
+	for _, rowErr := range rowErrs {
+		fmt.Fprintf(c.out, skippedMessage.String()+"\n", rowErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, importedMessage.String()+"\n", imported, len(rowErrs))
+
+	return nil
+}
+
+// sync синхронизирует задачи с другой БД: "sync other.db" или "sync other.db newer", последний аргумент - политика
+// разрешения конфликтов (newer, local, other или skip). Без политики по каждому конфликту спрашивается, какую версию оставить.
+func (c *console) sync(args []string) error {
+
+	resolve := c.resolveConflict
+	if len(args) > 1 {
+		policy, err := parseSyncPolicy(args[len(args)-1])
+		if err == nil {
+			args = args[:len(args)-1]
+			resolve = func(conflict syncConflict) (syncChoice, error) {
+				fmt.Fprintf(c.out, syncConflictMessage.String()+"\n", describeConflict(conflict, c.loc))
+				return policy(conflict)
+			}
+		}
+	}
+
+	name := strings.Join(args, " ")
+	if name == "" {
+		fmt.Fprintln(c.out, syncMessage)
+		var err error
+		name, err = c.scanInput()
+		if err != nil {
+			return err
+		}
+	}
+
+	plan, err := syncDatabases(c.store, dbPath, name, resolve, c.now())
+	if err != nil {
+		return err
+	}
+	c.pages = nil
+
+	if len(plan.toLocal) == 0 && len(plan.toOther) == 0 && len(plan.conflicts) == 0 {
+		fmt.Fprintf(c.out, syncNothingMessage.String()+"\n", name)
+		return nil
+	}
+	fmt.Fprintf(c.out, syncedMessage.String()+"\n", name, len(plan.toOther), len(plan.toLocal), len(plan.conflicts), plan.skipped())
+
+	return nil
+}
+
+// resolveConflict выводит конфликт синхронизации и спрашивает, какую версию задачи оставить
+func (c *console) resolveConflict(conflict syncConflict) (syncChoice, error) {
+
+	fmt.Fprintf(c.out, syncConflictMessage.String()+"\n", describeConflict(conflict, c.loc))
+	fmt.Fprintln(c.out, syncChoiceMessage)
+
+	in, err := c.scanInput()
+	if err != nil {
+		return syncSkip, err
+	}
+
+	switch strings.ToLower(in) {
+	case "h", "here":
+		return syncKeepLocal, nil
+	case "o", "other":
+		return syncKeepOther, nil
+	}
+
+	return syncSkip, nil
+}
+
+// scanExchangeArgs разбирает аргументы export и import: [формат] файл. Если файл не указан, он запрашивается.
+func (c *console) scanExchangeArgs(args []string, prompt message) (format, name string, err error) {
+
+	if len(args) > 1 {
+		format, name = strings.ToLower(args[0]), strings.Join(args[1:], " ")
+		return format, name, checkFormat(format)
+	}
+
+	name = strings.Join(args, " ")
+	if name == "" {
+		fmt.Fprintln(c.out, prompt)
+		name, err = c.scanInput()
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	format, err = formatFromName(name)
+
+	return format, name, err
+}
+
+// turnPage листает последнюю выборку на delta страниц вперёд или назад
+func (c *console) turnPage(delta int) error {
+
+	if c.pages == nil {
+		fmt.Fprintln(c.out, errorNoPagesMessage)
+		return nil
+	}
+
+	return c.showPage(c.pages.page + delta)
+}
+
+// gotoPage переходит на страницу последней выборки с номером из аргументов команды ("goto 3"), нумерация с единицы
+func (c *console) gotoPage(args []string) error {
+
+	if c.pages == nil {
+		fmt.Fprintln(c.out, errorNoPagesMessage)
+		return nil
+	}
+
+	in := strings.Join(args, " ")
+	if in == "" {
+		fmt.Fprintln(c.out, gotoMessage)
+		var err error
+		in, err = c.scanInput()
+		if err != nil {
+			return err
+		}
+	}
+
+	page, err := strconv.Atoi(in)
+	if err != nil {
+		fmt.Fprintln(c.out, errorPageMessage)
+		return nil
+	}
+
+	return c.showPage(page - 1)
+}
+
+// showPage выводит страницу page (с нуля) последней выборки и подсказку о переходе между страницами
+func (c *console) showPage(page int) error {
+
+	allTasks, more, ok, err := c.pages.seek(page)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Fprintln(c.out, errorPageMessage)
+		return nil
+	}
+
+	if c.pages.opts.TopLevel {
+		err = printTree(c.out, c.store, allTasks, c.pages.opts, c.loc, c.highlightAt())
+		if err != nil {
+			return err
+		}
+	} else {
+		printTasks(c.out, allTasks, c.loc, c.highlightAt())
+	}
+
+	current := c.pages.page + 1
+	switch {
+	case more && current > 1:
+		fmt.Fprintf(c.out, pageMessage.String()+"\n", current)
+	case more:
+		fmt.Fprintf(c.out, firstPageMessage.String()+"\n", current)
+	case current > 1:
+		fmt.Fprintf(c.out, lastPageMessage.String()+"\n", current)
+	}
+
+	return nil
+}
+
+// parseListArgs разбирает фильтры команд read и search ("open priority +work -home project:release"), window - длина периода для upcoming в днях (0 - фильтр не задан)
+func parseListArgs(args []string) (opts ListOptions, window int, err error) {
+
+	opts = ListOptions{Limit: pageSize}
+
+	for _, arg := range args {
+		name, param, hasParam := strings.Cut(strings.ToLower(arg), ":")
+
+		switch {
+		case strings.HasPrefix(arg, "+") || strings.HasPrefix(arg, "-"):
+			tags, err := normalizeTags([]string{arg[1:]})
+			if err != nil {
+				return opts, 0, err
+			}
+			if arg[0] == '+' {
+				opts.Tags = append(opts.Tags, tags...)
+			} else {
+				opts.ExcludeTags = append(opts.ExcludeTags, tags...)
+			}
+		case name == "project" && hasParam:
+			opts.Project, err = normalizeProject(arg[len("project:"):])
+			if err != nil {
+				return opts, 0, err
+			}
+		case name == "open" && !hasParam:
+			opts.OnlyOpen = true
+		case (name == "priority" || name == "p") && !hasParam:
+			opts.ByPriority = true
+		case name == "upcoming":
+			window = upcomingDays
+			if hasParam {
+				window, err = strconv.Atoi(param)
+				if err != nil || window < 1 {
+					return opts, 0, invalidInputf("bad upcoming window %q, expected number of days", param)
+				}
+			}
+		default:
+			return opts, 0, invalidInputf("unknown filter %q, expected open, priority, upcoming[:days], +tag, -tag or project:name", arg)
+		}
+	}
+
+	return opts, window, nil
+}
+
+// printTasks выводит задачи таблицей, сроки - в часовом поясе loc. Задачи, просроченные к моменту now,
+// выделяются цветом (нулевой now - без выделения).
+func printTasks(w io.Writer, allTasks []Task, loc *time.Location, now time.Time) {
+
+	printHeader(w)
+	for _, val := range allTasks {
+		printRow(w, val, formatDue(val, loc), taskStatus(val), 0, loc, now)
+	}
+}
+
+// printTree выводит задачи таблицей деревом: под каждой задачей с отступом - её подзадачи, отобранные
+// по тем же фильтрам opts (подзадача, не прошедшая фильтр, скрывается вместе со своими подзадачами)
+func printTree(w io.Writer, store TaskStore, allTasks []Task, opts ListOptions, loc *time.Location, now time.Time) error {
+
+	printHeader(w)
+
+	opts.TopLevel = false
+	opts.After = nil
+	opts.Limit = 0
+
+	return printSubtasks(w, store, allTasks, opts, 0, loc, now)
+}
+
+// printSubtasks выводит строки задач уровня depth и, рекурсивно, их подзадач
+func printSubtasks(w io.Writer, store TaskStore, allTasks []Task, opts ListOptions, depth int, loc *time.Location, now time.Time) error {
+
+	for _, task := range allTasks {
+		printRow(w, task, formatDue(task, loc), taskStatus(task), depth, loc, now)
+		if task.subtasks == 0 {
+			continue
+		}
+
+		opts.Parent = task.id
+		children, err := store.List(opts)
+		if err != nil {
+			return err
+		}
+		err = printSubtasks(w, store, children, opts, depth+1, loc, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// printOccurrences выводит таблицей представление upcoming, ещё не созданные повторения отмечены [~]
+func printOccurrences(w io.Writer, upcoming []occurrence, loc *time.Location) {
+
+	printHeader(w)
+	for _, val := range upcoming {
+		status := taskStatus(val.task)
+		if val.planned {
+			status = "[~]"
+		}
+		printRow(w, val.task, formatDueOn(val.task, val.date, loc), status, 0, loc, time.Time{})
+	}
+}
+
+// printHeader выводит заголовок таблицы задач
+func printHeader(w io.Writer) {
+
+	fmt.Fprintf(w, "%5s. %-16s %3s %-3s %v\n", "id", "due", "", "pri", "content")
+}
+
+// printRow выводит строку таблицы задач, due - срок для вывода, depth - уровень вложенности подзадачи (0 - без отступа).
+// Если задача просрочена к моменту now, строка выводится красным (нулевой now - без выделения).
+func printRow(w io.Writer, task Task, due, status string, depth int, loc *time.Location, now time.Time) {
+
+	content := task.content
+	if task.snippet != "" {
+		content = task.snippet
+	}
+	if depth > 0 {
+		content = strings.Repeat("  ", depth-1) + "└ " + content
+	}
+
+	highlight := !now.IsZero() && isOverdue(task, now)
+	if highlight {
+		fmt.Fprint(w, escRed)
+	}
+
+	fmt.Fprintf(w, "%5d. %-16s %3s %-3s %v", task.id, due, status, priorityMark(task.priority), content)
+	if progress := formatProgress(task); progress != "" {
+		fmt.Fprintf(w, " [%s]", progress)
+	}
+	if task.project != "" {
+		fmt.Fprintf(w, " project:%s", task.project)
+	}
+	for _, tag := range task.tags {
+		fmt.Fprintf(w, " +%s", tag)
+	}
+	if task.recur != "" {
+		fmt.Fprintf(w, " (%s)", describeRecurrence(task.recur))
+	}
+	if !task.deletedAt.IsZero() {
+		fmt.Fprintf(w, " [deleted %s]", task.deletedAt.In(loc).Format(dateLayout))
+	}
+	if highlight {
+		fmt.Fprint(w, escReset)
+	}
+	fmt.Fprintln(w)
+}
+
+// taskStatus возвращает отметку о выполнении задачи
+func taskStatus(task Task) string {
+
+	if task.done {
+		return "[x]"
+	}
+
+	return "[ ]"
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runTestConsole выполняет в интерактивном режиме над store строки input (exit добавляется в конце) и возвращает вывод
+func runTestConsole(t *testing.T, store TaskStore, input ...string) string {
+
+	t.Helper()
+
+	var out bytes.Buffer
+	in := bufio.NewScanner(strings.NewReader(strings.Join(append(input, "exit"), "\n") + "\n"))
+	newConsole(store, testConfig(t), in, &out, time.UTC).run()
+
+	return out.String()
+}
+
+func TestConsoleCreateAndRead(t *testing.T) {
+
+	store := newMemoryStore()
+
+	// описание, срок, затем пустые важность, повторение, проект, метки и напоминания
+	out := runTestConsole(t, store, "create", "buy milk", tomorrow(), "", "", "", "", "", "read")
+
+	task, err := store.Get(1)
+	if err != nil {
+		t.Fatalf("task was not created: %v\n%s", err, out)
+	}
+	if task.content != "buy milk" || task.date != tomorrow() || task.list != inboxList {
+		t.Errorf("created task %+v", task)
+	}
+	if !strings.Contains(out, "buy milk") || !strings.Contains(out, byeMessage.String()) {
+		t.Errorf("output:\n%s", out)
+	}
+}
+
+func TestConsoleRejectsPastDate(t *testing.T) {
+
+	store := newMemoryStore()
+
+	out := runTestConsole(t, store, "create", "late", "2000.01.01", tomorrow(), "", "", "", "", "")
+
+	if !strings.Contains(out, dateInvTimeMessage.String()) {
+		t.Errorf("past date was not refused:\n%s", out)
+	}
+	if task, err := store.Get(1); err != nil || task.date != tomorrow() {
+		t.Errorf("task %+v, %v", task, err)
+	}
+}
+
+func TestConsoleCompleteAndDelete(t *testing.T) {
+
+	store := newMemoryStore()
+	parent, _ := store.Create(Task{content: "trip", date: tomorrow(), list: inboxList})
+	store.Create(Task{content: "tickets", date: tomorrow(), parent: parent, list: inboxList})
+
+	runTestConsole(t, store, "complete 2", "delete 1", "d")
+
+	if _, err := store.Get(1); err == nil {
+		t.Error("task 1 is not in the trash")
+	}
+	trash, _ := store.List(ListOptions{Trash: true})
+	if len(trash) != 2 {
+		t.Errorf("trash has %d tasks, want the task with its subtask", len(trash))
+	}
+	for _, task := range trash {
+		if task.id == 2 && !task.done {
+			t.Error("subtask 2 is not done")
+		}
+	}
+}
+
+func TestConsoleUnknownIDAndCommand(t *testing.T) {
+
+	out := runTestConsole(t, newMemoryStore(), "complete 42", "frobnicate")
+
+	if !strings.Contains(out, errorIdMessage.String()) {
+		t.Errorf("missing id was not reported:\n%s", out)
+	}
+	if !strings.Contains(out, errorCommandMessage.String()) {
+		t.Errorf("unknown command was not reported:\n%s", out)
+	}
+}
+
+func TestConsoleLists(t *testing.T) {
+
+	store := newMemoryStore()
+
+	out := runTestConsole(t, store, "lists new work", "switch work", "create", "deploy", tomorrow(), "", "", "", "", "", "read")
+
+	task, err := store.Get(1)
+	if err != nil || task.list != "work" {
+		t.Fatalf("task %+v, %v\n%s", task, err, out)
+	}
+	if !strings.Contains(out, "[work]") {
+		t.Errorf("prompt does not show the current list:\n%s", out)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// deadline описывает срок задачи: календарный день и, если указано, время
+type deadline struct {
+	date   string    // день в формате dateFormfat в часовом поясе пользователя
+	due    time.Time // момент срока в UTC: указанное время, а для задачи на весь день - начало дня в часовом поясе пользователя
+	allDay bool      // время не указано, задача на весь день
+}
+
+// loadZone загружает часовой пояс пользователя по имени IANA ("Europe/Moscow"), пустое имя - системный пояс
+func loadZone(name string) (*time.Location, error) {
+
+	if name == "" {
+		return time.Local, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, invalidInputf("bad time zone %q, expected IANA name like Europe/Moscow", name)
+	}
+
+	return loc, nil
+}
+
+// parseDeadline разбирает срок: день в формате настройки date_format (или в формате хранения yyyy.mm.dd), относительную запись (tomorrow, next friday, +3d, in 2 weeks,
+// завтра, через неделю, см. parseRelativeDay) и необязательное время "hh:mm" ("at hh:mm", "в hh:mm").
+// Относительные дни отсчитываются от сегодняшнего дня момента now, время указано в часовом поясе now.
+func parseDeadline(in string, now time.Time) (deadline, error) {
+
+	words := strings.Fields(in)
+
+	var clock string
+	if n := len(words); n > 1 && strings.Contains(words[n-1], ":") {
+		clock = words[n-1]
+		words = words[:n-1]
+		if n := len(words); n > 1 && (strings.EqualFold(words[n-1], "at") || strings.EqualFold(words[n-1], "в")) {
+			words = words[:n-1]
+		}
+	}
+	day := strings.Join(words, " ")
+
+	date, err := parseDate(day)
+	if err != nil {
+		var ok bool
+		date, ok = parseRelativeDay(day, dayOf(now))
+		if !ok {
+			return deadline{}, invalidInputf("bad date %q, expected %s or a relative date like tomorrow, next fri, +3d, in 2 weeks, "+
+				"завтра, через неделю, optionally followed by time hh:mm", strings.Join(strings.Fields(in), " "), datePattern())
+		}
+	}
+
+	if clock == "" {
+		return dayDeadline(date, now.Location()), nil
+	}
+
+	at, err := time.Parse(timeFormat, clock)
+	if err != nil {
+		return deadline{}, invalidInputf("bad time %q, expected hh:mm", clock)
+	}
+
+	return timedDeadline(date, at.Hour(), at.Minute(), now.Location()), nil
+}
+
+// isRelativeDate проверяет, что срок введён относительной записью, а не датой: такой срок стоит показать
+// пользователю, чтобы он убедился, что запись понята правильно
+func isRelativeDate(in string) bool {
+
+	day, _, _ := strings.Cut(strings.TrimSpace(in), " ")
+	_, err := parseDate(day)
+
+	return err != nil
+}
+
+// parseDate разбирает дату в формате настройки date_format, а если не получилось - в формате хранения dateFormfat
+func parseDate(in string) (time.Time, error) {
+
+	date, err := time.Parse(dateLayout, in)
+	if err != nil {
+		date, err = time.Parse(dateFormfat, in)
+	}
+
+	return date, err
+}
+
+// displayDate переводит дату из формата хранения dateFormfat в формат вывода dateLayout
+func displayDate(date string) string {
+
+	day, err := time.Parse(dateFormfat, date)
+	if err != nil {
+		return date
+	}
+
+	return day.Format(dateLayout)
+}
+
+// datePattern возвращает формат дат dateLayout в виде для подсказок: "yyyy.mm.dd"
+func datePattern() string {
+
+	return strings.NewReplacer("2006", "yyyy", "01", "mm", "02", "dd").Replace(dateLayout)
+}
+
+// describe возвращает срок для подтверждения: день недели, дата и время (если указано) в часовом поясе loc
+func (d deadline) describe(loc *time.Location) string {
+
+	if d.allDay {
+		day, _ := time.Parse(dateFormfat, d.date)
+		return day.Weekday().String() + ", " + displayDate(d.date)
+	}
+
+	local := d.due.In(loc)
+
+	return local.Weekday().String() + ", " + local.Format(dateLayout+" "+timeFormat)
+}
+
+// validateDeadline разбирает срок в часовом поясе момента now и проверяет, что он не в прошлом
+func validateDeadline(in string, now time.Time) (deadline, error) {
+
+	d, err := parseDeadline(in, now)
+	if err != nil {
+		return d, err
+	}
+
+	return d, d.checkFuture(now)
+}
+
+// dayDeadline возвращает срок на весь день day: полночь этого дня по часовому поясу loc.
+// Дата day берётся как есть (обычно это полночь UTC, как её возвращает time.Parse).
+func dayDeadline(day time.Time, loc *time.Location) deadline {
+
+	return deadline{
+		date:   day.Format(dateFormfat),
+		due:    time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc).UTC(),
+		allDay: true,
+	}
+}
+
+// timedDeadline возвращает срок в день day в hour:min по часовому поясу loc
+func timedDeadline(day time.Time, hour, min int, loc *time.Location) deadline {
+
+	local := time.Date(day.Year(), day.Month(), day.Day(), hour, min, 0, 0, loc)
+
+	return deadline{
+		date: local.Format(dateFormfat),
+		due:  local.UTC(),
+	}
+}
+
+// momentDeadline возвращает срок в момент at, день срока определяется по часовому поясу loc
+func momentDeadline(at time.Time, loc *time.Location) deadline {
+
+	return deadline{
+		date: at.In(loc).Format(dateFormfat),
+		due:  at.UTC(),
+	}
+}
+
+// deadlineOf возвращает срок задачи
+func deadlineOf(task Task) deadline {
+
+	return deadline{date: task.date, due: task.due, allDay: task.allDay}
+}
+
+// apply записывает срок в задачу
+func (d deadline) apply(task *Task) {
+
+	task.date = d.date
+	task.due = d.due
+	task.allDay = d.allDay
+}
+
+// checkFuture возвращает errPastDate, если срок уже прошёл: для задачи на весь день - если прошёл её день
+func (d deadline) checkFuture(now time.Time) error {
+
+	if d.allDay && d.date < now.Format(dateFormfat) || !d.allDay && d.due.Before(now) {
+		return errPastDate
+	}
+
+	return nil
+}
+
+// onDay возвращает такой же срок (на весь день или в то же время суток), перенесённый на день day
+func (d deadline) onDay(day time.Time, loc *time.Location) deadline {
+
+	if d.allDay {
+		return dayDeadline(day, loc)
+	}
+
+	local := d.due.In(loc)
+
+	return timedDeadline(day, local.Hour(), local.Minute(), loc)
+}
+
+// formatDue возвращает срок задачи для вывода: дату, а для задачи со временем - дату и время в часовом поясе loc
+func formatDue(task Task, loc *time.Location) string {
+
+	if task.allDay {
+		return displayDate(task.date)
+	}
+
+	return task.due.In(loc).Format(dateLayout + " " + timeFormat)
+}
+
+// formatDueOn возвращает срок повторения задачи в день date (в формате dateFormfat) для вывода
+func formatDueOn(task Task, date string, loc *time.Location) string {
+
+	if task.allDay {
+		return displayDate(date)
+	}
+
+	return displayDate(date) + " " + task.due.In(loc).Format(timeFormat)
+}
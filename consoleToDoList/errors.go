@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// виды ошибок, по которым команды решают, что сообщить пользователю (проверяются через errors.Is)
+var (
+	errNotFound     = errors.New("task not found")     // задачи с указанным id нет
+	errInvalidInput = errors.New("invalid input")      // пользователь ввёл некорректные данные, см. inputError
+	errStorage      = errors.New("storage failure")    // хранилище не смогло выполнить операцию, см. storageError
+	errInputClosed  = errors.New("input closed")       // ввод команд закончился (например, конец файла в stdin)
+	errStoreClosed  = errors.New("database is closed") // хранилище закрыто и не открылось заново, работать дальше нельзя
+	errPastDate     = invalidInput(errPastDateReason)  // дата задачи указывает на прошедший день
+)
+
+// errPastDateReason - текст ошибки errPastDate
+var errPastDateReason = errors.New("date is in the past")
+
+// inputError - ошибка во введённых данных: сообщение описывает, что не так, а errors.Is(err, errInvalidInput) истинно
+type inputError struct {
+	err error
+}
+
+// invalidInput помечает ошибку как ошибку ввода (nil остаётся nil)
+func invalidInput(err error) error {
+
+	if err == nil {
+		return nil
+	}
+
+	return inputError{err: err}
+}
+
+// invalidInputf создаёт ошибку ввода по формату, как fmt.Errorf
+func invalidInputf(format string, args ...any) error {
+
+	return inputError{err: fmt.Errorf(format, args...)}
+}
+
+// Error возвращает описание ошибки ввода
+func (e inputError) Error() string {
+
+	return e.err.Error()
+}
+
+// Unwrap возвращает исходную ошибку
+func (e inputError) Unwrap() error {
+
+	return e.err
+}
+
+// Is относит ошибку к виду errInvalidInput
+func (e inputError) Is(target error) bool {
+
+	return target == errInvalidInput
+}
+
+// storageError - сбой хранилища при выполнении операции op
+type storageError struct {
+	op  string
+	err error
+}
+
+// Error возвращает описание сбоя с названием операции
+func (e storageError) Error() string {
+
+	return fmt.Sprintf("%s: %v", e.op, e.err)
+}
+
+// Unwrap возвращает исходную ошибку
+func (e storageError) Unwrap() error {
+
+	return e.err
+}
+
+// Is относит ошибку к виду errStorage
+func (e storageError) Is(target error) bool {
+
+	return target == errStorage
+}
+
+// storageFailure оборачивает ошибку *err, возникшую в операции op хранилища, в storageError.
+// Ошибки, которые уже несут свой вид (не найдено, ошибка ввода), не трогаются. Вызывается через defer.
+func storageFailure(err *error, op string) {
+
+	if *err == nil || errors.Is(*err, errNotFound) || errors.Is(*err, errInvalidInput) || errors.Is(*err, errStorage) {
+		return
+	}
+
+	*err = storageError{op: op, err: *err}
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// форматы выгрузки и загрузки задач
+const (
+	formatJSON = "json" // массив объектов taskRecord
+	formatCSV  = "csv"  // таблица со столбцами csvHeader
+	formatICS  = "ics"  // iCalendar, каждая задача - VTODO
+)
+
+// csvHeader - столбцы CSV, метки перечисляются через пробел
+var csvHeader = []string{"id", "content", "date", "due", "priority", "done", "done_at", "repeat", "project", "tags", "parent", "list"}
+
+// taskRecord - задача в том виде, в котором она выгружается и загружается
+type taskRecord struct {
+	ID       int64    `json:"id,omitempty"`       // id в исходной БД, при загрузке связывает подзадачи с родительскими задачами
+	Content  string   `json:"content"`            // описание
+	Date     string   `json:"date"`               // дата в формате dateFormfat
+	Due      string   `json:"due,omitempty"`      // момент срока в RFC 3339 (UTC), только для задачи со временем
+	Priority string   `json:"priority,omitempty"` // название уровня важности
+	Done     bool     `json:"done,omitempty"`     // задача выполнена
+	DoneAt   string   `json:"done_at,omitempty"`  // момент выполнения в RFC 3339
+	Repeat   string   `json:"repeat,omitempty"`   // правило повторения в канонической записи
+	Project  string   `json:"project,omitempty"`  // проект
+	Tags     []string `json:"tags,omitempty"`     // метки
+	Parent   int64    `json:"parent,omitempty"`   // id родительской задачи в исходной БД, при загрузке - в пределах файла
+	List     string   `json:"list,omitempty"`     // имя списка задач, при загрузке недостающий список создаётся
+
+	Subtasks     int `json:"subtasks,omitempty"`      // количество подзадач, при загрузке не используется
+	SubtasksDone int `json:"subtasks_done,omitempty"` // сколько из них выполнено, при загрузке не используется
+}
+
+// importRow - запись, прочитанная из файла, с номером строки (записи) для сообщений об ошибках
+type importRow struct {
+	row    int
+	record taskRecord
+	err    error // ошибка разбора записи, запись при этом пропускается
+}
+
+// importError описывает ошибку в одной записи загружаемого файла
+type importError struct {
+	row int
+	err error
+}
+
+// Error возвращает текст ошибки с номером записи
+func (e importError) Error() string {
+
+	return fmt.Sprintf("row %d: %v", e.row, e.err)
+}
+
+// Unwrap возвращает исходную ошибку
+func (e importError) Unwrap() error {
+
+	return e.err
+}
+
+// formatFromName определяет формат по расширению файла
+func formatFromName(name string) (string, error) {
+
+	format := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	if format == "ical" {
+		format = formatICS
+	}
+
+	return format, checkFormat(format)
+}
+
+// checkFormat проверяет, что формат поддерживается
+func checkFormat(format string) error {
+
+	switch format {
+	case formatJSON, formatCSV, formatICS:
+		return nil
+	}
+
+	return invalidInputf("unknown format %q, expected %s, %s or %s", format, formatJSON, formatCSV, formatICS)
+}
+
+// exportTasks выгружает все задачи в w в формате format и возвращает их количество
+// Сроки со временем выгружаются в UTC.
+func exportTasks(store TaskStore, w io.Writer, format string, now time.Time) (int, error) {
+
+	err := checkFormat(format)
+	if err != nil {
+		return 0, err
+	}
+
+	allTasks, err := store.List(ListOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	records := make([]taskRecord, len(allTasks))
+	for i, task := range allTasks {
+		records[i] = recordOf(task)
+	}
+
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(records)
+	case formatCSV:
+		err = writeCSV(w, records)
+	case formatICS:
+		err = writeICS(w, records, now)
+	}
+
+	return len(records), err
+}
+
+// importTasks загружает задачи из r в формате format. Каждая запись проверяется по тем же правилам, что и ввод
+// с клавиатуры, кроме срока в прошлом (он допустим, иначе потерялись бы просроченные задачи): ошибочные записи
+// пропускаются и возвращаются в rowErrs, остальные добавляются.
+// Даты без часового пояса относятся к поясу момента now. Подзадача загружается подзадачей, если её родительская
+// задача (по id исходной БД) есть в том же файле и в том же списке, иначе - задачей верхнего уровня.
+// Задачи загружаются в указанные в записях списки (недостающие создаются), записи без списка - в список list.
+func importTasks(store TaskStore, list string, r io.Reader, format string, now time.Time) (imported int, rowErrs []error, err error) {
+
+	var rows []importRow
+
+	switch format {
+	case formatJSON:
+		rows, err = readJSON(r)
+	case formatCSV:
+		rows, err = readCSV(r)
+	case formatICS:
+		rows, err = readICS(r, now.Location())
+	default:
+		err = checkFormat(format)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	ids := make(map[int64]int64)     // id исходной БД -> id загруженной задачи
+	parents := make(map[int64]int64) // id загруженной подзадачи -> id её родительской задачи в исходной БД
+	lists := make(map[int64]string)  // id загруженной задачи -> её список
+
+	existing, err := store.Lists()
+	if err != nil {
+		return 0, nil, err
+	}
+	known := make(map[string]bool) // имена имеющихся списков в нижнем регистре
+	for _, l := range existing {
+		known[strings.ToLower(l.name)] = true
+	}
+
+	for _, row := range rows {
+		if row.err != nil {
+			rowErrs = append(rowErrs, importError{row: row.row, err: row.err})
+			continue
+		}
+
+		task, err := row.record.toTask(now)
+		if err != nil {
+			rowErrs = append(rowErrs, importError{row: row.row, err: err})
+			continue
+		}
+
+		if task.list == "" {
+			task.list = list
+		}
+		if task.list != "" && !known[strings.ToLower(task.list)] {
+			err = store.CreateList(task.list)
+			if err != nil {
+				return imported, rowErrs, err
+			}
+			known[strings.ToLower(task.list)] = true
+		}
+
+		id, err := store.Create(task)
+		if err != nil {
+			return imported, rowErrs, err
+		}
+		imported++
+		lists[id] = strings.ToLower(task.list)
+
+		if row.record.ID != 0 {
+			ids[row.record.ID] = id
+		}
+		if row.record.Parent != 0 {
+			parents[id] = row.record.Parent
+		}
+	}
+
+	// родительская задача может идти в файле после подзадачи, поэтому связи восстанавливаются после загрузки всех задач
+	for id, parent := range parents {
+		if ids[parent] == 0 || lists[id] != lists[ids[parent]] {
+			continue
+		}
+		err = store.SetParent(id, ids[parent])
+		if err != nil {
+			return imported, rowErrs, err
+		}
+	}
+
+	return imported, rowErrs, nil
+}
+
+// recordOf переводит задачу в запись для выгрузки
+func recordOf(task Task) taskRecord {
+
+	record := taskRecord{
+		ID:      task.id,
+		Content: task.content,
+		Date:    task.date,
+		Done:    task.done,
+		Repeat:  task.recur,
+		Project: task.project,
+		Tags:    task.tags,
+		Parent:  task.parent,
+		List:    task.list,
+
+		Subtasks:     task.subtasks,
+		SubtasksDone: task.subtasksDone,
+	}
+
+	if !task.allDay {
+		record.Due = task.due.UTC().Format(dueLayout)
+	}
+	if task.priority != priorityNone {
+		record.Priority = priorityNames[task.priority]
+	}
+	if task.done && !task.doneAt.IsZero() {
+		record.DoneAt = task.doneAt.UTC().Format(time.RFC3339)
+	}
+
+	return record
+}
+
+// toTask проверяет запись и переводит её в задачу
+func (r taskRecord) toTask(now time.Time) (Task, error) {
+
+	task := Task{
+		content: strings.TrimSpace(r.Content),
+		done:    r.Done,
+	}
+
+	if task.content == "" {
+		return task, errors.New("task content is empty")
+	}
+
+	// срок в прошлом допустим: выгруженные просроченные задачи должны загружаться обратно
+	due, err := r.deadline(now)
+	if err != nil {
+		return task, err
+	}
+	due.apply(&task)
+
+	task.priority, err = parsePriority(r.Priority)
+	if err != nil {
+		return task, err
+	}
+
+	if task.done {
+		task.doneAt = now
+		if r.DoneAt != "" {
+			task.doneAt, err = time.Parse(time.RFC3339, r.DoneAt)
+			if err != nil {
+				return task, fmt.Errorf("bad done_at %q, expected RFC 3339 time", r.DoneAt)
+			}
+		}
+	}
+
+	if r.Repeat != "" {
+		rule, err := parseRecurrence(r.Repeat)
+		if err != nil {
+			return task, err
+		}
+		task.recur = rule.String()
+	}
+
+	task.project, err = normalizeProject(r.Project)
+	if err != nil {
+		return task, err
+	}
+
+	task.tags, err = normalizeTags(r.Tags)
+	if err != nil {
+		return task, err
+	}
+
+	if r.List != "" {
+		task.list, err = normalizeListName(r.List)
+		if err != nil {
+			return task, err
+		}
+	}
+
+	return task, nil
+}
+
+// deadline возвращает срок записи: момент Due, если он указан, иначе день Date (относительные записи
+// отсчитываются от момента now, в его же часовом поясе)
+func (r taskRecord) deadline(now time.Time) (deadline, error) {
+
+	loc := now.Location()
+	if r.Due == "" {
+		return parseDeadline(r.Date, now)
+	}
+
+	at, err := time.Parse(time.RFC3339, strings.TrimSpace(r.Due))
+	if err != nil {
+		return deadline{}, fmt.Errorf("bad due %q, expected RFC 3339 time", r.Due)
+	}
+
+	return momentDeadline(at, loc), nil
+}
+
+// readJSON читает массив записей JSON, номер записи - её номер в массиве с единицы
+func readJSON(r io.Reader) ([]importRow, error) {
+
+	var raw []json.RawMessage
+	err := json.NewDecoder(r).Decode(&raw)
+	if err != nil {
+		return nil, fmt.Errorf("reading JSON: %w", err)
+	}
+
+	rows := make([]importRow, len(raw))
+	for i, data := range raw {
+		rows[i].row = i + 1
+		rows[i].err = json.Unmarshal(data, &rows[i].record)
+	}
+
+	return rows, nil
+}
+
+// writeCSV записывает записи в CSV с заголовком csvHeader
+func writeCSV(w io.Writer, records []taskRecord) error {
+
+	cw := csv.NewWriter(w)
+
+	err := cw.Write(csvHeader)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		err = cw.Write([]string{
+			strconv.FormatInt(r.ID, 10),
+			r.Content,
+			r.Date,
+			r.Due,
+			r.Priority,
+			strconv.FormatBool(r.Done),
+			r.DoneAt,
+			r.Repeat,
+			r.Project,
+			strings.Join(r.Tags, " "),
+			formatOptionalID(r.Parent),
+			r.List,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// formatOptionalID записывает id для CSV, 0 (нет id) - пустая строка
+func formatOptionalID(id int64) string {
+
+	if id == 0 {
+		return ""
+	}
+
+	return strconv.FormatInt(id, 10)
+}
+
+// readCSV читает CSV с заголовком: столбцы ищутся по названию, обязательны content и date.
+// Номер записи - номер строки файла.
+func readCSV(r io.Reader) ([]importRow, error) {
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"content", "date"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV header has no %q column", name)
+		}
+	}
+
+	var rows []importRow
+
+	for line := 2; ; line++ {
+		fields, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+
+		row := importRow{row: line}
+		if err != nil {
+			row.err = err
+			rows = append(rows, row)
+			continue
+		}
+
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(fields) {
+				return ""
+			}
+			return fields[i]
+		}
+
+		row.record = taskRecord{
+			Content:  field("content"),
+			Date:     field("date"),
+			Due:      field("due"),
+			Priority: field("priority"),
+			DoneAt:   field("done_at"),
+			Repeat:   field("repeat"),
+			Project:  field("project"),
+			Tags:     strings.Fields(field("tags")),
+			List:     field("list"),
+		}
+		if done := field("done"); done != "" {
+			row.record.Done, row.err = strconv.ParseBool(done)
+		}
+		if id := field("id"); id != "" && row.err == nil {
+			row.record.ID, row.err = strconv.ParseInt(id, 10, 64)
+		}
+		if parent := field("parent"); parent != "" && row.err == nil {
+			row.record.Parent, row.err = strconv.ParseInt(parent, 10, 64)
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
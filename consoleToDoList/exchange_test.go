@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+
+	now := time.Now().UTC()
+
+	source := newMemoryStore()
+
+	overdue := Task{content: "overdue report", list: inboxList, priority: priorityHigh, tags: []string{"work"}}
+	dayDeadline(now.AddDate(0, 0, -3), time.UTC).apply(&overdue)
+	source.Create(overdue)
+
+	done := Task{content: "paid rent", list: inboxList, done: true, doneAt: now.Add(-time.Hour).Truncate(time.Second)}
+	dayDeadline(now.AddDate(0, 0, -10), time.UTC).apply(&done)
+	source.Create(done)
+
+	recurring := Task{content: "standup", list: inboxList, recur: "weekly:mon,thu", project: "team"}
+	timedDeadline(now.AddDate(0, 0, 2), 10, 30, time.UTC).apply(&recurring)
+	source.Create(recurring)
+
+	want := []Task{overdue, done, recurring}
+
+	for _, format := range []string{formatJSON, formatCSV, formatICS} {
+		var file bytes.Buffer
+		n, err := exportTasks(source, &file, format, now)
+		if err != nil || n != len(want) {
+			t.Fatalf("%s: export %d tasks, %v", format, n, err)
+		}
+
+		target := newMemoryStore()
+		imported, rowErrs, err := importTasks(target, "", &file, format, now)
+		if err != nil || len(rowErrs) > 0 || imported != len(want) {
+			t.Fatalf("%s: imported %d tasks, row errors %v, %v", format, imported, rowErrs, err)
+		}
+
+		// задачи выгружаются по сроку, поэтому сопоставляются по описанию
+		allTasks, err := target.List(ListOptions{})
+		if err != nil {
+			t.Fatalf("%s: List: %v", format, err)
+		}
+		byContent := make(map[string]Task)
+		for _, task := range allTasks {
+			byContent[task.content] = task
+		}
+
+		for i, w := range want {
+			got, ok := byContent[w.content]
+			if !ok {
+				t.Fatalf("%s: task %q was not imported", format, w.content)
+			}
+			if got.content != w.content || got.date != w.date || got.allDay != w.allDay || !got.due.Equal(w.due) ||
+				got.done != w.done || got.recur != w.recur || got.priority != w.priority || got.project != w.project {
+				t.Errorf("%s: task %d\n got %+v\nwant %+v", format, i+1, got, w)
+			}
+			if w.done && !got.doneAt.Equal(w.doneAt) {
+				t.Errorf("%s: task %d done at %v, want %v", format, i+1, got.doneAt, w.doneAt)
+			}
+		}
+	}
+}
@@ -2,11 +2,14 @@ module consoleToDoList
 
 go 1.24.1
 
-require modernc.org/sqlite v1.37.0
+require (
+	github.com/google/uuid v1.6.0
+	golang.org/x/term v0.30.0
+	modernc.org/sqlite v1.37.0
+)
 
 require (
 	github.com/dustin/go-humanize v1.0.1 // indirect
-	github.com/google/uuid v1.6.0 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/ncruces/go-strftime v0.1.9 // indirect
 	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
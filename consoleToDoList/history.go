@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// taskState - состояние задачи, которое сохраняется в истории изменений (в БД - как JSON)
+type taskState struct {
+	Content   string   `json:"content"`
+	Date      string   `json:"date"`
+	Due       string   `json:"due"` // момент срока в формате dueLayout (UTC)
+	AllDay    bool     `json:"all_day"`
+	Priority  int      `json:"priority"`
+	Done      bool     `json:"done"`
+	DoneAt    string   `json:"done_at,omitempty"` // RFC 3339
+	Recur     string   `json:"recur,omitempty"`
+	Project   string   `json:"project,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	Parent    int64    `json:"parent,omitempty"`
+	List      string   `json:"list,omitempty"`
+	DeletedAt string   `json:"deleted_at,omitempty"` // RFC 3339
+}
+
+// stateOf возвращает сохраняемое состояние задачи
+func stateOf(task Task) taskState {
+
+	state := taskState{
+		Content:  task.content,
+		Date:     task.date,
+		Due:      task.due.UTC().Format(dueLayout),
+		AllDay:   task.allDay,
+		Priority: task.priority,
+		Done:     task.done,
+		DoneAt:   formatDoneAt(task.done, task.doneAt),
+		Recur:    task.recur,
+		Project:  task.project,
+		Tags:     task.tags,
+		Parent:   task.parent,
+		List:     task.list,
+	}
+	if !task.deletedAt.IsZero() {
+		state.DeletedAt = task.deletedAt.UTC().Format(time.RFC3339)
+	}
+
+	return state
+}
+
+// sameState сообщает, что изменение ничего не изменило в задаче (такие изменения в историю не записываются)
+func sameState(before, after Task) bool {
+
+	// пустой и отсутствующий список меток в JSON выглядят одинаково, поэтому сравниваются записи
+	a, errA := encodeState(before)
+	b, errB := encodeState(after)
+
+	return errA == nil && errB == nil && a == b
+}
+
+// encodeState записывает состояние задачи в JSON для БД
+func encodeState(task Task) (string, error) {
+
+	data, err := json.Marshal(stateOf(task))
+
+	return string(data), err
+}
+
+// decodeState читает из JSON состояние задачи id, пустая строка - нулевая задача (состояние до создания)
+func decodeState(id int64, data string) (Task, error) {
+
+	task := Task{id: id}
+	if data == "" {
+		return task, nil
+	}
+
+	var state taskState
+	err := json.Unmarshal([]byte(data), &state)
+	if err != nil {
+		return task, fmt.Errorf("task %d: bad history record: %w", id, err)
+	}
+
+	task.content = state.Content
+	task.date = state.Date
+	task.allDay = state.AllDay
+	task.priority = state.Priority
+	task.done = state.Done
+	task.recur = state.Recur
+	task.project = state.Project
+	task.tags = state.Tags
+	task.parent = state.Parent
+	task.list = state.List
+
+	task.due, err = time.Parse(dueLayout, state.Due)
+	if err == nil && state.DoneAt != "" {
+		task.doneAt, err = time.Parse(time.RFC3339, state.DoneAt)
+	}
+	if err == nil && state.DeletedAt != "" {
+		task.deletedAt, err = time.Parse(time.RFC3339, state.DeletedAt)
+	}
+	if err != nil {
+		return task, fmt.Errorf("task %d: bad history record: %w", id, err)
+	}
+
+	return task, nil
+}
+
+// revertTo возвращает задачу current с полями версии version: описанием, сроком, важностью, выполнением,
+// повторением, проектом и метками. Список, родительская задача и корзина остаются как есть.
+func revertTo(current, version Task) Task {
+
+	reverted := version
+	reverted.id = current.id
+	reverted.parent = current.parent
+	reverted.list = current.list
+	reverted.deletedAt = current.deletedAt
+
+	return reverted
+}
+
+// taskField - поле задачи в описании изменения
+type taskField struct {
+	name  string
+	value string
+}
+
+// describeFields возвращает поля задачи в виде для вывода, сроки - в часовом поясе loc
+func describeFields(task Task, loc *time.Location) []taskField {
+
+	status := "open"
+	if task.done {
+		status = "done"
+	}
+
+	fields := []taskField{
+		{"content", fmt.Sprintf("%q", task.content)},
+		{"due", formatDue(task, loc)},
+		{"priority", priorityNames[task.priority]},
+		{"status", status},
+		{"repeat", "none"},
+		{"project", "none"},
+		{"tags", "none"},
+		{"parent", "none"},
+		{"list", "none"},
+	}
+	if task.recur != "" {
+		fields[4].value = describeRecurrence(task.recur)
+	}
+	if task.project != "" {
+		fields[5].value = task.project
+	}
+	if len(task.tags) > 0 {
+		fields[6].value = "+" + strings.Join(task.tags, " +")
+	}
+	if task.parent != 0 {
+		fields[7].value = fmt.Sprint(task.parent)
+	}
+	if task.list != "" {
+		fields[8].value = task.list
+	}
+
+	return fields
+}
+
+// describeChange описывает изменение: для создания - поля новой задачи, для остальных - изменившиеся поля "было -> стало"
+func describeChange(c Change, loc *time.Location) string {
+
+	after := describeFields(c.after, loc)
+
+	var parts []string
+	if c.action == actionCreate {
+		for _, f := range after {
+			if f.value != "none" && f.value != "open" {
+				parts = append(parts, f.name+" "+f.value)
+			}
+		}
+		return strings.Join(parts, ", ")
+	}
+
+	// корзина в полях не выводится: удаление и восстановление видны по действию
+	before := describeFields(c.before, loc)
+	for i, f := range after {
+		if f.value != before[i].value {
+			parts = append(parts, fmt.Sprintf("%s %s -> %s", f.name, before[i].value, f.value))
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// printHistory выводит историю изменений задачи: номер версии, момент, действие и что изменилось
+func printHistory(w io.Writer, changes []Change, loc *time.Location) {
+
+	for _, c := range changes {
+		fmt.Fprintf(w, "%5d. %s %-7s %s\n", c.version, c.at.In(loc).Format(dateLayout+" "+timeFormat), c.action, describeChange(c, loc))
+	}
+}
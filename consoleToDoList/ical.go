@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// icsDate и icsTime - форматы дат и моментов времени iCalendar (RFC 5545)
+const (
+	icsDate = "20060102"
+	icsTime = "20060102T150405Z"
+)
+
+// icsPriority - значения PRIORITY iCalendar для уровней важности (1 - самая высокая, 0 - не указана)
+var icsPriority = []int{0, 9, 5, 1}
+
+// icsWeekdays - обозначения дней недели в RRULE, индекс совпадает с time.Weekday
+var icsWeekdays = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// writeICS записывает задачи календарём iCalendar, каждая задача - компонент VTODO
+func writeICS(w io.Writer, records []taskRecord, now time.Time) error {
+
+	bw := bufio.NewWriter(w)
+
+	line := func(name, value string) {
+		writeFolded(bw, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//consoleToDoList//TO DO List CLI//EN")
+
+	for _, r := range records {
+		line("BEGIN", "VTODO")
+		line("UID", fmt.Sprintf("task-%d@consoleToDoList", r.ID))
+		line("DTSTAMP", now.UTC().Format(icsTime))
+		line("SUMMARY", icsEscape(r.Content))
+
+		if r.Due != "" {
+			due, err := time.Parse(time.RFC3339, r.Due)
+			if err != nil {
+				return fmt.Errorf("task %d: bad due %q: %w", r.ID, r.Due, err)
+			}
+			line("DUE", due.UTC().Format(icsTime))
+		} else {
+			day, err := time.Parse(dateFormfat, r.Date)
+			if err != nil {
+				return fmt.Errorf("task %d: bad date %q: %w", r.ID, r.Date, err)
+			}
+			line("DUE;VALUE=DATE", day.Format(icsDate))
+		}
+
+		if level, err := parsePriority(r.Priority); err == nil && level != priorityNone {
+			line("PRIORITY", strconv.Itoa(icsPriority[level]))
+		}
+
+		if r.Done {
+			line("STATUS", "COMPLETED")
+			if doneAt, err := time.Parse(time.RFC3339, r.DoneAt); err == nil {
+				line("COMPLETED", doneAt.UTC().Format(icsTime))
+			}
+		} else {
+			line("STATUS", "NEEDS-ACTION")
+		}
+
+		if r.Repeat != "" {
+			rule, err := parseRecurrence(r.Repeat)
+			if err != nil {
+				return fmt.Errorf("task %d: %w", r.ID, err)
+			}
+			line("RRULE", icsRRule(rule))
+		}
+
+		if len(r.Tags) > 0 {
+			escaped := make([]string, len(r.Tags))
+			for i, tag := range r.Tags {
+				escaped[i] = icsEscape(tag)
+			}
+			line("CATEGORIES", strings.Join(escaped, ","))
+		}
+
+		if r.Project != "" {
+			line("X-TODO-PROJECT", icsEscape(r.Project))
+		}
+
+		line("END", "VTODO")
+	}
+
+	line("END", "VCALENDAR")
+
+	return bw.Flush()
+}
+
+// readICS читает компоненты VTODO календаря iCalendar, номер записи - номер VTODO в файле с единицы.
+// Срок задачи берётся из DUE (или DTSTART); время без часового пояса (и без TZID) относится к поясу loc.
+func readICS(r io.Reader, loc *time.Location) ([]importRow, error) {
+
+	lines, err := unfoldLines(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading iCalendar: %w", err)
+	}
+
+	var rows []importRow
+	var row *importRow
+	var start, startParams string
+
+	for _, l := range lines {
+		nameParams, value, ok := strings.Cut(l, ":")
+		if !ok {
+			continue
+		}
+		name, params, _ := strings.Cut(nameParams, ";")
+		name = strings.ToUpper(name)
+
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VTODO"):
+			rows = append(rows, importRow{row: len(rows) + 1})
+			row = &rows[len(rows)-1]
+			start, startParams = "", ""
+			continue
+		case name == "END" && strings.EqualFold(value, "VTODO"):
+			if row != nil && row.record.Date == "" && row.err == nil {
+				row.record.Date, row.record.Due, row.err = icsDue(startParams, start, loc)
+			}
+			row = nil
+			continue
+		case row == nil || row.err != nil:
+			continue
+		}
+
+		switch name {
+		case "SUMMARY":
+			row.record.Content = icsUnescape(value)
+		case "DUE":
+			row.record.Date, row.record.Due, row.err = icsDue(params, value, loc)
+		case "DTSTART":
+			start, startParams = value, params
+		case "PRIORITY":
+			row.record.Priority, row.err = icsPriorityName(value)
+		case "STATUS":
+			row.record.Done = strings.EqualFold(value, "COMPLETED")
+		case "COMPLETED":
+			doneAt, err := time.Parse(icsTime, value)
+			if err != nil {
+				row.err = fmt.Errorf("bad COMPLETED %q", value)
+				continue
+			}
+			row.record.Done = true
+			row.record.DoneAt = doneAt.Format(time.RFC3339)
+		case "RRULE":
+			var rule recurrence
+			rule, row.err = parseRRule(value)
+			row.record.Repeat = rule.String()
+		case "CATEGORIES":
+			for _, tag := range splitEscaped(value) {
+				row.record.Tags = append(row.record.Tags, icsUnescape(tag))
+			}
+		case "X-TODO-PROJECT":
+			row.record.Project = icsUnescape(value)
+		}
+	}
+
+	return rows, nil
+}
+
+// icsRRule переводит правило повторения в RRULE
+func icsRRule(rule recurrence) string {
+
+	switch rule.kind {
+	case repeatWeekly:
+		days := make([]string, len(rule.weekdays))
+		for i, weekday := range rule.weekdays {
+			days[i] = icsWeekdays[weekday]
+		}
+		return "FREQ=WEEKLY;BYDAY=" + strings.Join(days, ",")
+	case repeatMonthly:
+		return "FREQ=MONTHLY;BYMONTHDAY=" + strconv.Itoa(rule.day)
+	case repeatEvery:
+		return "FREQ=DAILY;INTERVAL=" + strconv.Itoa(rule.interval)
+	}
+
+	return "FREQ=DAILY"
+}
+
+// parseRRule переводит RRULE в правило повторения, поддерживаются только правила, которые умеет планировщик
+func parseRRule(value string) (recurrence, error) {
+
+	parts := make(map[string]string)
+	for _, part := range strings.Split(strings.ToUpper(value), ";") {
+		key, val, _ := strings.Cut(part, "=")
+		parts[key] = val
+	}
+
+	interval := 1
+	if parts["INTERVAL"] != "" {
+		var err error
+		interval, err = strconv.Atoi(parts["INTERVAL"])
+		if err != nil || interval < 1 {
+			return recurrence{}, fmt.Errorf("unsupported RRULE %q: bad INTERVAL", value)
+		}
+	}
+
+	switch {
+	case parts["FREQ"] == "DAILY" && interval == 1:
+		return parseRecurrence(repeatDaily)
+	case parts["FREQ"] == "DAILY":
+		return parseRecurrence(fmt.Sprintf("%s %d", repeatEvery, interval))
+	case parts["FREQ"] == "WEEKLY" && interval == 1 && parts["BYDAY"] != "":
+		var days []string
+		for _, day := range strings.Split(parts["BYDAY"], ",") {
+			found := false
+			for i, name := range icsWeekdays {
+				if day == name {
+					days = append(days, weekdayNames[i])
+					found = true
+				}
+			}
+			if !found {
+				return recurrence{}, fmt.Errorf("unsupported RRULE %q: bad BYDAY", value)
+			}
+		}
+		return parseRecurrence(repeatWeekly + " " + strings.Join(days, ","))
+	case parts["FREQ"] == "MONTHLY" && interval == 1 && parts["BYMONTHDAY"] != "":
+		return parseRecurrence(repeatMonthly + " " + parts["BYMONTHDAY"])
+	}
+
+	return recurrence{}, fmt.Errorf("unsupported RRULE %q", value)
+}
+
+// icsDue извлекает срок задачи из параметров и значения DUE или DTSTART: для даты возвращается только date,
+// для даты со временем - ещё и due в RFC 3339 (UTC)
+func icsDue(params, value string, loc *time.Location) (date, due string, err error) {
+
+	switch {
+	case value == "":
+		return "", "", errors.New("no due date, expected DUE or DTSTART")
+	case len(value) == len(icsDate):
+		day, err := time.Parse(icsDate, value)
+		if err != nil {
+			return "", "", fmt.Errorf("bad date %q", value)
+		}
+		return day.Format(dateFormfat), "", nil
+	}
+
+	zone := loc
+	if strings.HasSuffix(value, "Z") {
+		zone = time.UTC
+	} else if tzid := icsParam(params, "TZID"); tzid != "" {
+		zone, err = time.LoadLocation(tzid)
+		if err != nil {
+			return "", "", fmt.Errorf("unknown time zone %q", tzid)
+		}
+	}
+
+	at, err := time.ParseInLocation(icsTime[:len(icsTime)-1], strings.TrimSuffix(value, "Z"), zone)
+	if err != nil {
+		return "", "", fmt.Errorf("bad date-time %q", value)
+	}
+
+	return at.In(loc).Format(dateFormfat), at.UTC().Format(time.RFC3339), nil
+}
+
+// icsParam возвращает значение параметра name из списка параметров свойства ("VALUE=DATE;TZID=Europe/Moscow")
+func icsParam(params, name string) string {
+
+	for _, param := range strings.Split(params, ";") {
+		key, value, _ := strings.Cut(param, "=")
+		if strings.EqualFold(key, name) {
+			return strings.Trim(value, `"`)
+		}
+	}
+
+	return ""
+}
+
+// icsPriorityName переводит PRIORITY iCalendar в название уровня важности
+func icsPriorityName(value string) (string, error) {
+
+	p, err := strconv.Atoi(value)
+	switch {
+	case err != nil || p < 0 || p > 9:
+		return "", fmt.Errorf("bad PRIORITY %q", value)
+	case p == 0:
+		return priorityNames[priorityNone], nil
+	case p < 5:
+		return priorityNames[priorityHigh], nil
+	case p == 5:
+		return priorityNames[priorityMedium], nil
+	}
+
+	return priorityNames[priorityLow], nil
+}
+
+// writeFolded записывает строку iCalendar, перенося её по 75 байт (не разрывая символы UTF-8)
+func writeFolded(w *bufio.Writer, l string) {
+
+	limit := 75
+	for len(l) > limit {
+		cut := limit
+		for cut > 0 && !utf8Start(l[cut]) {
+			cut--
+		}
+		w.WriteString(l[:cut] + "\r\n ")
+		l = l[cut:]
+		limit = 74 // продолжение начинается с пробела
+	}
+	w.WriteString(l + "\r\n")
+}
+
+// utf8Start проверяет, что байт начинает символ UTF-8
+func utf8Start(b byte) bool {
+
+	return b&0xC0 != 0x80
+}
+
+// unfoldLines читает строки iCalendar, склеивая перенесённые (продолжение начинается с пробела или табуляции)
+func unfoldLines(r io.Reader) ([]string, error) {
+
+	var lines []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		l := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		lines = append(lines, l)
+	}
+
+	return lines, scanner.Err()
+}
+
+// icsEscape экранирует текстовое значение iCalendar
+func icsEscape(s string) string {
+
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+// icsUnescape снимает экранирование текстового значения iCalendar
+func icsUnescape(s string) string {
+
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(s)
+}
+
+// splitEscaped разбивает список значений по неэкранированным запятым
+func splitEscaped(s string) []string {
+
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
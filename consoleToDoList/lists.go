@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// normalizeListName проверяет имя списка задач: одно непустое слово
+func normalizeListName(in string) (string, error) {
+
+	name := strings.TrimSpace(in)
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return "", invalidInputf("bad list name %q, list name is a single word", name)
+	}
+
+	return name, nil
+}
+
+// lookupList возвращает список задач по имени (без учёта регистра), пустое имя - входящие
+func lookupList(store TaskStore, name string) (TaskList, error) {
+
+	lists, err := store.Lists()
+	if err != nil {
+		return TaskList{}, err
+	}
+
+	for _, list := range lists {
+		if (name == "" && list.inbox) || (name != "" && strings.EqualFold(list.name, name)) {
+			return list, nil
+		}
+	}
+
+	return TaskList{}, invalidInputf("there is no list %q, see lists", name)
+}
+
+// printLists выводит списки задач с количеством задач в каждом, текущий список current отмечен звёздочкой
+func printLists(w io.Writer, lists []TaskList, current string) {
+
+	for _, list := range lists {
+		mark := " "
+		if strings.EqualFold(list.name, current) {
+			mark = "*"
+		}
+		fmt.Fprintf(w, "%s %-20s %5d", mark, list.name, list.tasks)
+		if list.inbox {
+			fmt.Fprint(w, " (inbox)")
+		}
+		fmt.Fprintln(w)
+	}
+}
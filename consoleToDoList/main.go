@@ -3,374 +3,377 @@
 
 Описание:
 	При запуске программа проверяет наличие и в случае отсутствия создаёт файл базы данных (БД) в папке, в которой находится (можете указать
-	местоположение и имя файла БД в константе "dbFile"). Чтобы первый запуск стал успешным, ознакомьтесь с разделом "Запуск псевдоприложения".
+	местоположение и имя файла БД в настройке db, см. раздел "Настройки"). Чтобы первый запуск стал успешным, ознакомьтесь с разделом "Запуск псевдоприложения".
 	Все задания хранятся в отдельном файле БД, поэтому завершение программы не приведёт к потере записанных задач. Добавить задание можно только для будущего
 	времени - если вводится дата в неправильном формате или указывает на прошедшее время, программа с завидной упёртостью предложит указать корректную дату.
+	Схема БД версионируется (PRAGMA user_version): при каждом запуске к файлу БД по порядку применяются недостающие миграции из migrate.go,
+	а файл, созданный более новой версией программы, открыт не будет.
 
 Управление:
 	Работа с планировщиком строится на основании следующих незамысловатых команд (поддерживаются и короткие варианты по первой букве), вводимых в консоли.
-	create (c)		- при помощи "create" можно добавлять задачу (описание, дата в формате гггг.мм.дд) в базу данных.
-	read (r)		- выводит список всех имеющихся задач, отсортированный по дате, количество одновременно выведенных на экран задач можно изменить в константе "Limit".
-	update (u)		- запрашивает id задачи, которую надо изменить, и предлагает ввести новые значения описания и даты (всё в том же формате гггг.мм.дд).
-	delete (d)		- удаляет задачу по id.
+	Сообщения выводятся на языке из настройки language (см. раздел "Настройки"), тексты на каждом языке собраны в каталоги
+	в messages.go. На русском команды можно вводить и по-русски: создать, показать, изменить, удалить, выполнить, открыть,
+	метка, снять, родитель, перенести, списки, перейти, история, вернуть, напомнить, забыть, удалитьбазу, найти, просроченные,
+	перенестисрок, статистика, далее, назад, страница, корзина, восстановить, копия, выгрузить, загрузить, синхронизировать,
+	настройки, выход (английские работают всегда).
+	create (c)		- при помощи "create" можно добавлять задачу (описание, дата в формате гггг.мм.дд, важность, правило повторения, проект, метки) в базу данных.
+					  К дате можно добавить время: "2026.10.20 15:30". Время вводится и выводится в часовом поясе из настройки
+					  time_zone (имя IANA, по умолчанию - системный пояс), а хранится в БД в UTC, поэтому при смене пояса срок не съезжает.
+					  Задача без времени считается задачей на весь день, её срок - начало дня в этом поясе (у задач из старых БД -
+					  в поясе, заданном при первом запуске новой версии). "create 5" добавляет подзадачу задачи 5.
+					  Вместо даты можно ввести относительную запись: today, tomorrow, friday (ближайшая пятница, в том числе
+					  сегодняшняя), next friday (следующая после сегодняшнего дня), +3d, +2w, +1m, in 3 days, in 2 weeks, in a month,
+					  а также сегодня, завтра, послезавтра, в пятницу, в следующую пятницу, через 3 дня, через неделю, через месяц;
+					  время добавляется так же: "завтра 15:30", "next fri at 9:00". Получившаяся дата выводится для проверки.
+					  Правила повторения: daily - каждый день, weekly mon,thu - по указанным дням недели, monthly 15 - каждый месяц
+					  15-го числа (или в последний день короткого месяца), every 3 days - каждые 3 дня.
+	read (r)		- выводит список всех имеющихся задач, отсортированный по сроку (дата и время), постранично: количество задач на странице можно изменить
+					  в настройке page_size. Задачи выводятся деревом: под задачей с отступом - её подзадачи (по сроку), а у задачи
+					  с подзадачами - сколько из них выполнено: [3/5]. Фильтры применяются и к подзадачам.
+					  В той же строке можно указать фильтры: "read open" - только невыполненные задачи, "read priority" - сначала более важные,
+					  затем по сроку (фильтры можно сочетать). Выполненные задачи отмечены [x], важность - восклицательными знаками.
+					  "read upcoming" (или "read upcoming:14") показывает невыполненные задачи на ближайшие 7 (14) дней вместе
+					  с будущими повторениями повторяющихся задач, ещё не созданные повторения отмечены [~].
+					  Фильтры по меткам и проекту: "read +work -home project:release" - задачи проекта release с меткой work и без метки home.
+					  Просроченные задачи (невыполненные, срок которых прошёл) выделяются красным, если вывод идёт в терминал
+					  и переменная окружения NO_COLOR не задана.
+	overdue			- выводит просроченные задачи текущего списка, самые давние - первыми.
+	reschedule		- переносит сроки просроченных задач текущего списка в одной транзакции: "reschedule 3 5 to tomorrow" -
+					  на указанный день (время суток задач сохраняется, а если в сроке указано время - на этот момент),
+					  "reschedule all by 7" - сдвигает сроки всех просроченных задач на 7 дней ("by -1" - на день назад).
+					  Каждая выбранная задача должна быть просроченной и из текущего списка, а её новый срок - не в прошлом,
+					  иначе не переносится ни одна задача. Без аргументов задачи и срок запрашиваются.
+	update (u)		- запрашивает id задачи, которую надо изменить, и предлагает ввести новые значения описания и срока (всё в том же формате гггг.мм.дд [чч:мм]).
+	delete (d)		- перемещает задачу в корзину ("delete 5"), если задачи с таким id нет - предупреждает об этом.
+					  Если у задачи есть подзадачи, программа спросит, удалить их вместе с ней или поднять на её уровень
+					  (они перейдут к её родительской задаче или станут задачами верхнего уровня).
+	trash			- выводит задачи из корзины с датой удаления. Через trash_days дней (настройка, по умолчанию 30) после удаления задачи удаляются
+					  окончательно (проверяется при каждом запуске программы).
+	restore			- возвращает задачу из корзины: "restore 5" (вместе с подзадачами, удалёнными вместе с ней).
+	backup			- сохраняет резервную копию БД (VACUUM INTO) в папку "backupDir" рядом с файлом БД, имя копии содержит
+					  дату и время. Хранятся backup_keep последних копий (настройка, по умолчанию 5), более старые удаляются. "backup list" - список копий.
+					  "restore backup" восстанавливает БД из самой новой копии ("restore backup <имя файла>" - из указанной): копия
+					  сначала проверяется (PRAGMA integrity_check и версия схемы), а текущая БД сохраняется в ещё одну копию.
+	complete (x)	- отмечает задачу выполненной (id можно указать в той же строке: "complete 5"), запоминается момент выполнения.
+					  Если задача повторяющаяся, сразу создаётся её следующее повторение (не раньше сегодняшнего дня).
+	reopen (o)		- снимает с задачи отметку о выполнении.
+	tag (t)			- добавляет задаче метки: "tag 5 work urgent" (недостающие id и метки будут запрошены).
+	untag			- снимает с задачи метки: "untag 5 urgent".
+	history (h)		- выводит историю изменений задачи: "history 5". Каждое добавление, изменение (описания, срока, важности,
+					  выполнения, повторения, проекта, меток, родительской задачи), удаление в корзину и восстановление
+					  записывается с моментом изменения, а изменившиеся поля - в виде "было -> стало". Номер записи - номер версии
+					  задачи (её состояния после этого изменения). История хранится, пока задача не удалена из корзины окончательно.
+	revert			- возвращает задаче прежнюю версию: "revert 5 3" (без номера выводится история и номер запрашивается).
+					  Возвращаются описание, срок, важность, выполнение, повторение, проект и метки, а список, родительская задача
+					  и корзина не меняются. Возврат тоже записывается в историю, так что его можно отменить.
+	lists			- выводит списки задач (work, home, shopping...) с количеством задач в каждом, текущий отмечен звёздочкой.
+					  Текущий список выводится в приглашении ввести команду: "[work] Enter your command...". create добавляет
+					  задачи в текущий список, а read, search, trash и import работают только с ним (напоминания срабатывают для всех).
+					  "lists new shopping" - новый список, "lists rename shopping groceries" - переименование, "lists delete groceries home"
+					  - удаление, задачи списка (в том числе из корзины) переносятся в home (если список не указан, он будет запрошен).
+					  Задачи, добавленные до появления списков, лежат во входящих (inbox): их можно переименовать, но не удалить.
+	switch			- делает текущим другой список: "switch work". При запуске текущий список - входящие (или из настройки list: "todo --list work").
+	move			- переносит задачу вместе с подзадачами в другой список: "move 5 home". Перенесённая подзадача становится
+					  задачей верхнего уровня, а подзадача всегда добавляется в список своей родительской задачи.
+	parent			- делает задачу подзадачей другой: "parent 7 5", или задачей верхнего уровня: "parent 7 none".
+					  Задачу нельзя сделать подзадачей её собственной подзадачи или задачи из другого списка. Следующее повторение повторяющейся
+					  подзадачи остаётся под той же задачей.
+	remind			- добавляет задаче напоминания: "remind 5 15m 1d" - за 15 минут и за сутки до срока ("0" - в момент срока),
+					  "remind 5" - список напоминаний задачи. Напоминания можно задать и при создании задачи. Для задачи на весь день
+					  они отсчитываются от "allDayRemindHour" часов её дня. Пока программа запущена, напоминания проверяются в фоне
+					  раз в "reminderInterval" и выводятся перед приглашением ввести команду; каждое срабатывает один раз, а после
+					  переноса срока задачи - снова. Следующее повторение повторяющейся задачи получает те же напоминания.
+	unremind		- убирает у задачи напоминания: "unremind 5 15m".
 	basedelete (b)	- удаляет файл БД, запускает ракету на Марс и выпивает цианиду, чтобы вражеской разведке ничего не досталось.
-	search (s)		- выводит задачи, содержащие поисковый запрос. Запросы на кириллице чувствительны к регистру.
+					  Перед удалением сохраняется резервная копия БД (если сохранить её не удалось, БД не удаляется).
+	search (s)		- выводит задачи, содержащие слова поискового запроса (полнотекстовый поиск FTS5): регистр не важен, в том числе
+					  для кириллицы, ё и е не различаются, каждое слово ищется как начало слова ("мол" найдёт "Молоко"), самые
+					  релевантные задачи выводятся первыми, а совпадения выделяются звёздочками. Понимает те же фильтры, что и read.
+	stats			- отчёт по задачам текущего списка: сколько задач открыто, просрочено и выполнено, гистограмма невыполненных задач
+					  (с будущими повторениями) по неделям на ближайший месяц, доля выполненных из задач со сроком в последние 30 дней
+					  и среднее время от добавления задачи до выполнения (момент добавления берётся из истории). "stats json" - в JSON.
+	next (n)		- следующая страница последней выборки read или search.
+	prev (p)		- предыдущая страница.
+	goto (g)		- переход на страницу с указанным номером: "goto 5".
+	export			- выгружает все задачи в файл: "export tasks.json". Формат определяется по расширению (.json, .csv, .ics)
+					  или указывается перед именем файла: "export csv tasks.txt". Файл .ics (задачи VTODO) открывается календарями.
+	import			- загружает задачи из файла тех же форматов: "import tasks.csv". Каждая запись проверяется по тем же правилам,
+					  что и ввод с клавиатуры (дата в формате гггг.мм.дд, важность, повторение, метки), но срок может быть и в прошлом,
+					  чтобы выгруженные просроченные задачи загружались обратно. Записи с ошибками пропускаются с указанием номера
+					  записи и причины. Задача попадает в список из своей записи (недостающий список создаётся), а запись без списка -
+					  в текущий список.
+	sync			- синхронизирует задачи с другим файлом БД в обе стороны: "sync laptop.db". Задачи сопоставляются по постоянному
+					  uuid, задача, изменённая после прошлой синхронизации только в одной БД, переписывается в другую. Задача, изменённая
+					  в обеих, - конфликт: выводится, чем различаются версии, и спрашивается, какую оставить (h - эту, o - другую,
+					  пусто - пропустить до следующей синхронизации), либо конфликты решаются политикой после имени файла:
+					  "sync laptop.db newer" (newer - изменённая позже, local - эта, other - другая, skip - пропустить все).
+					  Списки сопоставляются по имени, напоминания и история остаются в своей БД. Перед записью сохраняются резервные
+					  копии обеих БД. При первой синхронизации задачи, которые уже различаются в копиях одной БД, могут оказаться конфликтами.
+					  Задача в корзине синхронизируется как обычная. Ограничение: об окончательно удалённых из корзины задачах (через
+					  trash_days дней) БД не помнит, поэтому такая задача возвращается при синхронизации, если в другой БД она ещё есть.
+	config			- выводит действующие настройки и откуда взято каждое значение ("config show" - то же самое).
 	exit (e)		- выход из программы.
 
+Настройки:
+	Настройки берутся по слоям, каждый следующий перекрывает предыдущий: значения по умолчанию (константы в main.go), файл настроек
+	~/.config/todo/config (путь можно заменить переменной окружения TODO_CONFIG), переменные окружения и флаги перед командой
+	("todo --page-size 20 list", "todo --db work.db" - интерактивный режим с другой БД). В файле - строки "имя = значение",
+	строки с # пропускаются. Неверное значение (с указанием, откуда оно взято) или неизвестная настройка в файле не дают программе запуститься.
+	db (TODO_DB, --db)							- файл БД, по умолчанию tasksDB.db в текущей папке.
+	page_size (TODO_PAGE_SIZE, --page-size)		- количество задач на странице read, search, trash и todo list, по умолчанию 100.
+	date_format (TODO_DATE_FORMAT, --date-format)	- формат ввода и вывода дат из yyyy, mm и dd с разделителями, например dd.mm.yyyy,
+												  по умолчанию yyyy.mm.dd. Дата в формате yyyy.mm.dd понимается всегда, в нём же даты
+												  хранятся в БД и выгружаются в файлы и в HTTP API.
+	language (TODO_LANG, --lang)				- язык сообщений интерактивного режима: en или ru, по умолчанию - язык локали
+												  из LC_ALL, LC_MESSAGES или LANG ("ru_RU.UTF-8" - ru), а если для него нет
+												  сообщений - en. Неинтерактивный режим и HTTP API всегда отвечают на английском.
+	list (TODO_LIST, --list)					- список задач, с которым начинается работа, по умолчанию - входящие.
+	time_zone (TODO_TIME_ZONE, --time-zone)		- часовой пояс (имя IANA, например Europe/Moscow), по умолчанию - системный.
+	trash_days (TODO_TRASH_DAYS, --trash-days)	- сколько дней задача хранится в корзине до окончательного удаления, по умолчанию 30.
+	backup_keep (TODO_BACKUP_KEEP, --backup-keep)	- сколько последних резервных копий хранится, по умолчанию 5.
+	Действующие настройки выводят команды config и todo config show.
+
+Неинтерактивный режим:
+	Если запустить программу с аргументами, она выполнит одну команду и завершится (удобно для скриптов). Без аргументов запускается
+	интерактивный режим, описанный выше.
+	todo add "Buy milk" --date 2026.10.20	- добавляет задачу и выводит её id (важность задаётся флагом --priority).
+											  --date понимает и относительные записи ("--date tomorrow"), получившийся срок
+											  выводится в stderr.
+	todo list --limit 20 --page 2			- выводит вторую страницу по 20 задач, отсортированных по сроку (по умолчанию страница
+											  из page_size задач). Если есть следующая страница, в stderr выводится подсказка
+											  с курсором "--after ..." для её получения (так же работает search). Флаги --open и
+											  --by-priority работают как фильтры open и priority команды read, а --tag work, --not-tag home
+											  и --project release - как фильтры +work, -home и project:release.
+	todo update 5 --content ... --date ...	- изменяет описание и/или срок задачи (--date "2026.10.20 15:30" - со временем).
+	todo overdue							- выводит просроченные задачи текущего списка.
+	todo reschedule all --by 7				- сдвигает сроки всех просроченных задач на 7 дней или переносит задачи на день
+											  (todo reschedule 3 5 --to tomorrow), всё в одной транзакции, как reschedule выше.
+	todo add ... --parent 5, todo update 7 --parent 5	- добавляет подзадачу задачи 5 или делает ею задачу 7
+											  (--parent none - задача верхнего уровня). todo list выводит задачи деревом, как read.
+	todo complete 5, todo reopen 5			- отмечает задачу выполненной или снова открывает её.
+	todo tag 5 work urgent, todo untag 5 work	- добавляет задаче метки или снимает их.
+	todo remind 5 15m 1d, todo unremind 5 1d	- добавляет задаче напоминания (и выводит их список) или убирает их
+											  (при создании - флагом --remind 15m,1d).
+	todo remind								- выводит напоминания, время которых настало (удобно запускать из cron).
+	todo remind --watch						- проверяет напоминания раз в "reminderInterval" (флаг --interval 1m), пока не нажат Ctrl+C,
+											  и выводит их в stdout или выполняет команду оповещения "reminderCommand" (флаг
+											  --command 'notify-send "$1"'): текст напоминания передаётся в $1, id, описание и срок
+											  задачи - в переменных окружения TODO_TASK_ID, TODO_TASK_CONTENT и TODO_TASK_DUE.
+	todo --list work list					- выполняет команду со списком задач work (без команды - запускает с ним интерактивный режим),
+											  так же задаются и другие настройки (см. раздел "Настройки").
+	todo config show						- выводит действующие настройки и откуда взято каждое значение.
+	todo lists new work, todo move 5 work	- добавляет список (а также lists rename, lists delete NAME --into OTHER) или переносит в него задачу.
+	todo history 5, todo revert 5 3			- выводит историю изменений задачи или возвращает задаче версию 3 из истории.
+	todo rm 5								- перемещает задачу в корзину. У задачи с подзадачами нужно указать, что с ними сделать:
+											  --children delete (в корзину вместе с задачей) или --children reparent (на уровень выше).
+	todo trash, todo restore 5				- выводит корзину или возвращает задачу из неё.
+	todo backup, todo backup list			- сохраняет резервную копию БД или выводит список копий.
+	todo restore backup [FILE]				- восстанавливает БД из самой новой или указанной копии.
+	todo search milk						- выводит задачи, содержащие слова поискового запроса.
+	todo stats [--json]						- выводит отчёт stats (см. выше) по текущему списку, с --json - в JSON.
+	todo export --format ics > tasks.ics	- выгружает задачи в stdout (или в файл флагом --output).
+	todo import tasks.csv					- загружает задачи из файла ("-" - из stdin, формат задаётся флагом --format),
+											  ошибки записей выводятся в stderr, если были ошибки - код завершения 2.
+	todo sync laptop.db --policy newer		- синхронизирует задачи с другим файлом БД (см. sync выше), конфликты решаются
+											  политикой --policy (по умолчанию skip) и выводятся вместе с принятым решением.
+	todo serve --addr 127.0.0.1:8080		- запускает HTTP JSON API (по умолчанию на адресе "serveAddr") поверх той же БД:
+											  GET /tasks - страница задач (параметры limit, after, open, by_priority, tag, not_tag,
+											  project, а q - полнотекстовый поиск), в ответе {"tasks": [...], "next": курсор};
+											  POST /tasks - добавить задачу, GET /tasks/5 - задача, PATCH /tasks/5 - изменить
+											  указанные поля (content, date, due, priority, repeat, project, tags, done, parent, list),
+											  DELETE /tasks/5 - в корзину (у задачи с подзадачами - с параметром children=delete
+											  или children=reparent), GET /tasks?parent=5 - подзадачи задачи 5, GET /tasks?list=work -
+											  задачи списка work (без параметра - всех списков, задача без list добавляется во входящие).
+											  Задачи передаются в том же виде, что и в export json (с id родительской задачи, списком и числом подзадач),
+											  проверяются по тем же правилам, что и ввод с клавиатуры; ошибка возвращается
+											  как {"error": "..."} со статусом 400 (неверные данные), 404 (нет задачи) или 500.
+	todo tui								- полноэкранный режим поверх той же БД: список задач по сроку (прокручивается стрелками,
+											  PgUp/PgDn, Home/End), под ним подробности о выбранной задаче. Клавиши: a - добавить,
+											  e или Enter - изменить (поля вводятся по очереди в нижней строке), x - выполнить или
+											  снова открыть, d - в корзину (с подтверждением), / - поиск (список обновляется по мере
+											  ввода, Esc - сбросить), r - перечитать БД, q или Ctrl+C - выйти.
+	Коды завершения: 0 - успех, 1 - внутренняя ошибка, 2 - неверные аргументы или данные, 3 - задача не найдена.
+	Ошибка выводится в stderr одной строкой "error: ...", полная справка добавляется только к неизвестной команде и неверному флагу.
+
 Запуск псевдоприложения:
-	Для установки драйвера подключения БД необходимо, находясь в папке с фалом main.go, в консоли выполнить команды:
-	1. "go mod init consoleToDoList" (consoleToDoList для примера, введите имя папки, в которой лежит файл с программой);
-	2. "go get modernc.org/sqlite" (для подключения драйвера работы с БД);
-	3. "go mod tidy" (для актуализации всех связей).
-	Они превратят папку с программой в модуль с указанием всех связей (появятся два файла с указанием связей go.mod и go.sum, не удаляйте их).
-	Далее просто запустите программу, например, командой "go run main.go" и следуйте инструкциям в консоли.
+	Программа - модуль Go (go.mod и go.sum лежат рядом с main.go, не удаляйте их) и состоит из нескольких файлов пакета main.
+	Зависимости (драйвер SQLite modernc.org/sqlite, github.com/google/uuid и golang.org/x/term) загружаются командой
+	"go mod download", находясь в папке с программой (go build и go run загружают их и сами).
+	Далее просто запустите программу командой "go run ." (именно папку, а не main.go - иначе остальные файлы пакета не соберутся)
+	или соберите её командой "go build" и следуйте инструкциям в консоли.
 
 Комментарии:
-	Недостатком программы является остановка с помощью panic() при некоторых внутренних ошибках, но доводить до ума и так уже много букв.
+	Команды работают с задачами через интерфейс TaskStore (store.go): основная реализация хранит задачи в SQLite, вторая - в памяти
+	(пригодится для тестов, файл БД при этом не трогается).
+	Ошибки делятся на виды (errors.go): задача не найдена, некорректный ввод и сбой хранилища. Команды возвращают их, интерактивный
+	режим сообщает о них пользователю и продолжает работу, а неинтерактивный - выбирает по виду ошибки код завершения.
+	Программу завершает только ошибка при запуске (например, не удалось открыть файл БД или обновить его схему).
 */
 
 package main
 
 import (
 	"bufio"
-	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"time"
-
-	_ "modernc.org/sqlite"
 )
 
+// сообщения интерактивного режима: ключи каталогов сообщений, сами тексты на каждом языке - в messages.go
 const (
-	welcomeMessage       = "Welcome to the TO DO List CLI app!"                                           // приветствие при запуске программы
-	commandMessage       = "Enter your command (create, read, update, delete, basedelete, search, exit):" // приглашение ввести команду
-	inputContentMessage  = "Enter task content:"                                                          // приглашение ввести описание задачи
-	inputDateMessage     = "Enter task date in format yyyy.mm.dd:"                                        // приглашение ввести дату, на которую запланирована задача
-	updateMassage        = "Enter id task for update:"                                                    // приглашение ввести id задачи для обновления
-	deleteMessage        = "Enter id task for delete:"                                                    // приглашение ввести id задачи для её удаления
-	deleteBaseMessage    = "Database has been deleted. Restart the program."                              // сообщение об удалении БД
-	dateInvTimeMessage   = "Enter correct date:"                                                          // приглашение ввести корректную дату
-	searchMessage        = "Enter search query:"                                                          // приглашение к вводу искомой подстроки
-	byeMessage           = "The program is completed. All data is saved. Good luck!"                      // сообщение при завершении программы
-	errorCommandMessage  = "Invalid command! Please, try again!"                                          // сообщение о неверном вводе команды
-	errorIdUpdateMassage = "Bad id for updating task."                                                    // сообщение о вводе неверного id задачи при обновлении
-	errorPrefix          = "oops, something went wrong, programm is stopped, error: "                     // сообщение об ошибке, приведшей к завершению программы
+	welcomeMessage        message = iota // приветствие при запуске программы
+	commandMessage                       // приглашение ввести команду
+	inputContentMessage                  // приглашение ввести описание задачи
+	inputDateMessage                     // приглашение ввести дату, на которую запланирована задача
+	inputPriorityMessage                 // приглашение ввести важность задачи
+	inputRepeatMessage                   // приглашение ввести правило повторения задачи
+	inputProjectMessage                  // приглашение ввести проект задачи
+	inputTagsMessage                     // приглашение ввести метки задачи
+	updateMassage                        // приглашение ввести id задачи для обновления
+	deleteMessage                        // приглашение ввести id задачи для её удаления
+	restoreMessage                       // приглашение ввести id задачи, возвращаемой из корзины
+	completeMessage                      // приглашение ввести id выполненной задачи
+	reopenMessage                        // приглашение ввести id задачи, которую надо снова открыть
+	tagMessage                           // приглашение ввести id задачи, которой добавляются метки
+	untagMessage                         // приглашение ввести id задачи, с которой снимаются метки
+	parentMessage                        // приглашение ввести id задачи, у которой меняется родительская задача
+	inputParentMessage                   // приглашение ввести id родительской задачи
+	deleteSubtasksMessage                // вопрос, что делать с подзадачами удаляемой задачи
+	notDeletedMessage                    // сообщение об отмене удаления
+	switchMessage                        // приглашение ввести имя списка, который станет текущим
+	newListMessage                       // приглашение ввести имя нового списка
+	renameListMessage                    // приглашение ввести имя переименовываемого списка
+	inputListNameMessage                 // приглашение ввести новое имя списка
+	deleteListMessage                    // приглашение ввести имя удаляемого списка
+	moveTasksMessage                     // вопрос, куда перенести задачи удаляемого списка
+	listNotDeletedMessage                // сообщение об отмене удаления списка
+	moveMessage                          // приглашение ввести id задачи, которая переносится в другой список
+	inputMoveListMessage                 // приглашение ввести имя списка, в который переносится задача
+	historyMessage                       // приглашение ввести id задачи, история которой выводится
+	revertMessage                        // приглашение ввести id задачи, которой возвращается прежняя версия
+	inputVersionMessage                  // приглашение ввести номер версии задачи из истории
+	remindMessage                        // приглашение ввести id задачи, которой добавляются напоминания
+	unremindMessage                      // приглашение ввести id задачи, у которой убираются напоминания
+	inputRemindMessage                   // приглашение ввести напоминания задачи
+	gotoMessage                          // приглашение ввести номер страницы выборки
+	deleteBaseMessage                    // сообщение об удалении БД
+	dateInvTimeMessage                   // приглашение ввести корректную дату
+	resolvedDateMessage                  // подтверждение срока, введённого относительной записью
+	searchMessage                        // приглашение к вводу искомой подстроки
+	exportMessage                        // приглашение ввести имя файла для выгрузки задач
+	importMessage                        // приглашение ввести имя файла для загрузки задач
+	syncMessage                          // приглашение ввести имя файла БД для синхронизации
+	rescheduleMessage                    // приглашение ввести задачи и новый срок для переноса
+	syncChoiceMessage                    // вопрос, какую версию задачи оставить при конфликте синхронизации
+	byeMessage                           // сообщение при завершении программы
+	addedMessage                         // сообщение о добавлении задачи
+	completedMessage                     // сообщение о выполнении задачи
+	nextAddedMessage                     // сообщение о создании следующего повторения задачи
+	reopenedMessage                      // сообщение о том, что задача снова открыта
+	noRemindersMessage                   // сообщение о том, что у задачи нет напоминаний
+	remindersMessage                     // заголовок списка напоминаний задачи
+	noHistoryMessage                     // сообщение о том, что у задачи нет истории
+	historyTitleMessage                  // заголовок истории задачи
+	revertedMessage                      // сообщение о возврате задаче прежней версии
+	switchHintMessage                    // подсказка о смене текущего списка
+	listAddedMessage                     // сообщение о добавлении списка
+	listRenamedMessage                   // сообщение о переименовании списка
+	listDeletedMessage                   // сообщение об удалении пустого списка
+	listMovedMessage                     // сообщение об удалении списка с переносом его задач
+	switchedMessage                      // сообщение о смене текущего списка
+	movedMessage                         // сообщение о переносе задачи в другой список
+	trashedAllMessage                    // сообщение об удалении задачи вместе с подзадачами
+	trashedUpMessage                     // сообщение об удалении задачи с подъёмом подзадач на её уровень
+	trashedMessage                       // сообщение об удалении задачи без подзадач
+	topLevelMessage                      // сообщение о том, что задача стала задачей верхнего уровня
+	subtaskMessage                       // сообщение о том, что задача стала подзадачей
+	trashPurgeMessage                    // подсказка о сроке хранения задач в корзине
+	restoredMessage                      // сообщение о возврате задачи из корзины
+	backupMessage                        // сообщение о сохранении резервной копии
+	backupKeepMessage                    // сообщение о сохранении резервной копии с количеством хранимых копий
+	noBackupsMessage                     // сообщение об отсутствии резервных копий
+	restoredBackupMessage                // сообщение о восстановлении БД из резервной копии
+	exportedMessage                      // сообщение о выгрузке задач
+	skippedMessage                       // сообщение о пропущенной при загрузке записи
+	importedMessage                      // итог загрузки задач
+	syncConflictMessage                  // описание конфликта синхронизации
+	syncedMessage                        // итог синхронизации
+	syncNothingMessage                   // сообщение о том, что синхронизировать нечего
+	noOverdueMessage                     // сообщение об отсутствии просроченных задач
+	rescheduleHintMessage                // подсказка о переносе сроков просроченных задач
+	rescheduledMessage                   // сообщение о переносе сроков задач
+	pageMessage                          // номер страницы выборки и подсказка о листании
+	firstPageMessage                     // номер первой страницы выборки и подсказка о листании
+	lastPageMessage                      // номер последней страницы выборки и подсказка о листании
+	errorCommandMessage                  // сообщение о неверном вводе команды
+	errorIdUpdateMassage                 // сообщение о вводе неверного id задачи при обновлении
+	errorIdMessage                       // сообщение о вводе неверного или несуществующего id задачи
+	errorTrashIdMessage                  // сообщение о вводе id задачи, которой нет в корзине
+	errorNoPagesMessage                  // сообщение о листании до первой выборки
+	errorPageMessage                     // сообщение о переходе на несуществующую страницу
+	errorStorageMessage                  // сообщение о сбое хранилища, команда при этом не выполнена
+	errorMessage                         // сообщение об ошибке команды
+	errorPrefix                          // сообщение об ошибке, приведшей к завершению программы
+
+	messageCount // количество сообщений, размер каталога
 )
 
 const (
-	dbFile      = "tasksDB.db" // название файла базы данных
-	Limit       = 100          // количество единовременно выводимых на экран строк с заданиями
-	dateFormfat = "2006.01.02" // формат ввода даты
+	dbFile           = "tasksDB.db"     // название файла базы данных по умолчанию (настройка db, см. config.go)
+	Limit            = 100              // количество строк с заданиями на одной странице выборки по умолчанию (настройка page_size)
+	dateFormfat      = "2006.01.02"     // формат хранения дат в БД и в файлах обмена, формат ввода и вывода по умолчанию (настройка date_format)
+	timeFormat       = "15:04"          // формат ввода времени срока
+	timeZone         = ""               // часовой пояс пользователя по умолчанию (настройка time_zone, имя IANA, например "Europe/Moscow"), пустая строка - системный
+	upcomingDays     = 7                // длина периода по умолчанию для представления upcoming, дней
+	trashDays        = 30               // сколько дней задача хранится в корзине по умолчанию, после этого она удаляется окончательно (настройка trash_days)
+	backupDir        = "backups"        // папка резервных копий БД рядом с файлом БД
+	backupKeep       = 5                // сколько последних резервных копий хранится по умолчанию, более старые удаляются (настройка backup_keep)
+	allDayRemindHour = 9                // от какого часа дня отсчитываются напоминания задач на весь день
+	reminderInterval = 30 * time.Second // как часто проверяются напоминания в интерактивном режиме и в todo remind --watch
+	reminderCommand  = ""               // команда оповещения для todo remind --watch (sh -c, текст напоминания - в $1), пустая - вывод в stdout
+	serveAddr        = "127.0.0.1:8080" // адрес, на котором todo serve по умолчанию принимает запросы HTTP API
 )
 
-// table - схема таблицы БД
-const table = `
-CREATE TABLE dataTask (
-id INTEGER PRIMARY KEY AUTOINCREMENT,
-content TEXT NOT NULL DEFAULT "",
-date CHAR(8) NOT NULL DEFAULT ""
-);
-CREATE INDEX dataTask_date ON dataTask (date);`
-
-// Task описывает структуру задачи
-type Task struct {
-	id      string
-	content string
-	date    string
-}
-
 func main() {
 
-	err := initDB(dbFile)
-	if err != nil {
-		fmt.Println("call error initDB")
-		panic(fmt.Sprint(errorPrefix, err))
-	}
-
-	fmt.Println(welcomeMessage)
-
-	var input string
-
-	for {
-		fmt.Println(commandMessage)
-		input = scanInput()
-
-		switch {
-		case input == "create" || input == "c" || input == "с": // на всякий случай и в кириллице
-			create()
-		case input == "read" || input == "r":
-			read()
-		case input == "update" || input == "u":
-			update()
-		case input == "delete" || input == "d":
-			delTask()
-		case input == "basedelete" || input == "b":
-			basedelete()
-			return
-		case input == "search" || input == "s":
-			search()
-		case input == "exit" || input == "e":
-			fmt.Println(byeMessage)
-			return
-		default:
-			fmt.Println(errorCommandMessage)
-		}
-	}
-
-}
-
-// scanInput сканирует введённые данные
-func scanInput() string {
-
-	scanner := bufio.NewScanner(os.Stdin)
-	scanner.Scan()
-	err := scanner.Err()
-	if err != nil {
-		panic(fmt.Sprint(errorPrefix, err))
-	}
-
-	return scanner.Text()
-}
-
-// initDB проверяет наличие и создаёт БД, если её нет
-func initDB(dbFile string) error {
-
-	_, err := os.Stat(dbFile)
-	var install bool
-	if err != nil {
-		install = true
-	}
-
-	db, err := sql.Open("sqlite", dbFile)
-	if err != nil {
-		fmt.Printf("opening error %s: ", dbFile)
-		return err
-	}
-	defer db.Close()
-
-	if install {
-		_, err = db.Exec(table)
-		if err != nil {
-			fmt.Printf("error creating a table in %v or adding an index: ", dbFile)
-			return err
-		}
+	cfg, args, err := loadConfig(os.Args[1:], os.Getenv)
+	if errors.Is(err, errInvalidInput) {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
 	}
-
-	return nil
-}
-
-// checkDate проверяет корректность введённой даты
-func checkDate(in string) bool {
-
-	now := time.Now()
-
-	date, err := time.Parse(dateFormfat, in)
 	if err != nil {
-		fmt.Printf("error: %v\n", err)
-		return false
-	}
-
-	if !date.After(now) && (now.Format(dateFormfat) != in) {
-		fmt.Println(dateInvTimeMessage)
-		return false
-	}
-
-	return true
-}
-
-// create добавляет задачу в БД
-func create() {
-
-	var task Task
-
-	fmt.Println(inputContentMessage)
-	task.content = scanInput()
-
-	var quest bool
-	for !quest {
-		fmt.Println(inputDateMessage)
-		task.date = scanInput()
-		quest = checkDate(task.date)
+		fmt.Fprintf(os.Stderr, "%s%v\n", errorPrefix, err)
+		os.Exit(exitError)
 	}
 
-	db, err := sql.Open("sqlite", dbFile)
+	loc, err := cfg.apply()
 	if err != nil {
-		panic(fmt.Sprint(errorPrefix, err))
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
 	}
-	defer db.Close()
-
-	var id int64
 
-	query := "INSERT INTO dataTask (content, date) VALUES (:content, :date)"
-	res, err := db.Exec(query,
-		sql.Named("content", task.content),
-		sql.Named("date", task.date))
-	if err == nil {
-		id, err = res.LastInsertId()
-		if err != nil {
-			panic(fmt.Sprint(errorPrefix, err))
-		}
-	}
-
-	fmt.Printf("Task with id = %d added.\n", id)
-}
-
-// read выводит список всех задач, отсортированных по дате в максимальном количестве limit на странице
-func read() {
-
-	db, err := sql.Open("sqlite", dbFile)
+	store, err := openSQLiteStore(dbPath, loc)
 	if err != nil {
-		panic(fmt.Sprint(errorPrefix, err))
+		fmt.Fprintf(os.Stderr, "%s%v\n", errorPrefix, err)
+		os.Exit(exitError)
 	}
-	defer db.Close()
-
-	var allTasks []*Task
-	var rows *sql.Rows
 
-	rows, err = db.Query("SELECT * FROM dataTask ORDER BY date LIMIT :limit",
-		sql.Named("limit", Limit))
+	// сбой очистки корзины не мешает работе, задачи будут удалены при следующем запуске
+	_, err = store.Purge(time.Now().AddDate(0, 0, -trashPeriod))
 	if err != nil {
-		panic(fmt.Sprint(errorPrefix, err))
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		task := Task{}
-		err = rows.Scan(&task.id, &task.content, &task.date)
-		if err != nil {
-			panic(fmt.Sprint(errorPrefix, err))
-		}
-		allTasks = append(allTasks, &task)
+	if len(args) > 0 {
+		code := runCLI(store, cfg, args, os.Stdin, os.Stdout, os.Stderr, loc)
+		store.Close()
+		os.Exit(code)
 	}
 
-	if err := rows.Err(); err != nil {
-		panic(fmt.Sprint(errorPrefix, err))
-	}
+	c := newConsole(store, cfg, bufio.NewScanner(os.Stdin), os.Stdout, loc)
+	c.run()
 
-	fmt.Printf("%5s. %10s %v\n", "id", "date", "content")
-	for _, val := range allTasks {
-		fmt.Printf("%5s. %10s %v\n", val.id, val.date, val.content)
-	}
-}
-
-// update позволяет обновить задание по введённому id задачи
-func update() {
-
-	db, err := sql.Open("sqlite", dbFile)
-	if err != nil {
-		panic(fmt.Sprint(errorPrefix, err))
-	}
-	defer db.Close()
-
-	var task Task
-
-	fmt.Println(updateMassage)
-	task.id = scanInput()
-
-	fmt.Println(inputContentMessage)
-	task.content = scanInput()
-
-	var quest bool
-	for !quest {
-		fmt.Println(inputDateMessage)
-		task.date = scanInput()
-		quest = checkDate(task.date)
-	}
-
-	res, err := db.Exec("UPDATE dataTask SET content = :content, date = :date WHERE id = :id",
-		sql.Named("content", &task.content),
-		sql.Named("date", &task.date),
-		sql.Named("id", &task.id))
-	if err != nil {
-		panic(fmt.Sprint(errorPrefix, err))
-	}
-
-	count, err := res.RowsAffected()
-	if err != nil {
-		panic(fmt.Sprint(errorPrefix, err))
-	}
-	if count == 0 {
-		fmt.Println(errorIdUpdateMassage)
-		return
-	}
-}
-
-// delTask удаляет задачу по введённоу id
-func delTask() {
-
-	db, err := sql.Open("sqlite", dbFile)
-	if err != nil {
-		panic(fmt.Sprint(errorPrefix, err))
-	}
-	defer db.Close()
-
-	var task Task
-
-	fmt.Println(deleteMessage)
-	task.id = scanInput()
-
-	_, err = db.Exec("DELETE FROM dataTask WHERE id = :id",
-		sql.Named("id", &task.id))
-	if err != nil {
-		panic(fmt.Sprint(errorPrefix, err))
-	}
-}
-
-// basedelete удаляет файл базы данных и запускает ракету к Марсу
-func basedelete() {
-
-	_, err := os.Stat(dbFile)
-	if err != nil {
-		panic(fmt.Sprint(errorPrefix, err))
-	}
-
-	err = os.Remove(dbFile)
-	if err != nil {
-		panic(fmt.Sprint(errorPrefix, err))
-	}
-
-	fmt.Println(deleteBaseMessage)
-}
-
-// search позволяет найти задачи, в описании или дате которых, есть введённая подстрока
-func search() {
-
-	db, err := sql.Open("sqlite", dbFile)
-	if err != nil {
-		panic(fmt.Sprint(errorPrefix, err))
-	}
-	defer db.Close()
-
-	fmt.Println(searchMessage)
-	searching := scanInput()
-	searching = "%" + searching + "%"
-
-	var allTasks []*Task
-	var rows *sql.Rows
-
-	rows, err = db.Query("SELECT * FROM dataTask WHERE content LIKE :searching OR date LIKE :searching ORDER BY date LIMIT :limit",
-		sql.Named("searching", searching),
-		sql.Named("limit", Limit))
-	if err != nil {
-		panic(fmt.Sprint(errorPrefix, err))
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		task := Task{}
-		err = rows.Scan(&task.id, &task.content, &task.date)
-		if err != nil {
-			panic(fmt.Sprint(errorPrefix, err))
-		}
-		allTasks = append(allTasks, &task)
-	}
-
-	if err := rows.Err(); err != nil {
-		panic(fmt.Sprint(errorPrefix, err))
-	}
-
-	fmt.Printf("%5s. %10s %v\n", "id", "date", "content")
-	for _, val := range allTasks {
-		fmt.Printf("%5s. %10s %v\n", val.id, val.date, val.content)
-	}
+	// после восстановления из резервной копии консоль работает уже с другим хранилищем
+	c.store.Close()
 }
@@ -0,0 +1,266 @@
+package main
+
+import "strings"
+
+// message - ключ сообщения интерактивного режима (см. константы в main.go), текст сообщения на каждом языке
+// берётся из каталога этого языка
+type message int
+
+// catalogs - каталоги сообщений по языкам (настройка language), индекс - ключ сообщения
+var catalogs = map[string]*[messageCount]string{
+	"en": &messagesEn,
+	"ru": &messagesRu,
+}
+
+// String возвращает текст сообщения на языке language; если в каталоге языка сообщения нет - на языке по умолчанию
+func (m message) String() string {
+
+	if text := catalogs[language][m]; text != "" {
+		return text
+	}
+
+	return catalogs[defaultLanguage][m]
+}
+
+// localCommand переводит команду интерактивного режима, введённую на языке language, в английскую;
+// английские команды (и всё остальное) возвращаются как есть
+func localCommand(word string) string {
+
+	if command, ok := commandAliases[language][strings.ToLower(word)]; ok {
+		return command
+	}
+
+	return word
+}
+
+// commandAliases - команды интерактивного режима на языках сообщений: слово -> английская команда
+var commandAliases = map[string]map[string]string{
+	"ru": {
+		"создать":          "create",
+		"показать":         "read",
+		"изменить":         "update",
+		"удалить":          "delete",
+		"выполнить":        "complete",
+		"открыть":          "reopen",
+		"метка":            "tag",
+		"снять":            "untag",
+		"родитель":         "parent",
+		"перенести":        "move",
+		"списки":           "lists",
+		"перейти":          "switch",
+		"история":          "history",
+		"вернуть":          "revert",
+		"напомнить":        "remind",
+		"забыть":           "unremind",
+		"удалитьбазу":      "basedelete",
+		"найти":            "search",
+		"просроченные":     "overdue",
+		"перенестисрок":    "reschedule",
+		"статистика":       "stats",
+		"далее":            "next",
+		"назад":            "prev",
+		"страница":         "goto",
+		"корзина":          "trash",
+		"восстановить":     "restore",
+		"копия":            "backup",
+		"выгрузить":        "export",
+		"загрузить":        "import",
+		"синхронизировать": "sync",
+		"настройки":        "config",
+		"выход":            "exit",
+	},
+}
+
+// messagesEn - каталог сообщений на английском
+var messagesEn = [messageCount]string{
+	welcomeMessage:        "Welcome to the TO DO List CLI app!",
+	commandMessage:        "Enter your command (create, read, update, overdue, reschedule, delete, complete, reopen, tag, untag, parent, move, lists, switch, history, revert, remind, unremind, basedelete, search, stats, next, prev, goto, trash, restore, backup, export, import, sync, config, exit):",
+	inputContentMessage:   "Enter task content:",
+	inputDateMessage:      "Enter task date: %s or today, tomorrow, next fri, +3d, in 2 weeks, завтра, через неделю; optionally with time hh:mm:",
+	inputPriorityMessage:  "Enter task priority (none, low, medium, high or 0-3), empty to skip:",
+	inputRepeatMessage:    "Enter repeat rule (daily, weekly mon,thu, monthly 15, every 3 days; none to remove), empty to skip:",
+	inputProjectMessage:   "Enter project (single word; none to remove), empty to skip:",
+	inputTagsMessage:      "Enter tags separated by spaces, empty for none:",
+	updateMassage:         "Enter id task for update:",
+	deleteMessage:         "Enter id task for delete:",
+	restoreMessage:        "Enter id task to restore from trash:",
+	completeMessage:       "Enter id task to complete:",
+	reopenMessage:         "Enter id task to reopen:",
+	tagMessage:            "Enter id task to tag:",
+	untagMessage:          "Enter id task to untag:",
+	parentMessage:         "Enter id task to move in the hierarchy:",
+	inputParentMessage:    "Enter id of the parent task, none for a top-level task:",
+	deleteSubtasksMessage: "The task has %d subtasks: delete them too (d), move them up a level (r) or cancel (empty)?",
+	notDeletedMessage:     "The task was not deleted.",
+	switchMessage:         "Enter list name to switch to:",
+	newListMessage:        "Enter name of the new list:",
+	renameListMessage:     "Enter name of the list to rename:",
+	inputListNameMessage:  "Enter new name of the list:",
+	deleteListMessage:     "Enter name of the list to delete:",
+	moveTasksMessage:      "The list has %d tasks and %d in the trash, enter list name to move them to (empty to cancel):",
+	listNotDeletedMessage: "The list was not deleted.",
+	moveMessage:           "Enter id task to move to another list:",
+	inputMoveListMessage:  "Enter list name to move the task to:",
+	historyMessage:        "Enter id task to show history of:",
+	revertMessage:         "Enter id task to revert:",
+	inputVersionMessage:   "Enter version number to revert to:",
+	remindMessage:         "Enter id task to set reminders for:",
+	unremindMessage:       "Enter id task to remove reminders from:",
+	inputRemindMessage:    "Enter reminders before due separated by spaces (15m, 2h, 1d, 0 - at due), empty for none:",
+	gotoMessage:           "Enter page number:",
+	deleteBaseMessage:     "Database has been deleted. Restart the program.",
+	dateInvTimeMessage:    "Enter correct date:",
+	resolvedDateMessage:   "Due date: %s.",
+	searchMessage:         "Enter search query:",
+	exportMessage:         "Enter file name to export to (.json, .csv or .ics):",
+	importMessage:         "Enter file name to import from (.json, .csv or .ics):",
+	syncMessage:           "Enter database file to sync with:",
+	rescheduleMessage:     "Enter tasks and the new date: 3 5 to tomorrow, or all by 7 to shift all overdue tasks by days:",
+	syncChoiceMessage:     "Which version to keep: h - this one, o - the other one, empty - skip until the next sync:",
+	byeMessage:            "The program is completed. All data is saved. Good luck!",
+	addedMessage:          "Task with id = %d added.",
+	completedMessage:      "Task with id = %d completed.",
+	nextAddedMessage:      "Next occurrence added: id = %d, due %s.",
+	reopenedMessage:       "Task with id = %d reopened.",
+	noRemindersMessage:    "Task with id = %d has no reminders, add them like remind %d 15m 1d.",
+	remindersMessage:      "Reminders of task with id = %d:",
+	noHistoryMessage:      "Task with id = %d has no recorded changes.",
+	historyTitleMessage:   "History of task with id = %d, use revert %d N to go back to version N:",
+	revertedMessage:       "Task with id = %d reverted to version %d.",
+	switchHintMessage:     "Use switch NAME to change the current list.",
+	listAddedMessage:      "List %s added, use switch %s to work with it.",
+	listRenamedMessage:    "List %s renamed to %s.",
+	listDeletedMessage:    "List %s deleted.",
+	listMovedMessage:      "List %s deleted, its tasks moved to %s.",
+	switchedMessage:       "Current list is %s now, %d tasks.",
+	movedMessage:          "Task with id = %d moved to list %s with its subtasks.",
+	trashedAllMessage:     "Task with id = %d and its subtasks moved to trash, use restore %d to bring them back.",
+	trashedUpMessage:      "Task with id = %d moved to trash, its subtasks moved up a level. Use restore %d to bring it back.",
+	trashedMessage:        "Task with id = %d moved to trash, use restore %d to bring it back.",
+	topLevelMessage:       "Task with id = %d is a top-level task now.",
+	subtaskMessage:        "Task with id = %d is a subtask of task %d now.",
+	trashPurgeMessage:     "Tasks in trash are purged after %d days.",
+	restoredMessage:       "Task with id = %d restored.",
+	backupMessage:         "Backup saved to %s.",
+	backupKeepMessage:     "Backup saved to %s, the last %d backups are kept.",
+	noBackupsMessage:      "There are no backups yet.",
+	restoredBackupMessage: "Database restored from %s, the previous state is saved to %s.",
+	exportedMessage:       "%d tasks exported to %s.",
+	skippedMessage:        "skipped %v",
+	importedMessage:       "%d tasks imported, %d skipped.",
+	syncConflictMessage:   "Conflict: %s.",
+	syncedMessage:         "Synced with %s: %d tasks written there, %d written here, %d conflicts, %d of them skipped.",
+	syncNothingMessage:    "Nothing to sync, the tasks in %s are the same.",
+	noOverdueMessage:      "There are no overdue tasks.",
+	rescheduleHintMessage: "Use reschedule 3 5 to DATE or reschedule all by N (days) to move them.",
+	rescheduledMessage:    "%d tasks rescheduled:",
+	pageMessage:           "Page %d. Use next (n), prev (p) or goto N (g N).",
+	firstPageMessage:      "Page %d. Use next (n) or goto N (g N) for more.",
+	lastPageMessage:       "Page %d, the last one. Use prev (p) or goto N (g N).",
+	errorCommandMessage:   "Invalid command! Please, try again!",
+	errorIdUpdateMassage:  "Bad id for updating task.",
+	errorIdMessage:        "Task with this id does not exist.",
+	errorTrashIdMessage:   "There is no task with this id in trash.",
+	errorNoPagesMessage:   "Nothing to page through, use read or search first.",
+	errorPageMessage:      "There is no such page.",
+	errorStorageMessage:   "Storage error, the command was not completed: %v",
+	errorMessage:          "error: %v",
+	errorPrefix:           "oops, something went wrong, programm is stopped, error: ",
+}
+
+// messagesRu - каталог сообщений на русском
+var messagesRu = [messageCount]string{
+	welcomeMessage:        "Добро пожаловать в планировщик задач TO DO List!",
+	commandMessage:        "Введите команду (создать, показать, просроченные, перенестисрок, изменить, удалить, выполнить, открыть, метка, снять, родитель, перенести, списки, перейти, история, вернуть, напомнить, забыть, удалитьбазу, найти, статистика, далее, назад, страница, корзина, восстановить, копия, выгрузить, загрузить, синхронизировать, настройки, выход; английские команды тоже работают):",
+	inputContentMessage:   "Введите описание задачи:",
+	inputDateMessage:      "Введите срок задачи: %s или сегодня, завтра, в пятницу, через 3 дня, через неделю, tomorrow, +3d; можно со временем чч:мм:",
+	inputPriorityMessage:  "Введите важность задачи (none, low, medium, high или 0-3), пусто - пропустить:",
+	inputRepeatMessage:    "Введите правило повторения (daily, weekly mon,thu, monthly 15, every 3 days; none - убрать), пусто - пропустить:",
+	inputProjectMessage:   "Введите проект (одно слово; none - убрать), пусто - пропустить:",
+	inputTagsMessage:      "Введите метки через пробел, пусто - без меток:",
+	updateMassage:         "Введите id изменяемой задачи:",
+	deleteMessage:         "Введите id удаляемой задачи:",
+	restoreMessage:        "Введите id задачи, которую надо вернуть из корзины:",
+	completeMessage:       "Введите id выполненной задачи:",
+	reopenMessage:         "Введите id задачи, которую надо снова открыть:",
+	tagMessage:            "Введите id задачи, которой добавляются метки:",
+	untagMessage:          "Введите id задачи, с которой снимаются метки:",
+	parentMessage:         "Введите id задачи, которая переносится в иерархии:",
+	inputParentMessage:    "Введите id родительской задачи, none - задача верхнего уровня:",
+	deleteSubtasksMessage: "У задачи %d подзадач: удалить их тоже (d), поднять на уровень выше (r) или отменить (пусто)?",
+	notDeletedMessage:     "Задача не удалена.",
+	switchMessage:         "Введите имя списка, на который надо перейти:",
+	newListMessage:        "Введите имя нового списка:",
+	renameListMessage:     "Введите имя переименовываемого списка:",
+	inputListNameMessage:  "Введите новое имя списка:",
+	deleteListMessage:     "Введите имя удаляемого списка:",
+	moveTasksMessage:      "В списке %d задач и %d в корзине, введите имя списка, в который их перенести (пусто - отменить):",
+	listNotDeletedMessage: "Список не удалён.",
+	moveMessage:           "Введите id задачи, которая переносится в другой список:",
+	inputMoveListMessage:  "Введите имя списка, в который переносится задача:",
+	historyMessage:        "Введите id задачи, историю которой надо вывести:",
+	revertMessage:         "Введите id задачи, которой возвращается прежняя версия:",
+	inputVersionMessage:   "Введите номер возвращаемой версии:",
+	remindMessage:         "Введите id задачи, которой добавляются напоминания:",
+	unremindMessage:       "Введите id задачи, у которой убираются напоминания:",
+	inputRemindMessage:    "Введите напоминания до срока через пробел (15m, 2h, 1d, 0 - в момент срока), пусто - без напоминаний:",
+	gotoMessage:           "Введите номер страницы:",
+	deleteBaseMessage:     "База данных удалена. Запустите программу заново.",
+	dateInvTimeMessage:    "Введите корректную дату:",
+	resolvedDateMessage:   "Срок: %s.",
+	searchMessage:         "Введите поисковый запрос:",
+	exportMessage:         "Введите имя файла для выгрузки (.json, .csv или .ics):",
+	importMessage:         "Введите имя файла для загрузки (.json, .csv или .ics):",
+	syncMessage:           "Введите имя файла базы данных для синхронизации:",
+	rescheduleMessage:     "Введите задачи и новый срок: 3 5 to завтра, или all by 7, чтобы сдвинуть все просроченные задачи на 7 дней:",
+	syncChoiceMessage:     "Какую версию оставить: h - эту, o - другую, пусто - пропустить до следующей синхронизации:",
+	byeMessage:            "Программа завершена. Все данные сохранены. Удачи!",
+	addedMessage:          "Задача с id = %d добавлена.",
+	completedMessage:      "Задача с id = %d выполнена.",
+	nextAddedMessage:      "Добавлено следующее повторение: id = %d, срок %s.",
+	reopenedMessage:       "Задача с id = %d снова открыта.",
+	noRemindersMessage:    "У задачи с id = %d нет напоминаний, добавьте их так: напомнить %d 15m 1d.",
+	remindersMessage:      "Напоминания задачи с id = %d:",
+	noHistoryMessage:      "У задачи с id = %d нет записанных изменений.",
+	historyTitleMessage:   "История задачи с id = %d, вернуть версию N можно командой вернуть %d N:",
+	revertedMessage:       "Задаче с id = %d возвращена версия %d.",
+	switchHintMessage:     "Сменить текущий список можно командой перейти ИМЯ.",
+	listAddedMessage:      "Список %s добавлен, перейти на него можно командой перейти %s.",
+	listRenamedMessage:    "Список %s переименован в %s.",
+	listDeletedMessage:    "Список %s удалён.",
+	listMovedMessage:      "Список %s удалён, его задачи перенесены в %s.",
+	switchedMessage:       "Текущий список - %s, задач: %d.",
+	movedMessage:          "Задача с id = %d перенесена в список %s вместе с подзадачами.",
+	trashedAllMessage:     "Задача с id = %d и её подзадачи перемещены в корзину, вернуть их можно командой восстановить %d.",
+	trashedUpMessage:      "Задача с id = %d перемещена в корзину, её подзадачи подняты на уровень выше. Вернуть её можно командой восстановить %d.",
+	trashedMessage:        "Задача с id = %d перемещена в корзину, вернуть её можно командой восстановить %d.",
+	topLevelMessage:       "Задача с id = %d теперь задача верхнего уровня.",
+	subtaskMessage:        "Задача с id = %d теперь подзадача задачи %d.",
+	trashPurgeMessage:     "Задачи удаляются из корзины окончательно через %d дней.",
+	restoredMessage:       "Задача с id = %d возвращена из корзины.",
+	backupMessage:         "Резервная копия сохранена в %s.",
+	backupKeepMessage:     "Резервная копия сохранена в %s, хранятся %d последних копий.",
+	noBackupsMessage:      "Резервных копий пока нет.",
+	restoredBackupMessage: "База данных восстановлена из %s, прежнее состояние сохранено в %s.",
+	exportedMessage:       "Выгружено задач: %d, файл %s.",
+	skippedMessage:        "пропущено: %v",
+	importedMessage:       "Загружено задач: %d, пропущено: %d.",
+	syncConflictMessage:   "Конфликт: %s.",
+	syncedMessage:         "Синхронизация с %s: записано туда задач: %d, сюда: %d, конфликтов: %d, из них пропущено: %d.",
+	syncNothingMessage:    "Синхронизировать нечего, задачи в %s те же.",
+	noOverdueMessage:      "Просроченных задач нет.",
+	rescheduleHintMessage: "Перенести их сроки: перенестисрок 3 5 to ДАТА или перенестисрок all by N (дней).",
+	rescheduledMessage:    "Перенесены сроки задач (%d):",
+	pageMessage:           "Страница %d. Дальше - далее (n), назад - назад (p), переход - страница N (g N).",
+	firstPageMessage:      "Страница %d. Дальше - далее (n) или страница N (g N).",
+	lastPageMessage:       "Страница %d, последняя. Назад - назад (p) или страница N (g N).",
+	errorCommandMessage:   "Неверная команда! Попробуйте ещё раз!",
+	errorIdUpdateMassage:  "Неверный id изменяемой задачи.",
+	errorIdMessage:        "Задачи с таким id нет.",
+	errorTrashIdMessage:   "В корзине нет задачи с таким id.",
+	errorNoPagesMessage:   "Листать нечего, сначала выполните показать или найти.",
+	errorPageMessage:      "Такой страницы нет.",
+	errorStorageMessage:   "Сбой хранилища, команда не выполнена: %v",
+	errorMessage:          "ошибка: %v",
+	errorPrefix:           "что-то пошло не так, программа остановлена, ошибка: ",
+}
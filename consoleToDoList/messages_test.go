@@ -0,0 +1,39 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCatalogsComplete(t *testing.T) {
+
+	for lang, catalog := range catalogs {
+		for m := range messageCount {
+			if catalog[m] == "" {
+				t.Errorf("%s: message %d is empty", lang, m)
+			}
+		}
+	}
+}
+
+func TestCommandAliases(t *testing.T) {
+
+	// basedelete работает с файлом БД, поэтому он подменяется несуществующим
+	saved := dbPath
+	dbPath = filepath.Join(t.TempDir(), "missing.db")
+	defer func() { dbPath = saved }()
+
+	unknown := errorCommandMessage.String()
+	for lang, aliases := range commandAliases {
+		if _, ok := catalogs[lang]; !ok {
+			t.Errorf("aliases for %s, which has no message catalog", lang)
+		}
+		for alias, command := range aliases {
+			out := runTestConsole(t, newMemoryStore(), command)
+			if strings.Contains(out, unknown) {
+				t.Errorf("%s: alias %q names command %q, which the interactive mode does not handle", lang, alias, command)
+			}
+		}
+	}
+}
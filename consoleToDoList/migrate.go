@@ -0,0 +1,316 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migration описывает один шаг изменения схемы БД
+type migration struct {
+	name string                                     // краткое описание изменения
+	up   string                                     // SQL, приводящий схему к следующей версии
+	data func(tx *sql.Tx, loc *time.Location) error // изменение данных, которому нужен часовой пояс пользователя (в SQL он неизвестен), nil - не нужно
+}
+
+// migrations - упорядоченный список изменений схемы, версия схемы равна номеру последнего применённого шага (с единицы).
+// Новые шаги добавляются только в конец, уже выпущенные шаги не меняются.
+var migrations = []migration{
+	{
+		name: "create dataTask table",
+		up: `
+CREATE TABLE IF NOT EXISTS dataTask (
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+content TEXT NOT NULL DEFAULT "",
+date CHAR(8) NOT NULL DEFAULT ""
+);
+CREATE INDEX IF NOT EXISTS dataTask_date ON dataTask (date);`,
+	},
+	{
+		name: "add completion status and priority",
+		up: `
+ALTER TABLE dataTask ADD COLUMN done INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE dataTask ADD COLUMN done_at TEXT NOT NULL DEFAULT "";
+ALTER TABLE dataTask ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX dataTask_done_date ON dataTask (done, date);`,
+	},
+	{
+		name: "add recurrence rule",
+		up: `
+ALTER TABLE dataTask ADD COLUMN recur TEXT NOT NULL DEFAULT "";`,
+	},
+	{
+		name: "add tags and projects",
+		up: `
+CREATE TABLE tags (
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+name TEXT NOT NULL UNIQUE
+);
+CREATE TABLE task_tags (
+task_id INTEGER NOT NULL REFERENCES dataTask (id) ON DELETE CASCADE,
+tag_id INTEGER NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+PRIMARY KEY (task_id, tag_id)
+);
+CREATE INDEX task_tags_tag ON task_tags (tag_id);
+ALTER TABLE dataTask ADD COLUMN project TEXT NOT NULL DEFAULT "";
+CREATE INDEX dataTask_project ON dataTask (project);`,
+	},
+	{
+		name: "add full-text index over content and date",
+		up: `
+CREATE VIRTUAL TABLE dataTask_fts USING fts5 (
+content, date,
+content = 'dataTask', content_rowid = 'id',
+tokenize = 'unicode61 remove_diacritics 2'
+);
+INSERT INTO dataTask_fts (dataTask_fts) VALUES ('rebuild');
+CREATE TRIGGER dataTask_fts_insert AFTER INSERT ON dataTask BEGIN
+INSERT INTO dataTask_fts (rowid, content, date) VALUES (new.id, new.content, new.date);
+END;
+CREATE TRIGGER dataTask_fts_delete AFTER DELETE ON dataTask BEGIN
+INSERT INTO dataTask_fts (dataTask_fts, rowid, content, date) VALUES ('delete', old.id, old.content, old.date);
+END;
+CREATE TRIGGER dataTask_fts_update AFTER UPDATE OF content, date ON dataTask BEGIN
+INSERT INTO dataTask_fts (dataTask_fts, rowid, content, date) VALUES ('delete', old.id, old.content, old.date);
+INSERT INTO dataTask_fts (rowid, content, date) VALUES (new.id, new.content, new.date);
+END;`,
+	},
+	{
+		name: "add index for pages sorted by priority",
+		up: `
+CREATE INDEX dataTask_priority_date ON dataTask (priority, date);`,
+	},
+	{
+		name: "add trash",
+		up: `
+ALTER TABLE dataTask ADD COLUMN deleted_at TEXT NOT NULL DEFAULT "";
+CREATE INDEX dataTask_deleted_at ON dataTask (deleted_at);`,
+	},
+	{
+		// у старых задач время не указано, их срок - начало дня (часовой пояс пользователя в SQL неизвестен, берётся UTC,
+		// в пояс пользователя срок переводит шаг "move all-day deadlines of old tasks to the user's time zone")
+		name: "add due time",
+		up: `
+ALTER TABLE dataTask ADD COLUMN due TEXT NOT NULL DEFAULT "";
+ALTER TABLE dataTask ADD COLUMN all_day INTEGER NOT NULL DEFAULT 1;
+UPDATE dataTask SET due = replace(date, '.', '-') || 'T00:00:00Z';
+CREATE INDEX dataTask_due ON dataTask (due);
+CREATE INDEX dataTask_done_due ON dataTask (done, due);
+CREATE INDEX dataTask_priority_due ON dataTask (priority, due);`,
+	},
+	{
+		// напоминание срабатывает один раз, но после переноса срока задачи снова ждёт своего времени
+		name: "add reminders",
+		up: `
+CREATE TABLE reminders (
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+task_id INTEGER NOT NULL REFERENCES dataTask (id) ON DELETE CASCADE,
+offset_minutes INTEGER NOT NULL,
+fired_at TEXT NOT NULL DEFAULT "",
+UNIQUE (task_id, offset_minutes)
+);
+CREATE INDEX reminders_fired_at ON reminders (fired_at);
+CREATE TRIGGER reminders_reset AFTER UPDATE OF due, all_day ON dataTask
+WHEN old.due != new.due OR old.all_day != new.all_day BEGIN
+UPDATE reminders SET fired_at = '' WHERE task_id = new.id;
+END;`,
+	},
+	{
+		// у задачи верхнего уровня parent_id - NULL, иначе внешний ключ не проверить
+		name: "add subtasks",
+		up: `
+ALTER TABLE dataTask ADD COLUMN parent_id INTEGER REFERENCES dataTask (id) ON DELETE SET NULL;
+CREATE INDEX dataTask_parent_id ON dataTask (parent_id);`,
+	},
+	{
+		// состояния задачи до и после изменения - JSON (см. taskState), old_state пустое у записи о создании
+		name: "add task history",
+		up: `
+CREATE TABLE task_history (
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+task_id INTEGER NOT NULL REFERENCES dataTask (id) ON DELETE CASCADE,
+action TEXT NOT NULL,
+changed_at TEXT NOT NULL,
+old_state TEXT NOT NULL DEFAULT "",
+new_state TEXT NOT NULL
+);
+CREATE INDEX task_history_task_id ON task_history (task_id, id);`,
+	},
+	{
+		// все имеющиеся задачи попадают во входящие (список 1). При включённых внешних ключах ALTER TABLE не может
+		// добавить столбец с REFERENCES и непустым значением по умолчанию, поэтому ссылку на список проверяет хранилище.
+		name: "add task lists",
+		up: `
+CREATE TABLE lists (
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+name TEXT NOT NULL UNIQUE COLLATE NOCASE
+);
+INSERT INTO lists (id, name) VALUES (1, 'inbox');
+ALTER TABLE dataTask ADD COLUMN list_id INTEGER NOT NULL DEFAULT 1;
+CREATE INDEX dataTask_list_id_due ON dataTask (list_id, due);`,
+	},
+	{
+		// задачи получают постоянные uuid (версии 4) и момент последнего изменения - по истории, а без неё - момент миграции;
+		// replica - идентификатор самой БД, sync_peers - когда она синхронизировалась с другими БД
+		name: "add sync identifiers",
+		up: `
+ALTER TABLE dataTask ADD COLUMN uuid TEXT NOT NULL DEFAULT "";
+ALTER TABLE dataTask ADD COLUMN updated_at TEXT NOT NULL DEFAULT "";
+UPDATE dataTask SET uuid = lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' ||
+substr('89ab', 1 + (random() & 3), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)));
+UPDATE dataTask SET updated_at = coalesce((SELECT max(changed_at) FROM task_history WHERE task_history.task_id = dataTask.id),
+strftime('%Y-%m-%dT%H:%M:%SZ', 'now'));
+CREATE UNIQUE INDEX dataTask_uuid ON dataTask (uuid);
+CREATE TABLE replica (
+id TEXT NOT NULL
+);
+INSERT INTO replica (id) VALUES (lower(hex(randomblob(16))));
+CREATE TABLE sync_peers (
+replica TEXT PRIMARY KEY,
+synced_at TEXT NOT NULL
+);`,
+	},
+	{
+		// срок задачи на весь день - полночь её дня в часовом поясе пользователя, как его записывает dayDeadline;
+		// шаг "add due time" записал старым задачам полночь UTC, здесь она переводится в пояс пользователя
+		name: "move all-day deadlines of old tasks to the user's time zone",
+		data: localizeAllDay,
+	},
+	{
+		// при поиске ё и е - одна буква, поэтому в индекс записывается текст с е вместо ё. Число и границы слов от этого
+		// не меняются, и snippet выделяет совпадения в исходном тексте задачи. Индекс строится заново не через rebuild:
+		// тот взял бы текст из dataTask как есть.
+		name: "fold ё into е in the full-text index",
+		up: `
+DROP TRIGGER dataTask_fts_insert;
+DROP TRIGGER dataTask_fts_delete;
+DROP TRIGGER dataTask_fts_update;
+INSERT INTO dataTask_fts (dataTask_fts) VALUES ('delete-all');
+INSERT INTO dataTask_fts (rowid, content, date) SELECT id, replace(replace(content, 'ё', 'е'), 'Ё', 'Е'), date FROM dataTask;
+CREATE TRIGGER dataTask_fts_insert AFTER INSERT ON dataTask BEGIN
+INSERT INTO dataTask_fts (rowid, content, date) VALUES (new.id, replace(replace(new.content, 'ё', 'е'), 'Ё', 'Е'), new.date);
+END;
+CREATE TRIGGER dataTask_fts_delete AFTER DELETE ON dataTask BEGIN
+INSERT INTO dataTask_fts (dataTask_fts, rowid, content, date)
+VALUES ('delete', old.id, replace(replace(old.content, 'ё', 'е'), 'Ё', 'Е'), old.date);
+END;
+CREATE TRIGGER dataTask_fts_update AFTER UPDATE OF content, date ON dataTask BEGIN
+INSERT INTO dataTask_fts (dataTask_fts, rowid, content, date)
+VALUES ('delete', old.id, replace(replace(old.content, 'ё', 'е'), 'Ё', 'Е'), old.date);
+INSERT INTO dataTask_fts (rowid, content, date) VALUES (new.id, replace(replace(new.content, 'ё', 'е'), 'Ё', 'Е'), new.date);
+END;`,
+	},
+}
+
+// schemaVersion возвращает версию схемы, хранящуюся в PRAGMA user_version
+func schemaVersion(db *sql.DB) (int, error) {
+
+	var version int
+	err := db.QueryRow("PRAGMA user_version").Scan(&version)
+
+	return version, err
+}
+
+// migrate применяет к БД недостающие шаги миграций, каждый в своей транзакции; loc - часовой пояс пользователя
+func migrate(db *sql.DB, loc *time.Location) error {
+
+	version, err := schemaVersion(db)
+	if err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+
+	if version > len(migrations) {
+		return fmt.Errorf("database schema version %d is newer than supported version %d, update the program", version, len(migrations))
+	}
+
+	for i := version; i < len(migrations); i++ {
+		err = applyMigration(db, i+1, migrations[i], loc)
+		if err != nil {
+			return fmt.Errorf("migration %d (%s): %w", i+1, migrations[i].name, err)
+		}
+	}
+
+	return nil
+}
+
+// applyMigration выполняет шаг m и записывает новую версию схемы в одной транзакции
+func applyMigration(db *sql.DB, version int, m migration, loc *time.Location) error {
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if m.up != "" {
+		_, err = tx.Exec(m.up)
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.data != nil {
+		err = m.data(tx, loc)
+		if err != nil {
+			return err
+		}
+	}
+
+	// PRAGMA не поддерживает параметры запроса, поэтому версия подставляется в текст
+	_, err = tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// localizeAllDay переводит срок задач на весь день, записанный как полночь UTC их дня, в полночь по часовому поясу loc.
+// Отметки о сработавших напоминаниях при этом сохраняются, хотя триггер reminders_reset и сбрасывает их при смене срока.
+func localizeAllDay(tx *sql.Tx, loc *time.Location) error {
+
+	rows, err := tx.Query("SELECT id, date FROM dataTask WHERE all_day = 1 AND due = replace(date, '.', '-') || 'T00:00:00Z'")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	dues := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var date string
+		err = rows.Scan(&id, &date)
+		if err != nil {
+			return err
+		}
+		day, err := time.Parse(dateFormfat, date)
+		if err != nil {
+			return fmt.Errorf("task %d: bad date %q: %w", id, date, err)
+		}
+		dues[id] = dayDeadline(day, loc).due.Format(dueLayout)
+	}
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("CREATE TEMP TABLE fired AS SELECT id, fired_at FROM reminders WHERE fired_at != ''")
+	if err != nil {
+		return err
+	}
+
+	for id, due := range dues {
+		_, err = tx.Exec("UPDATE dataTask SET due = :due WHERE id = :id",
+			sql.Named("due", due),
+			sql.Named("id", id))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE reminders SET fired_at = (SELECT fired_at FROM fired WHERE fired.id = reminders.id)
+WHERE id IN (SELECT id FROM fired);
+DROP TABLE fired;`)
+
+	return err
+}
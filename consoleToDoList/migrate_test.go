@@ -0,0 +1,247 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// migrationVersion возвращает версию схемы сразу после шага с именем name
+func migrationVersion(t *testing.T, name string) int {
+
+	t.Helper()
+
+	for i, m := range migrations {
+		if m.name == name {
+			return i + 1
+		}
+	}
+	t.Fatalf("there is no migration %q", name)
+
+	return 0
+}
+
+// openTestDB создаёт во временной папке БД со схемой версии version и возвращает путь к файлу и саму БД,
+// БД закрывается в конце теста
+func openTestDB(t *testing.T, version int) (string, *sql.DB) {
+
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for i := range version {
+		err = applyMigration(db, i+1, migrations[i], time.UTC)
+		if err != nil {
+			t.Fatalf("migration %d (%s): %v", i+1, migrations[i].name, err)
+		}
+	}
+
+	return path, db
+}
+
+func TestMigrateAllDayOrder(t *testing.T) {
+
+	day := time.Now().UTC().AddDate(0, 0, 10).Truncate(24 * time.Hour)
+
+	for _, loc := range []*time.Location{time.UTC, time.FixedZone("UTC-5", -5*3600), time.FixedZone("UTC+9", 9*3600)} {
+		t.Run(loc.String(), func(t *testing.T) {
+
+			// задачи из БД, в которой ещё не было времени срока
+			path, db := openTestDB(t, migrationVersion(t, "add trash"))
+			for _, legacy := range []struct{ content, date string }{
+				{"legacy", day.Format(dateFormfat)},
+				{"legacy next day", day.AddDate(0, 0, 1).Format(dateFormfat)},
+			} {
+				_, err := db.Exec("INSERT INTO dataTask (content, date) VALUES (:content, :date)",
+					sql.Named("content", legacy.content),
+					sql.Named("date", legacy.date))
+				if err != nil {
+					t.Fatalf("insert %q: %v", legacy.content, err)
+				}
+			}
+			db.Close()
+
+			store, err := openSQLiteStore(path, loc)
+			if err != nil {
+				t.Fatalf("openSQLiteStore: %v", err)
+			}
+			defer store.Close()
+
+			for _, d := range []struct {
+				content string
+				due     deadline
+			}{
+				{"evening", timedDeadline(day, 23, 30, loc)},
+				{"all day", dayDeadline(day, loc)},
+				{"morning", timedDeadline(day, 0, 30, loc)},
+				{"day before", timedDeadline(day.AddDate(0, 0, -1), 23, 30, loc)},
+			} {
+				task := Task{content: d.content, list: inboxList}
+				d.due.apply(&task)
+				_, err = store.Create(task)
+				if err != nil {
+					t.Fatalf("Create %q: %v", d.content, err)
+				}
+			}
+
+			tasks, err := store.List(ListOptions{})
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			var order []string
+			for _, task := range tasks {
+				order = append(order, task.content)
+			}
+			want := []string{"day before", "legacy", "all day", "morning", "evening", "legacy next day"}
+			if len(order) != len(want) {
+				t.Fatalf("order %q, want %q", order, want)
+			}
+			for i := range want {
+				if order[i] != want[i] {
+					t.Fatalf("order %q, want %q", order, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrateFoldsYoInIndex(t *testing.T) {
+
+	// задача добавлена, пока индекс хранил ё как есть
+	path, db := openTestDB(t, migrationVersion(t, "fold ё into е in the full-text index")-1)
+	_, err := db.Exec("INSERT INTO dataTask (content, date, due) VALUES ('купить ёлку', '2030.01.01', '2030-01-01T00:00:00Z')")
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	db.Close()
+
+	store, err := openSQLiteStore(path, time.UTC)
+	if err != nil {
+		t.Fatalf("openSQLiteStore: %v", err)
+	}
+	defer store.Close()
+
+	tasks, err := store.Search("елку", ListOptions{})
+	if err != nil || len(tasks) != 1 || tasks[0].snippet != "купить *ёлку*" {
+		t.Errorf("Search: %+v, %v", tasks, err)
+	}
+}
+
+func TestMigrateEmpty(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	store, err := openSQLiteStore(path, time.UTC)
+	if err != nil {
+		t.Fatalf("openSQLiteStore: %v", err)
+	}
+	defer store.Close()
+
+	version, err := schemaVersion(store.db)
+	if err != nil || version != len(migrations) {
+		t.Errorf("schema version %d, %v, want %d", version, err, len(migrations))
+	}
+	id := createTestTask(t, store, "first", 1)
+	if task, err := store.Get(id); err != nil || task.content != "first" || task.list != inboxList {
+		t.Errorf("Get: %+v, %v", task, err)
+	}
+}
+
+func TestMigrateBaseline(t *testing.T) {
+
+	// схема, с которой программа работала до миграций: user_version не задан
+	path, db := openTestDB(t, 0)
+	_, err := db.Exec(`CREATE TABLE dataTask (id INTEGER PRIMARY KEY AUTOINCREMENT, content TEXT NOT NULL DEFAULT "", date CHAR(8) NOT NULL DEFAULT "");
+INSERT INTO dataTask (content, date) VALUES ('buy milk', '2030.01.02'), ('call mom', '2030.01.01');`)
+	if err != nil {
+		t.Fatalf("baseline schema: %v", err)
+	}
+	db.Close()
+
+	loc := time.FixedZone("UTC+3", 3*3600)
+	store, err := openSQLiteStore(path, loc)
+	if err != nil {
+		t.Fatalf("openSQLiteStore: %v", err)
+	}
+	defer store.Close()
+
+	tasks, err := store.List(ListOptions{})
+	if err != nil || len(tasks) != 2 {
+		t.Fatalf("List: %d tasks, %v", len(tasks), err)
+	}
+	seen := map[string]bool{}
+	for _, task := range tasks {
+		day, _ := time.Parse(dateFormfat, task.date)
+		if !task.allDay || !task.due.Equal(dayDeadline(day, loc).due) || task.list != inboxList || task.done {
+			t.Errorf("migrated task %+v", task)
+		}
+		if task.uuid == "" || seen[task.uuid] {
+			t.Errorf("task %d has uuid %q", task.id, task.uuid)
+		}
+		seen[task.uuid] = true
+	}
+	if tasks[0].content != "call mom" {
+		t.Errorf("first task %q, want the earliest one", tasks[0].content)
+	}
+	if found, _ := store.Search("milk", ListOptions{}); len(found) != 1 {
+		t.Errorf("search over migrated tasks found %d tasks, want 1", len(found))
+	}
+}
+
+func TestMigrateReopen(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	store, err := openSQLiteStore(path, time.UTC)
+	if err != nil {
+		t.Fatalf("openSQLiteStore: %v", err)
+	}
+	id := createTestTask(t, store, "kept", 1)
+	replica, _ := store.Replica()
+	store.Close()
+
+	// повторное открытие не применяет шаги заново и не трогает данные
+	store, err = openSQLiteStore(path, time.UTC)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer store.Close()
+
+	if version, _ := schemaVersion(store.db); version != len(migrations) {
+		t.Errorf("schema version %d, want %d", version, len(migrations))
+	}
+	if got, _ := store.Replica(); got != replica {
+		t.Errorf("replica changed from %s to %s", replica, got)
+	}
+	if task, err := store.Get(id); err != nil || task.content != "kept" {
+		t.Errorf("Get: %+v, %v", task, err)
+	}
+	if changes, _ := store.History(id); len(changes) != 1 {
+		t.Errorf("history has %d entries, want 1", len(changes))
+	}
+}
+
+func TestMigrateNewerVersion(t *testing.T) {
+
+	path, db := openTestDB(t, len(migrations))
+	_, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", len(migrations)+1))
+	if err != nil {
+		t.Fatalf("PRAGMA user_version: %v", err)
+	}
+	db.Close()
+
+	store, err := openSQLiteStore(path, time.UTC)
+	if err == nil {
+		store.Close()
+		t.Fatal("a database with a newer schema was opened")
+	}
+	if !strings.Contains(err.Error(), "newer than supported") {
+		t.Errorf("error %q does not explain the schema version", err)
+	}
+}
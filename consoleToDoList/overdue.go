@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rescheduling - перенос сроков командой reschedule: на день to (с сохранением времени суток задачи) или на момент to,
+// если в нём указано время, либо сдвиг сроков на days дней
+type rescheduling struct {
+	ids  []int64   // выбранные задачи, nil - все просроченные задачи списка
+	to   *deadline // новый срок, nil - сроки сдвигаются на days дней
+	days int
+}
+
+// overdueTasks возвращает невыполненные задачи списка list (пустая строка - всех списков), срок которых прошёл
+// к моменту now, самые давние - первыми
+func overdueTasks(store TaskStore, list string, now time.Time) ([]Task, error) {
+
+	allTasks, err := store.List(ListOptions{
+		OnlyOpen: true,
+		DateTo:   now.Format(dateFormfat),
+		List:     list,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var overdue []Task
+	for _, task := range allTasks {
+		if isOverdue(task, now) {
+			overdue = append(overdue, task)
+		}
+	}
+
+	return overdue, nil
+}
+
+// isOverdue сообщает, что задача не выполнена, а её срок прошёл к моменту now
+func isOverdue(task Task, now time.Time) bool {
+
+	return !task.done && duePassed(task, now)
+}
+
+// duePassed сообщает, что срок задачи прошёл к моменту now: у задачи на весь день - когда закончился её день
+func duePassed(task Task, now time.Time) bool {
+
+	if task.allDay {
+		return task.date < now.Format(dateFormfat)
+	}
+
+	return task.due.Before(now)
+}
+
+// parseSelection разбирает выбор задач для reschedule: all - все просроченные задачи (nil), иначе id через пробел или запятую
+func parseSelection(words []string) ([]int64, error) {
+
+	if len(words) == 1 && strings.EqualFold(words[0], "all") {
+		return nil, nil
+	}
+
+	var ids []int64
+	for _, word := range words {
+		for _, field := range strings.Split(word, ",") {
+			if field == "" {
+				continue
+			}
+			id, err := strconv.ParseInt(field, 10, 64)
+			if err != nil || id < 1 {
+				return nil, invalidInputf("bad task id %q, expected ids or all", field)
+			}
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, invalidInputf("no tasks selected, expected ids or all")
+	}
+
+	return ids, nil
+}
+
+// parseShift разбирает сдвиг сроков в днях: "3", "+3", "-1", "7d"
+func parseShift(in string) (int, error) {
+
+	days, err := strconv.Atoi(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(in)), "d"))
+	if err != nil || days == 0 {
+		return 0, invalidInputf("bad shift %q, expected a non-zero number of days like 3 or -1", in)
+	}
+
+	return days, nil
+}
+
+// parseRescheduling разбирает аргументы reschedule в интерактивном режиме: "3 5 to tomorrow", "all by 7"
+func parseRescheduling(args []string, now time.Time) (rescheduling, error) {
+
+	var r rescheduling
+
+	for i, word := range args {
+		keyword := strings.ToLower(word)
+		if keyword != "to" && keyword != "by" {
+			continue
+		}
+
+		var err error
+		r.ids, err = parseSelection(args[:i])
+		if err != nil {
+			return r, err
+		}
+
+		rest := strings.Join(args[i+1:], " ")
+		if keyword == "by" {
+			r.days, err = parseShift(rest)
+			return r, err
+		}
+		to, err := parseDeadline(rest, now)
+		r.to = &to
+		return r, err
+	}
+
+	return r, invalidInputf("expected tasks and the new date: 3 5 to tomorrow, all by 7")
+}
+
+// apply переносит сроки выбранных задач списка list в одной транзакции и возвращает перенесённые задачи.
+// Новый срок каждой задачи должен быть не в прошлом, иначе не переносится ни одна задача.
+func (r rescheduling) apply(store TaskStore, list string, now time.Time) ([]Task, error) {
+
+	selected, err := r.selected(store, list, now)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := now.Location()
+	for i, task := range selected {
+		var due deadline
+		switch {
+		case r.to != nil && r.to.allDay:
+			day, _ := time.Parse(dateFormfat, r.to.date)
+			due = deadlineOf(task).onDay(day, loc)
+		case r.to != nil:
+			due = *r.to
+		default:
+			day, err := time.Parse(dateFormfat, task.date)
+			if err != nil {
+				return nil, fmt.Errorf("task %d: bad date %q: %w", task.id, task.date, err)
+			}
+			due = deadlineOf(task).onDay(day.AddDate(0, 0, r.days), loc)
+		}
+
+		err = due.checkFuture(now)
+		if errors.Is(err, errPastDate) {
+			return nil, invalidInputf("task %d would be overdue on %s, nothing is rescheduled", task.id, due.describe(loc))
+		}
+		due.apply(&selected[i])
+	}
+
+	if len(selected) == 0 {
+		return nil, nil
+	}
+
+	return selected, store.Reschedule(selected)
+}
+
+// selected возвращает выбранные задачи: по id или все просроченные задачи списка list.
+// Задача, выбранная по id, должна быть просроченной и из списка list, иначе не переносится ни одна задача.
+func (r rescheduling) selected(store TaskStore, list string, now time.Time) ([]Task, error) {
+
+	if r.ids == nil {
+		return overdueTasks(store, list, now)
+	}
+
+	var selected []Task
+	for _, id := range r.ids {
+		task, err := store.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if task.done {
+			return nil, invalidInputf("task %d is done, reopen it first", id)
+		}
+		if list != "" && !strings.EqualFold(task.list, list) {
+			return nil, invalidInputf("task %d is in list %q, not in %q, nothing is rescheduled", id, task.list, list)
+		}
+		if !isOverdue(task, now) {
+			return nil, invalidInputf("task %d is not overdue, change its date with update, nothing is rescheduled", id)
+		}
+		selected = append(selected, task)
+	}
+
+	return selected, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// pager хранит состояние постраничного просмотра выборки read или search.
+// Страницы выбираются по ключу (курсору последней задачи предыдущей страницы), поэтому даже далёкие страницы
+// не требуют от БД пропускать строки через OFFSET.
+type pager struct {
+	fetch  func(opts ListOptions) ([]Task, error) // выполняет выборку: List или Search с запросом
+	opts   ListOptions                            // параметры выборки, opts.Limit - размер страницы
+	starts []*Cursor                              // курсоры начала уже известных страниц, starts[0] - начало выборки
+	page   int                                    // номер текущей страницы с нуля
+}
+
+// newPager создаёт постраничный просмотр выборки, начинающейся после курсора opts.After (nil - с начала)
+func newPager(fetch func(opts ListOptions) ([]Task, error), opts ListOptions) *pager {
+
+	if opts.Limit <= 0 {
+		opts.Limit = pageSize
+	}
+
+	return &pager{
+		fetch:  fetch,
+		opts:   opts,
+		starts: []*Cursor{opts.After},
+	}
+}
+
+// load выбирает уже известную страницу page, запоминает начало следующей, more - есть ли следующая страница
+func (p *pager) load(page int) (allTasks []Task, more bool, err error) {
+
+	opts := p.opts
+	opts.After = p.starts[page]
+	opts.Limit = p.opts.Limit + 1 // лишняя задача показывает, что есть следующая страница
+
+	allTasks, err = p.fetch(opts)
+	if err != nil {
+		return nil, false, err
+	}
+
+	more = len(allTasks) > p.opts.Limit
+	if more {
+		allTasks = allTasks[:p.opts.Limit]
+		if len(p.starts) == page+1 {
+			last := cursorOf(allTasks[len(allTasks)-1])
+			p.starts = append(p.starts, &last)
+		}
+	}
+
+	return allTasks, more, nil
+}
+
+// seek переходит на страницу page (с нуля), по порядку проходя ещё не известные страницы перед ней.
+// ok == false, если такой страницы нет, текущая страница при этом не меняется.
+func (p *pager) seek(page int) (allTasks []Task, more, ok bool, err error) {
+
+	if page < 0 {
+		return nil, false, false, nil
+	}
+
+	for len(p.starts) <= page {
+		_, more, err = p.load(len(p.starts) - 1)
+		if err != nil {
+			return nil, false, false, err
+		}
+		if !more {
+			return nil, false, false, nil
+		}
+	}
+
+	allTasks, more, err = p.load(page)
+	if err != nil {
+		return nil, false, false, err
+	}
+	p.page = page
+
+	return allTasks, more, true, nil
+}
+
+// next возвращает курсор, с которого начнётся следующая страница после текущей
+func (p *pager) next() *Cursor {
+
+	if p.page+1 < len(p.starts) {
+		return p.starts[p.page+1]
+	}
+
+	return nil
+}
+
+// formatCursor записывает курсор в строку для флага --after: срок,id,важность,релевантность
+func formatCursor(c Cursor) string {
+
+	return strings.Join([]string{
+		c.Due,
+		strconv.FormatInt(c.ID, 10),
+		strconv.Itoa(c.Priority),
+		strconv.FormatFloat(c.Rank, 'g', -1, 64),
+	}, ",")
+}
+
+// parseCursor разбирает строку, записанную formatCursor
+func parseCursor(in string) (*Cursor, error) {
+
+	parts := strings.Split(in, ",")
+	if len(parts) != 4 {
+		return nil, invalidInputf("bad cursor %q", in)
+	}
+
+	var c Cursor
+	var err error
+
+	c.Due = parts[0]
+	c.ID, err = strconv.ParseInt(parts[1], 10, 64)
+	if err == nil {
+		c.Priority, err = strconv.Atoi(parts[2])
+	}
+	if err == nil {
+		c.Rank, err = strconv.ParseFloat(parts[3], 64)
+	}
+	if err != nil {
+		return nil, invalidInputf("bad cursor %q", in)
+	}
+
+	return &c, nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// виды правил повторения задачи
+const (
+	repeatDaily   = "daily"   // каждый день
+	repeatWeekly  = "weekly"  // каждую неделю в указанные дни недели
+	repeatMonthly = "monthly" // каждый месяц в указанное число
+	repeatEvery   = "every"   // каждые N дней
+)
+
+// weekdayNames - краткие названия дней недели, индекс совпадает с time.Weekday
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// recurrence описывает правило повторения задачи
+type recurrence struct {
+	kind     string         // вид правила, см. repeatDaily и далее
+	weekdays []time.Weekday // дни недели для weekly, по порядку начиная с воскресенья
+	day      int            // число месяца для monthly (если в месяце меньше дней - последний день месяца)
+	interval int            // количество дней для every
+}
+
+// parseRecurrence разбирает правило повторения.
+// Понимает "daily", "weekly mon,thu", "monthly 15", "every 3 days", а также каноническую запись с двоеточием ("weekly:mon,thu").
+func parseRecurrence(in string) (recurrence, error) {
+
+	var rule recurrence
+
+	in = strings.ToLower(strings.TrimSpace(in))
+	kind, param, _ := strings.Cut(strings.Replace(in, ":", " ", 1), " ")
+	param = strings.TrimSpace(param)
+
+	switch kind {
+	case repeatDaily:
+		if param != "" {
+			return rule, invalidInputf("repeat rule %q: daily takes no parameters", in)
+		}
+		rule.kind = repeatDaily
+
+	case repeatWeekly:
+		seen := make(map[time.Weekday]bool)
+		for _, name := range strings.FieldsFunc(param, func(r rune) bool { return r == ',' || r == ' ' }) {
+			weekday, err := parseWeekday(name)
+			if err != nil {
+				return rule, invalidInputf("repeat rule %q: %w", in, err)
+			}
+			seen[weekday] = true
+		}
+		if len(seen) == 0 {
+			return rule, invalidInputf("repeat rule %q: weekly needs weekdays, e.g. \"weekly mon,thu\"", in)
+		}
+		rule.kind = repeatWeekly
+		for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+			if seen[weekday] {
+				rule.weekdays = append(rule.weekdays, weekday)
+			}
+		}
+
+	case repeatMonthly:
+		day, err := strconv.Atoi(param)
+		if err != nil || day < 1 || day > 31 {
+			return rule, invalidInputf("repeat rule %q: monthly needs a day of month 1-31, e.g. \"monthly 15\"", in)
+		}
+		rule.kind = repeatMonthly
+		rule.day = day
+
+	case repeatEvery:
+		param = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(param, "days"), "day"))
+		interval, err := strconv.Atoi(param)
+		if err != nil || interval < 1 {
+			return rule, invalidInputf("repeat rule %q: every needs a number of days, e.g. \"every 3 days\"", in)
+		}
+		rule.kind = repeatEvery
+		rule.interval = interval
+
+	default:
+		return rule, invalidInputf("bad repeat rule %q, expected daily, weekly <days>, monthly <day> or every <N> days", in)
+	}
+
+	return rule, nil
+}
+
+// parseWeekday разбирает день недели по первым трём буквам английского названия
+func parseWeekday(name string) (time.Weekday, error) {
+
+	if len(name) >= 3 {
+		for i, short := range weekdayNames {
+			if strings.HasPrefix(name, short) {
+				return time.Weekday(i), nil
+			}
+		}
+	}
+
+	return 0, invalidInputf("bad weekday %q", name)
+}
+
+// String возвращает каноническую запись правила, в которой оно хранится в БД
+func (r recurrence) String() string {
+
+	switch r.kind {
+	case repeatWeekly:
+		names := make([]string, len(r.weekdays))
+		for i, weekday := range r.weekdays {
+			names[i] = weekdayNames[weekday]
+		}
+		return repeatWeekly + ":" + strings.Join(names, ",")
+	case repeatMonthly:
+		return repeatMonthly + ":" + strconv.Itoa(r.day)
+	case repeatEvery:
+		return repeatEvery + ":" + strconv.Itoa(r.interval)
+	}
+
+	return r.kind
+}
+
+// next возвращает ближайшую дату повторения строго после даты after
+func (r recurrence) next(after time.Time) time.Time {
+
+	switch r.kind {
+	case repeatWeekly:
+		for i := 1; i <= 7; i++ {
+			date := after.AddDate(0, 0, i)
+			for _, weekday := range r.weekdays {
+				if date.Weekday() == weekday {
+					return date
+				}
+			}
+		}
+	case repeatMonthly:
+		date := monthDay(after.Year(), after.Month(), r.day, after.Location())
+		if !date.After(after) {
+			date = monthDay(after.Year(), after.Month()+1, r.day, after.Location())
+		}
+		return date
+	case repeatEvery:
+		return after.AddDate(0, 0, r.interval)
+	}
+
+	return after.AddDate(0, 0, 1)
+}
+
+// occurrences возвращает даты повторений, начиная с start (она считается первым повторением) и не позже end
+func (r recurrence) occurrences(start, end time.Time) []time.Time {
+
+	var dates []time.Time
+	for date := start; !date.After(end); date = r.next(date) {
+		dates = append(dates, date)
+	}
+
+	return dates
+}
+
+// monthDay возвращает day-е число месяца, а если в месяце столько дней нет - его последний день
+func monthDay(year int, month time.Month, day int, loc *time.Location) time.Time {
+
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, loc).Day()
+	if day > last {
+		day = last
+	}
+
+	return time.Date(year, month, day, 0, 0, 0, 0, loc)
+}
+
+// describeRecurrence возвращает запись правила для вывода пользователю (пустую строку для задачи без повторения)
+func describeRecurrence(recur string) string {
+
+	if recur == "" {
+		return ""
+	}
+
+	description := strings.Replace(recur, ":", " ", 1)
+	if strings.HasPrefix(recur, repeatEvery+":") {
+		description += " days"
+	}
+
+	return "↻ " + description
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dayWords - относительные дни, которые задаются одним словом (или фразой), и их сдвиг от сегодняшнего дня
+var dayWords = map[string]int{
+	"today":              0,
+	"tomorrow":           1,
+	"day after tomorrow": 2,
+	"сегодня":            0,
+	"завтра":             1,
+	"послезавтра":        2,
+}
+
+// periodUnit - единица относительного сдвига даты
+type periodUnit int
+
+const (
+	unitDay periodUnit = iota
+	unitWeek
+	unitMonth
+)
+
+// unitWords - названия единиц сдвига ("+3d", "in 2 weeks", "через 3 дня") во всех формах
+var unitWords = map[string]periodUnit{
+	"d": unitDay, "day": unitDay, "days": unitDay, "д": unitDay, "день": unitDay, "дня": unitDay, "дней": unitDay,
+	"w": unitWeek, "week": unitWeek, "weeks": unitWeek, "н": unitWeek, "неделю": unitWeek, "недели": unitWeek, "недель": unitWeek,
+	"m": unitMonth, "month": unitMonth, "months": unitMonth, "м": unitMonth, "месяц": unitMonth, "месяца": unitMonth, "месяцев": unitMonth,
+}
+
+// russianWeekdays - начала русских названий дней недели (во всех падежах) и их сокращения
+var russianWeekdays = map[string]time.Weekday{
+	"понедельник": time.Monday, "вторник": time.Tuesday, "сред": time.Wednesday, "четверг": time.Thursday,
+	"пятниц": time.Friday, "суббот": time.Saturday, "воскресень": time.Sunday,
+	"пн": time.Monday, "вт": time.Tuesday, "ср": time.Wednesday, "чт": time.Thursday,
+	"пт": time.Friday, "сб": time.Saturday, "вс": time.Sunday,
+}
+
+// parseRelativeDay разбирает относительную запись дня и возвращает день, отсчитанный от today (полночь UTC,
+// как её возвращает time.Parse). Понимает today, tomorrow, friday (ближайшая пятница, в том числе сегодня),
+// next friday (ближайшая пятница после сегодняшнего дня), +3d, +2w, +1m, in 3 days, in a week, in 2 months
+// и русские записи: сегодня, завтра, послезавтра, в пятницу, в следующую пятницу, через 3 дня, через неделю, через месяц.
+// ok == false, если запись не распознана.
+func parseRelativeDay(in string, today time.Time) (day time.Time, ok bool) {
+
+	words := strings.Fields(strings.ToLower(in))
+	phrase := strings.Join(words, " ")
+
+	if shift, ok := dayWords[phrase]; ok {
+		return today.AddDate(0, 0, shift), true
+	}
+
+	// +3d, +2w, +1m
+	if rest, ok := strings.CutPrefix(phrase, "+"); ok {
+		number := strings.TrimRightFunc(rest, func(r rune) bool { return r < '0' || r > '9' })
+		count, err := strconv.Atoi(number)
+		unit, known := unitWords[strings.TrimSpace(rest[len(number):])]
+		if err != nil || !known {
+			return day, false
+		}
+		return shiftDay(today, count, unit), true
+	}
+
+	// in 3 days, in a week, через 3 дня, через неделю
+	if len(words) >= 2 && (words[0] == "in" || words[0] == "через") {
+		count := 1
+		rest := words[1:]
+		if len(rest) == 2 {
+			n, err := strconv.Atoi(rest[0])
+			if rest[0] != "a" && rest[0] != "an" && rest[0] != "one" && err != nil {
+				return day, false
+			}
+			if err == nil {
+				count = n
+			}
+			rest = rest[1:]
+		}
+		unit, known := unitWords[rest[0]]
+		if len(rest) != 1 || !known || count < 0 {
+			return day, false
+		}
+		return shiftDay(today, count, unit), true
+	}
+
+	// friday, next friday, в пятницу, в следующую пятницу
+	if len(words) > 0 && (words[0] == "в" || words[0] == "во" || words[0] == "on") {
+		words = words[1:]
+	}
+	strict := false
+	if len(words) > 0 && (words[0] == "next" || strings.HasPrefix(words[0], "следующ")) {
+		strict = true
+		words = words[1:]
+	}
+	if len(words) != 1 {
+		return day, false
+	}
+	weekday, known := weekdayWord(words[0])
+	if !known {
+		return day, false
+	}
+
+	days := (int(weekday) - int(today.Weekday()) + 7) % 7
+	if days == 0 && strict {
+		days = 7
+	}
+
+	return today.AddDate(0, 0, days), true
+}
+
+// weekdayWord распознаёт день недели по английскому (mon, friday) или русскому (пт, пятницу) названию
+func weekdayWord(word string) (time.Weekday, bool) {
+
+	for i, short := range weekdayNames {
+		if len(word) >= 3 && strings.HasPrefix(word, short) && strings.HasPrefix(time.Weekday(i).String(), strings.ToUpper(word[:1])+word[1:]) {
+			return time.Weekday(i), true
+		}
+	}
+
+	for stem, weekday := range russianWeekdays {
+		if word == stem || len([]rune(stem)) > 2 && strings.HasPrefix(word, stem) {
+			return weekday, true
+		}
+	}
+
+	return 0, false
+}
+
+// shiftDay сдвигает день на count единиц unit. Сдвиг на месяцы не перескакивает через короткий месяц:
+// через месяц после 31 января - последний день февраля.
+func shiftDay(day time.Time, count int, unit periodUnit) time.Time {
+
+	switch unit {
+	case unitWeek:
+		return day.AddDate(0, 0, 7*count)
+	case unitMonth:
+		first := time.Date(day.Year(), day.Month()+time.Month(count), 1, 0, 0, 0, 0, time.UTC)
+		last := first.AddDate(0, 1, -1).Day()
+		return first.AddDate(0, 0, min(day.Day(), last)-1)
+	}
+
+	return day.AddDate(0, 0, count)
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// day - сутки, единица "d" в записи отступа напоминания
+const day = 24 * time.Hour
+
+// parseOffset разбирает отступ напоминания от срока: "15m", "2h", "1d", "1d12h", "0" - в момент срока
+func parseOffset(in string) (time.Duration, error) {
+
+	in = strings.ToLower(strings.TrimSpace(in))
+	bad := invalidInputf("bad reminder %q, expected time before due like 15m, 2h, 1d or 0", in)
+
+	if in == "0" {
+		return 0, nil
+	}
+
+	var offset time.Duration
+	rest := in
+	days, afterDays, hasDays := strings.Cut(in, "d")
+	if hasDays {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, bad
+		}
+		offset = time.Duration(n) * day
+		rest = afterDays
+	}
+
+	if rest != "" {
+		d, err := time.ParseDuration(rest)
+		if err != nil || d < 0 {
+			return 0, bad
+		}
+		offset += d
+	}
+
+	if offset%time.Minute != 0 {
+		return 0, invalidInputf("bad reminder %q, offsets are counted in whole minutes", in)
+	}
+
+	return offset, nil
+}
+
+// parseOffsets разбирает отступы напоминаний, пустые строки пропускаются
+func parseOffsets(in []string) ([]time.Duration, error) {
+
+	var offsets []time.Duration
+
+	for _, s := range in {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		offset, err := parseOffset(s)
+		if err != nil {
+			return nil, err
+		}
+		offsets = append(offsets, offset)
+	}
+
+	return offsets, nil
+}
+
+// formatOffset возвращает отступ в той же записи, которую понимает parseOffset ("1d2h30m")
+func formatOffset(offset time.Duration) string {
+
+	if offset < time.Minute {
+		return "0"
+	}
+
+	var b strings.Builder
+	if days := offset / day; days > 0 {
+		fmt.Fprintf(&b, "%dd", days)
+	}
+	if hours := offset % day / time.Hour; hours > 0 {
+		fmt.Fprintf(&b, "%dh", hours)
+	}
+	if minutes := offset % time.Hour / time.Minute; minutes > 0 {
+		fmt.Fprintf(&b, "%dm", minutes)
+	}
+
+	return b.String()
+}
+
+// at возвращает момент, когда напоминание должно сработать
+func (r Reminder) at() time.Time {
+
+	base := r.task.due
+	if r.task.allDay {
+		base = base.Add(allDayRemindHour * time.Hour)
+	}
+
+	return base.Add(-r.offset)
+}
+
+// formatReminder возвращает текст напоминания для вывода, сроки - в часовом поясе loc
+func formatReminder(r Reminder, now time.Time, loc *time.Location) string {
+
+	text := fmt.Sprintf("Reminder: task %d %q is due %s", r.task.id, r.task.content, formatDue(r.task, loc))
+
+	left := r.task.due.Sub(now).Truncate(time.Minute)
+	switch {
+	case r.task.allDay:
+	case left < 0:
+		text += " (overdue)"
+	case left == 0:
+		text += " (now)"
+	default:
+		text += " (in " + formatOffset(left) + ")"
+	}
+
+	return text + "."
+}
+
+// printReminders выводит напоминания задачи: отступ от срока, момент срабатывания и отметку о сработавших
+func printReminders(w io.Writer, reminders []Reminder, loc *time.Location) {
+
+	for _, r := range reminders {
+		when := formatOffset(r.offset) + " before due"
+		if r.offset == 0 {
+			when = "at due"
+		}
+		fired := ""
+		if !r.firedAt.IsZero() {
+			fired = " (sent)"
+		}
+		fmt.Fprintf(w, "%16s, at %s%s\n", when, r.at().In(loc).Format(dateLayout+" "+timeFormat), fired)
+	}
+}
+
+// fireReminders возвращает напоминания, время которых настало к моменту now, и отмечает их сработавшими,
+// так что каждое напоминание возвращается один раз. Если у задачи настало сразу несколько напоминаний
+// (например, программа долго не запускалась), возвращается только последнее из них.
+func fireReminders(store TaskStore, now time.Time) ([]Reminder, error) {
+
+	due, err := store.DueReminders(now)
+	if err != nil || len(due) == 0 {
+		return nil, err
+	}
+
+	ids := make([]int64, len(due))
+	var latest []Reminder
+	for i, r := range due {
+		ids[i] = r.id
+		// напоминания одной задачи идут подряд, от самого раннего к самому позднему
+		if len(latest) > 0 && latest[len(latest)-1].task.id == r.task.id {
+			latest[len(latest)-1] = r
+			continue
+		}
+		latest = append(latest, r)
+	}
+
+	return latest, store.MarkFired(ids, now)
+}
+
+// watchReminders проверяет напоминания сразу и затем раз в interval, пока не закроется stop.
+// Сработавшие напоминания (или ошибка проверки) передаются в notify, now возвращает текущий момент.
+func watchReminders(store TaskStore, interval time.Duration, now func() time.Time, notify func([]Reminder, error), stop <-chan struct{}) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		due, err := fireReminders(store, now())
+		if err != nil || len(due) > 0 {
+			notify(due, err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reminderWatch - фоновая проверка напоминаний интерактивного режима. Чтобы не печатать поверх вводимой команды,
+// сработавшие напоминания копятся и выводятся циклом команд перед следующим приглашением.
+type reminderWatch struct {
+	mu    sync.Mutex
+	notes []string      // тексты напоминаний, ещё не показанные пользователю
+	stop  chan struct{} // закрывается, чтобы остановить проверку
+	done  chan struct{} // закрывается, когда проверка остановлена
+}
+
+// startReminderWatch запускает проверку напоминаний хранилища store раз в reminderInterval, сроки выводятся в поясе loc
+func startReminderWatch(store TaskStore, loc *time.Location) *reminderWatch {
+
+	w := &reminderWatch{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	now := func() time.Time { return time.Now().In(loc) }
+
+	go func() {
+		defer close(w.done)
+		watchReminders(store, reminderInterval, now, func(due []Reminder, err error) {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			if err != nil {
+				w.notes = append(w.notes, fmt.Sprintf("error: checking reminders: %v", err))
+			}
+			for _, r := range due {
+				w.notes = append(w.notes, formatReminder(r, now(), loc))
+			}
+		}, w.stop)
+	}()
+
+	return w
+}
+
+// take возвращает накопившиеся напоминания и очищает очередь
+func (w *reminderWatch) take() []string {
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	notes := w.notes
+	w.notes = nil
+
+	return notes
+}
+
+// Stop останавливает проверку и дожидается её завершения, после этого хранилище можно закрывать
+func (w *reminderWatch) Stop() {
+
+	close(w.stop)
+	<-w.done
+}
+
+// runReminderCommand выполняет команду оповещения через sh -c: текст напоминания передаётся первым аргументом ($1),
+// а id, описание и срок задачи - в переменных окружения TODO_TASK_ID, TODO_TASK_CONTENT и TODO_TASK_DUE (RFC 3339, UTC)
+func runReminderCommand(command string, r Reminder, text string, stdout, stderr io.Writer) error {
+
+	cmd := exec.Command("sh", "-c", command, "todo-remind", text)
+	cmd.Env = append(os.Environ(),
+		"TODO_TASK_ID="+strconv.FormatInt(r.task.id, 10),
+		"TODO_TASK_CONTENT="+r.task.content,
+		"TODO_TASK_DUE="+r.task.due.UTC().Format(dueLayout))
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("reminder command for task %d: %w", r.task.id, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// параметры отчёта stats
+const (
+	statsWeeks      = 4  // на сколько недель вперёд строится гистограмма задач
+	statsRecentDays = 30 // за сколько последних дней считается доля выполненных задач
+	statsBarWidth   = 40 // длина самого длинного столбца гистограммы, символов
+)
+
+// taskStats - отчёт stats по задачам одного списка (или всех списков), в JSON - как есть
+type taskStats struct {
+	List    string      `json:"list,omitempty"` // список задач, пустая строка - все списки
+	Open    int         `json:"open"`           // невыполненные задачи (вместе с просроченными)
+	Overdue int         `json:"overdue"`        // невыполненные задачи, срок которых прошёл
+	Done    int         `json:"done"`           // выполненные задачи
+	Weeks   []weekStats `json:"weeks"`          // невыполненные задачи по неделям, начиная с сегодняшнего дня
+
+	RecentDue  int      `json:"recent_due"`                // задачи со сроком в последние statsRecentDays дней
+	RecentDone int      `json:"recent_done"`               // сколько из них выполнено
+	Completion *float64 `json:"completion_rate,omitempty"` // RecentDone / RecentDue, nil - таких задач нет
+
+	LeadTasks int           `json:"lead_time_tasks"`           // выполненные задачи, момент добавления которых известен из истории
+	LeadHours *float64      `json:"lead_time_hours,omitempty"` // среднее время от добавления до выполнения, nil - таких задач нет
+	leadTime  time.Duration // то же для вывода
+}
+
+// weekStats - столбец гистограммы: количество невыполненных задач (с будущими повторениями) за неделю
+type weekStats struct {
+	From  string `json:"from"` // первый день недели в формате dateFormfat
+	To    string `json:"to"`   // последний день недели
+	Tasks int    `json:"tasks"`
+}
+
+// collectStats собирает отчёт по задачам списка list (пустая строка - все списки) на момент now.
+// Момент добавления задачи берётся из записи о создании в её истории.
+func collectStats(store TaskStore, list string, now time.Time) (taskStats, error) {
+
+	stats := taskStats{List: list}
+
+	allTasks, err := store.List(ListOptions{List: list})
+	if err != nil {
+		return stats, err
+	}
+
+	recentFrom := now.AddDate(0, 0, -statsRecentDays)
+	var lead time.Duration
+
+	for _, task := range allTasks {
+		if !task.done {
+			stats.Open++
+			if isOverdue(task, now) {
+				stats.Overdue++
+			}
+		} else {
+			stats.Done++
+		}
+
+		if !task.due.Before(recentFrom) && duePassed(task, now) {
+			stats.RecentDue++
+			if task.done {
+				stats.RecentDone++
+			}
+		}
+
+		if !task.done {
+			continue
+		}
+		changes, err := store.History(task.id)
+		if err != nil {
+			return stats, err
+		}
+		if len(changes) > 0 && changes[0].action == actionCreate && !task.doneAt.Before(changes[0].at) {
+			lead += task.doneAt.Sub(changes[0].at)
+			stats.LeadTasks++
+		}
+	}
+
+	if stats.RecentDue > 0 {
+		rate := float64(stats.RecentDone) / float64(stats.RecentDue)
+		stats.Completion = &rate
+	}
+	if stats.LeadTasks > 0 {
+		stats.leadTime = lead / time.Duration(stats.LeadTasks)
+		hours := stats.leadTime.Hours()
+		stats.LeadHours = &hours
+	}
+
+	upcoming, err := upcomingTasks(store, list, now, statsWeeks*7)
+	if err != nil {
+		return stats, err
+	}
+
+	today := dayOf(now)
+	for i := range statsWeeks {
+		from := today.AddDate(0, 0, 7*i)
+		stats.Weeks = append(stats.Weeks, weekStats{
+			From: from.Format(dateFormfat),
+			To:   from.AddDate(0, 0, 6).Format(dateFormfat),
+		})
+	}
+	for _, o := range upcoming {
+		date, err := time.Parse(dateFormfat, o.date)
+		if err != nil {
+			return stats, fmt.Errorf("task %d: bad date %q: %w", o.task.id, o.date, err)
+		}
+		week := int(date.Sub(today) / (7 * day))
+		if week >= 0 && week < len(stats.Weeks) {
+			stats.Weeks[week].Tasks++
+		}
+	}
+
+	return stats, nil
+}
+
+// printStats выводит отчёт: количество задач, гистограмму по неделям, долю выполненных и среднее время выполнения
+func printStats(w io.Writer, stats taskStats) {
+
+	fmt.Fprintf(w, "open %d, overdue %d, done %d\n", stats.Open, stats.Overdue, stats.Done)
+
+	fmt.Fprintln(w, "open tasks by week:")
+	most := 0
+	for _, week := range stats.Weeks {
+		most = max(most, week.Tasks)
+	}
+	for _, week := range stats.Weeks {
+		fmt.Fprintf(w, "  %s - %s %4d", displayDate(week.From), displayDate(week.To), week.Tasks)
+		if week.Tasks > 0 {
+			// непустая неделя получает хотя бы один символ
+			fmt.Fprint(w, " "+strings.Repeat("#", (week.Tasks*statsBarWidth+most-1)/most))
+		}
+		fmt.Fprintln(w)
+	}
+
+	if stats.Completion == nil {
+		fmt.Fprintf(w, "completion rate: no tasks were due in the last %d days\n", statsRecentDays)
+	} else {
+		fmt.Fprintf(w, "completion rate: %.0f%%, %d of %d tasks due in the last %d days are done\n",
+			*stats.Completion*100, stats.RecentDone, stats.RecentDue, statsRecentDays)
+	}
+
+	if stats.LeadHours == nil {
+		fmt.Fprintln(w, "average lead time: no completed tasks with a known creation time")
+	} else {
+		fmt.Fprintf(w, "average lead time: %s from creation to completion over %d tasks\n", formatOffset(stats.leadTime), stats.LeadTasks)
+	}
+}
+
+// writeStatsJSON выводит отчёт в JSON
+func writeStatsJSON(w io.Writer, stats taskStats) error {
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(stats)
+}
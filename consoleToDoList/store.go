@@ -0,0 +1,175 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// Task описывает структуру задачи
+type Task struct {
+	id        int64
+	content   string
+	date      string    // день срока в формате dateFormfat (в часовом поясе пользователя)
+	due       time.Time // момент срока в UTC, для задачи на весь день - начало её дня
+	allDay    bool      // время срока не указано
+	parent    int64     // id родительской задачи, 0 - задача верхнего уровня
+	list      string    // имя списка задач, при добавлении пустое - входящие (inboxList)
+	priority  int       // важность задачи, см. priorityNone и далее
+	done      bool      // задача выполнена
+	doneAt    time.Time // момент выполнения, нулевой для невыполненной задачи
+	recur     string    // правило повторения в канонической записи (см. recurrence), пустое - задача не повторяется
+	project   string    // проект, к которому относится задача, пустая строка - без проекта
+	tags      []string  // метки задачи в алфавитном порядке
+	deletedAt time.Time // момент перемещения в корзину, нулевой для задачи не из корзины
+	uuid      string    // постоянный идентификатор задачи, по нему задачи сопоставляются при синхронизации БД (см. sync.go)
+	updatedAt time.Time // момент последнего изменения задачи (добавления, изменения полей, удаления в корзину...)
+	snippet   string    // фрагмент описания с выделенными совпадениями, заполняется только поиском
+	rank      float64   // релевантность найденной задачи (чем меньше, тем релевантнее), заполняется только поиском
+
+	subtasks     int // количество подзадач (не из корзины), заполняется при выборке
+	subtasksDone int // сколько из них выполнено
+}
+
+// Cursor указывает на последнюю задачу предыдущей страницы: следующая страница начинается сразу после неё
+// в порядке сортировки выборки (постраничная выборка по ключу, без OFFSET)
+type Cursor struct {
+	Priority int     // важность, учитывается при сортировке по важности
+	Rank     float64 // релевантность, учитывается при поиске
+	Due      string  // момент срока в UTC в формате dueLayout
+	ID       int64
+}
+
+// cursorOf возвращает курсор, указывающий на задачу task
+func cursorOf(task Task) Cursor {
+
+	return Cursor{
+		Priority: task.priority,
+		Rank:     task.rank,
+		Due:      task.due.UTC().Format(dueLayout),
+		ID:       task.id,
+	}
+}
+
+// Reminder описывает напоминание о задаче за offset до её срока (для задачи на весь день - до allDayRemindHour её дня)
+type Reminder struct {
+	id      int64
+	task    Task          // задача, о которой надо напомнить
+	offset  time.Duration // за сколько до срока напомнить, кратно минуте
+	firedAt time.Time     // момент срабатывания, нулевой - напоминание ещё не срабатывало
+}
+
+// TaskList описывает именованный список задач (work, home, shopping...). Каждая задача находится ровно в одном списке,
+// подзадачи - в списке своей родительской задачи.
+type TaskList struct {
+	name    string
+	inbox   bool // входящие: список, созданный вместе с БД, его можно переименовать, но не удалить
+	tasks   int  // количество задач в списке (не из корзины), заполняется при выборке
+	trashed int  // количество задач списка в корзине, заполняется при выборке
+}
+
+// inboxList - имя, которое входящие получают при создании БД
+const inboxList = "inbox"
+
+// действия, которые записываются в историю изменений задачи
+const (
+	actionCreate  = "create"  // задача добавлена
+	actionUpdate  = "update"  // изменены поля задачи (описание, срок, метки, выполнение, родительская задача...)
+	actionDelete  = "delete"  // задача перемещена в корзину
+	actionRestore = "restore" // задача возвращена из корзины
+	actionRevert  = "revert"  // задаче возвращено одно из прежних состояний
+	actionSync    = "sync"    // задача заменена её версией из другой БД при синхронизации
+)
+
+// Change - запись истории изменений задачи: состояние до изменения и после него
+type Change struct {
+	version int       // номер изменения в истории задачи с единицы, состояние после него - версия задачи с этим номером
+	action  string    // что произошло, см. actionCreate и далее
+	at      time.Time // момент изменения
+	before  Task      // состояние до изменения, для actionCreate - нулевое
+	after   Task      // состояние после изменения
+}
+
+// dueLayout - формат, в котором момент срока хранится в БД и курсорах: в UTC такие строки сортируются по времени
+const dueLayout = time.RFC3339
+
+// snippetMark обрамляет совпадения с поисковым запросом во фрагменте описания
+const snippetMark = "*"
+
+// yoFolder заменяет ё на е: при поиске это одна и та же буква, так же текст записывается и в полнотекстовый индекс БД
+var yoFolder = strings.NewReplacer("ё", "е", "Ё", "Е")
+
+// ListOptions описывает параметры выборки списка задач
+type ListOptions struct {
+	Limit       int      // максимальное количество задач в выборке, 0 - без ограничения
+	OnlyOpen    bool     // только невыполненные задачи
+	ByPriority  bool     // сначала более важные задачи, а уже потом по дате
+	DateFrom    string   // только задачи не раньше этой даты (в формате dateFormfat), пустая строка - без ограничения
+	DateTo      string   // только задачи не позже этой даты, пустая строка - без ограничения
+	Project     string   // только задачи этого проекта, пустая строка - любые
+	Tags        []string // только задачи, у которых есть все эти метки
+	ExcludeTags []string // только задачи, у которых нет ни одной из этих меток
+	After       *Cursor  // только задачи после этой позиции, nil - с начала
+	Trash       bool     // только задачи из корзины (без флага задачи из корзины не выбираются)
+	TopLevel    bool     // только задачи верхнего уровня (без родительской задачи)
+	Parent      int64    // только подзадачи задачи с этим id, 0 - любые задачи
+	List        string   // только задачи списка с этим именем, пустая строка - задачи всех списков
+}
+
+// TaskEdit описывает изменение задачи одним вызовом Edit: указанные (не nil) части записываются в одной транзакции
+// и одной записью истории задачи, а если какую-то часть записать нельзя, задача не меняется вовсе
+type TaskEdit struct {
+	Fields *Task     // описание, срок, важность, повторение и проект, как у Update
+	Tags   *[]string // новый набор меток целиком
+	List   *string   // список, в который задача переносится с подзадачами (подзадача при этом становится задачей верхнего уровня)
+	Parent *int64    // id родительской задачи (из списка, в котором задача окажется), 0 - задача верхнего уровня
+	Done   *bool     // true - отметить задачу выполненной в момент At, false - снова открыть
+	At     time.Time // момент выполнения
+	Next   *Task     // следующее повторение, добавляемое вместе с выполнением (с напоминаниями задачи, в её списке и под её родительской задачей)
+}
+
+// childPolicy - что делать с подзадачами удаляемой задачи
+type childPolicy int
+
+const (
+	childrenDelete   childPolicy = iota // переместить в корзину вместе с задачей
+	childrenReparent                    // поднять на уровень удаляемой задачи (к её родительской задаче)
+)
+
+// TaskStore описывает хранилище задач, с которым работают команды планировщика.
+// Если задачи с указанным id нет, методы возвращают errNotFound, при сбое самого хранилища - ошибку вида errStorage.
+// Задачи в корзине для всех методов, кроме Restore и Purge (и List с ListOptions.Trash), считаются несуществующими.
+type TaskStore interface {
+	Create(task Task) (int64, error)                            // добавляет задачу вместе с метками и возвращает её id
+	Get(id int64) (Task, error)                                 // возвращает задачу по id
+	List(opts ListOptions) ([]Task, error)                      // возвращает задачи, отсортированные по сроку (или по важности и сроку)
+	Update(task Task) error                                     // обновляет описание, срок, важность, повторение и проект задачи с id task.id
+	Reschedule(tasks []Task) error                              // переносит сроки нескольких задач (date, due и allDay из tasks) в одной транзакции
+	AddTags(id int64, tags []string) error                      // добавляет задаче метки
+	RemoveTags(id int64, tags []string) error                   // снимает с задачи метки
+	SetDone(id int64, done bool, at time.Time) error            // отмечает задачу выполненной в момент at или снова открывает её
+	Complete(id int64, at time.Time, next *Task) (int64, error) // выполняет задачу и добавляет её следующее повторение next (nil - нет) с её напоминаниями в одной транзакции
+	Edit(id int64, edit TaskEdit) (int64, error)                // изменяет задачу по edit в одной транзакции, возвращает id добавленного повторения (0 - не добавлялось)
+	SetParent(id, parent int64) error                           // делает задачу подзадачей задачи parent (0 - задачей верхнего уровня)
+	Delete(id int64, at time.Time, children childPolicy) error  // перемещает задачу в корзину в момент at, с подзадачами - по children
+	Restore(id int64) error                                     // возвращает задачу из корзины вместе с подзадачами, удалёнными вместе с ней
+	Purge(before time.Time) (int, error)                        // окончательно удаляет задачи, попавшие в корзину раньше before
+	Search(query string, opts ListOptions) ([]Task, error)      // возвращает найденные по словам query задачи, самые релевантные - первыми
+	AddReminders(id int64, offsets []time.Duration) error       // добавляет задаче напоминания, уже имеющиеся пропускаются
+	RemoveReminders(id int64, offsets []time.Duration) error    // убирает у задачи напоминания
+	Reminders(id int64) ([]Reminder, error)                     // возвращает напоминания задачи, самые ранние - первыми
+	DueReminders(now time.Time) ([]Reminder, error)             // возвращает несработавшие напоминания открытых задач, время которых настало
+	MarkFired(ids []int64, at time.Time) error                  // отмечает напоминания сработавшими в момент at
+	History(id int64) ([]Change, error)                         // возвращает историю изменений задачи (в том числе из корзины), от старых к новым
+	Revert(id int64, version int) error                         // возвращает задаче состояние её версии version (кроме корзины, списка и родительской задачи)
+	Lists() ([]TaskList, error)                                 // возвращает списки задач, входящие - первыми, остальные - в порядке создания
+	CreateList(name string) error                               // добавляет пустой список, имя не должно быть занято (без учёта регистра)
+	RenameList(name, newName string) error                      // переименовывает список
+	DeleteList(name, into string) error                         // удаляет список, его задачи (и из корзины) переносятся в список into (пустое имя - только пустой список)
+	MoveTask(id int64, list string) error                       // переносит задачу вместе с подзадачами в список list (подзадача при этом становится задачей верхнего уровня)
+	Replica() (string, error)                                   // возвращает идентификатор хранилища, под которым его запоминают другие БД при синхронизации
+	ResetReplica() (string, error)                              // даёт хранилищу новый идентификатор (у копии файла БД он тот же, что у оригинала)
+	SyncedAt(replica string) (time.Time, error)                 // возвращает момент последней синхронизации с хранилищем replica, нулевой - синхронизации не было
+	SetSyncedAt(replica string, at time.Time) error             // запоминает момент синхронизации с хранилищем replica
+	ApplySync(task Task, parent string) error                   // записывает версию задачи из другой БД (добавляет или заменяет задачу с тем же uuid) вместе с моментом изменения; parent - uuid родительской задачи
+	Close() error                                               // освобождает ресурсы хранилища
+}
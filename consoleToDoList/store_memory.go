@@ -0,0 +1,1176 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// проверка на этапе компиляции, что оба хранилища реализуют TaskStore
+var (
+	_ TaskStore = (*memoryStore)(nil)
+	_ TaskStore = (*sqliteStore)(nil)
+)
+
+// memoryStore хранит задачи в памяти, пригодится для тестов и экспериментов
+type memoryStore struct {
+	mu             sync.Mutex
+	nextID         int64
+	tasks          map[int64]Task
+	nextReminderID int64
+	reminders      map[int64]Reminder // напоминания по их id, в Reminder.task заполнен только id задачи
+	history        map[int64][]Change // истории изменений по id задач
+	lists          []TaskList         // списки задач, входящие - первыми, количества задач в них не заполняются
+	replica        string             // идентификатор хранилища для синхронизации
+	syncedAt       map[string]time.Time
+}
+
+// newMemoryStore создаёт пустое хранилище в памяти
+func newMemoryStore() *memoryStore {
+
+	return &memoryStore{
+		nextID:         1,
+		tasks:          make(map[int64]Task),
+		nextReminderID: 1,
+		reminders:      make(map[int64]Reminder),
+		history:        make(map[int64][]Change),
+		lists:          []TaskList{{name: inboxList, inbox: true}},
+		replica:        uuid.NewString(),
+		syncedAt:       make(map[string]time.Time),
+	}
+}
+
+// Create добавляет задачу в хранилище
+func (s *memoryStore) Create(task Task) (int64, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.create(task)
+}
+
+// create добавляет задачу, вызывается под s.mu
+func (s *memoryStore) create(task Task) (int64, error) {
+
+	i, err := s.findList(task.list)
+	if err != nil {
+		return 0, err
+	}
+	task.list = s.lists[i].name
+
+	// подзадача всегда попадает в список своей родительской задачи
+	if task.parent != 0 {
+		parent, ok := s.live(task.parent)
+		if !ok {
+			return 0, errNotFound
+		}
+		task.list = parent.list
+	}
+
+	task.id = s.nextID
+	task.uuid = uuid.NewString()
+	task.tags = mergeTags(nil, task.tags)
+	s.nextID++
+	s.save(task, actionCreate)
+
+	return task.id, nil
+}
+
+// Get возвращает задачу по id
+func (s *memoryStore) Get(id int64) (Task, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.live(id)
+	if !ok {
+		return Task{}, errNotFound
+	}
+
+	return s.withProgress(task), nil
+}
+
+// List возвращает задачи, отсортированные по сроку
+func (s *memoryStore) List(opts ListOptions) ([]Task, error) {
+
+	return pageTasks(s.filter(func(Task) bool { return true }, opts), opts), nil
+}
+
+// Update обновляет описание, срок, важность, повторение и проект задачи
+func (s *memoryStore) Update(task Task) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.live(task.id)
+	if !ok {
+		return errNotFound
+	}
+	if !stored.due.Equal(task.due) || stored.allDay != task.allDay {
+		// как и триггер в БД: после переноса срока напоминания снова ждут своего времени
+		s.resetReminders(task.id)
+	}
+	stored.content = task.content
+	stored.date = task.date
+	stored.due = task.due
+	stored.allDay = task.allDay
+	stored.priority = task.priority
+	stored.recur = task.recur
+	stored.project = task.project
+	s.save(stored, actionUpdate)
+
+	return nil
+}
+
+// Reschedule переносит сроки задач: либо переносятся все, либо (если какой-то задачи нет) ни одна
+func (s *memoryStore) Reschedule(tasks []Task) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, task := range tasks {
+		if _, ok := s.live(task.id); !ok {
+			return errNotFound
+		}
+	}
+
+	for _, task := range tasks {
+		stored, _ := s.live(task.id)
+		if !stored.due.Equal(task.due) || stored.allDay != task.allDay {
+			s.resetReminders(task.id)
+		}
+		stored.date = task.date
+		stored.due = task.due
+		stored.allDay = task.allDay
+		s.save(stored, actionUpdate)
+	}
+
+	return nil
+}
+
+// resetReminders снова включает сработавшие напоминания задачи id, как триггер reminders_reset в БД
+func (s *memoryStore) resetReminders(id int64) {
+
+	for rid, r := range s.reminders {
+		if r.task.id == id {
+			r.firedAt = time.Time{}
+			s.reminders[rid] = r
+		}
+	}
+}
+
+// SetDone отмечает задачу выполненной в момент at или снова открывает её
+func (s *memoryStore) SetDone(id int64, done bool, at time.Time) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.live(id)
+	if !ok {
+		return errNotFound
+	}
+	markDone(&task, done, at)
+	s.save(task, actionUpdate)
+
+	return nil
+}
+
+// Complete отмечает задачу выполненной в момент at и добавляет её следующее повторение next (nil - не добавляет)
+// с напоминаниями задачи: либо всё сразу, либо (если повторение добавить нельзя) ничего
+func (s *memoryStore) Complete(id int64, at time.Time, next *Task) (int64, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.live(id)
+	if !ok {
+		return 0, errNotFound
+	}
+
+	// повторение добавляется первым: это единственный шаг, который может не получиться
+	var nextID int64
+	if next != nil {
+		var err error
+		nextID, err = s.create(*next)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	markDone(&task, true, at)
+	s.save(task, actionUpdate)
+	s.copyReminders(id, nextID)
+
+	return nextID, nil
+}
+
+// copyReminders добавляет следующему повторению to напоминания задачи id с теми же отступами от срока
+// (to == 0 - повторения нет). Вызывается под s.mu.
+func (s *memoryStore) copyReminders(id, to int64) {
+
+	if to == 0 {
+		return
+	}
+
+	var offsets []time.Duration
+	for _, r := range s.reminders {
+		if r.task.id == id {
+			offsets = append(offsets, r.offset)
+		}
+	}
+	s.addReminders(to, offsets)
+}
+
+// Edit применяет к задаче правку edit целиком или, если какая-то её часть неверна, не меняет ничего.
+// В историю задачи записывается одно изменение.
+func (s *memoryStore) Edit(id int64, edit TaskEdit) (int64, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.live(id)
+	if !ok {
+		return 0, errNotFound
+	}
+
+	// всё проверяется до первого изменения, чтобы отклонённая правка ничего не меняла
+	list := task.list
+	if edit.List != nil {
+		i, err := s.findList(*edit.List)
+		if err != nil {
+			return 0, err
+		}
+		list = s.lists[i].name
+		if parent, ok := s.tasks[task.parent]; ok && parent.list != list {
+			task.parent = 0
+		}
+	}
+	if edit.Parent != nil {
+		err := s.checkParent(id, *edit.Parent, list)
+		if err != nil {
+			return 0, err
+		}
+		task.parent = *edit.Parent
+	}
+
+	// повторение добавляется первым: это единственный шаг, который может не получиться
+	var nextID int64
+	if edit.Next != nil {
+		next := *edit.Next
+		next.list = list
+		next.parent = task.parent
+		var err error
+		nextID, err = s.create(next)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if edit.Fields != nil {
+		fields := *edit.Fields
+		if !task.due.Equal(fields.due) || task.allDay != fields.allDay {
+			s.resetReminders(id)
+		}
+		task.content = fields.content
+		task.date = fields.date
+		task.due = fields.due
+		task.allDay = fields.allDay
+		task.priority = fields.priority
+		task.recur = fields.recur
+		task.project = fields.project
+	}
+	if edit.Tags != nil {
+		task.tags = mergeTags(nil, *edit.Tags)
+	}
+	if edit.Done != nil {
+		markDone(&task, *edit.Done, edit.At)
+	}
+	if edit.List != nil {
+		s.setList(task, list)
+	} else {
+		s.save(task, actionUpdate)
+	}
+	s.copyReminders(id, nextID)
+
+	return nextID, nil
+}
+
+// markDone записывает в задачу выполнение в момент at (с точностью до секунды, как в БД) или его отмену
+func markDone(task *Task, done bool, at time.Time) {
+
+	task.done = done
+	task.doneAt = time.Time{}
+	if done {
+		task.doneAt = at.UTC().Truncate(time.Second)
+	}
+}
+
+// AddTags добавляет задаче метки, уже имеющиеся метки пропускаются
+func (s *memoryStore) AddTags(id int64, tags []string) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.live(id)
+	if !ok {
+		return errNotFound
+	}
+	task.tags = mergeTags(task.tags, tags)
+	s.save(task, actionUpdate)
+
+	return nil
+}
+
+// RemoveTags снимает с задачи метки
+func (s *memoryStore) RemoveTags(id int64, tags []string) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.live(id)
+	if !ok {
+		return errNotFound
+	}
+
+	var kept []string
+	for _, tag := range task.tags {
+		if !slices.Contains(tags, tag) {
+			kept = append(kept, tag)
+		}
+	}
+	task.tags = kept
+	s.save(task, actionUpdate)
+
+	return nil
+}
+
+// SetParent делает задачу подзадачей задачи parent (0 - задачей верхнего уровня)
+func (s *memoryStore) SetParent(id, parent int64) error {
+
+	if id == parent {
+		return invalidInputf("task %d cannot be a subtask of itself", id)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.live(id)
+	if !ok {
+		return errNotFound
+	}
+
+	err := s.checkParent(id, parent, task.list)
+	if err != nil {
+		return err
+	}
+
+	task.parent = parent
+	s.save(task, actionUpdate)
+
+	return nil
+}
+
+// checkParent проверяет, что задачу id из списка list можно сделать подзадачей задачи parent. Вызывается под s.mu.
+func (s *memoryStore) checkParent(id, parent int64, list string) error {
+
+	if id == parent {
+		return invalidInputf("task %d cannot be a subtask of itself", id)
+	}
+	if parent == 0 {
+		return nil
+	}
+
+	parentTask, ok := s.live(parent)
+	if !ok {
+		return errNotFound
+	}
+
+	// задача не должна оказаться среди предков своей новой родительской задачи
+	if s.isAncestor(id, parent) {
+		return invalidInputf("task %d cannot become a subtask of its own subtask %d", id, parent)
+	}
+	if parentTask.list != list {
+		return invalidInputf("task %d and task %d are in different lists, move task %d to the list of task %d first", id, parent, id, parent)
+	}
+
+	return nil
+}
+
+// Delete перемещает задачу в корзину в момент at, подзадачи - вместе с ней или к её родительской задаче
+func (s *memoryStore) Delete(id int64, at time.Time, children childPolicy) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.live(id)
+	if !ok {
+		return errNotFound
+	}
+	at = at.UTC().Truncate(time.Second)
+
+	if children == childrenReparent {
+		for _, child := range s.tasks {
+			if child.parent == id && child.deletedAt.IsZero() {
+				child.parent = task.parent
+				s.save(child, actionUpdate)
+			}
+		}
+	}
+
+	s.setDeleted(task, time.Time{}, at, actionDelete)
+
+	return nil
+}
+
+// Restore возвращает задачу из корзины вместе с подзадачами, удалёнными в тот же момент
+func (s *memoryStore) Restore(id int64) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[id]
+	if !ok || task.deletedAt.IsZero() {
+		return errNotFound
+	}
+
+	// если родительская задача в корзине, восстановленная становится задачей верхнего уровня
+	if _, ok := s.live(task.parent); !ok {
+		task.parent = 0
+	}
+	s.setDeleted(task, task.deletedAt, time.Time{}, actionRestore)
+
+	return nil
+}
+
+// Purge окончательно удаляет задачи, попавшие в корзину раньше before
+func (s *memoryStore) Purge(before time.Time) (int, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for id, task := range s.tasks {
+		if !task.deletedAt.IsZero() && task.deletedAt.Before(before) {
+			delete(s.tasks, id)
+			count++
+		}
+	}
+	for id, r := range s.reminders {
+		if _, ok := s.tasks[r.task.id]; !ok {
+			delete(s.reminders, id)
+		}
+	}
+	for id := range s.history {
+		if _, ok := s.tasks[id]; !ok {
+			delete(s.history, id)
+		}
+	}
+	// как ON DELETE SET NULL в БД
+	for id, task := range s.tasks {
+		if _, ok := s.tasks[task.parent]; task.parent != 0 && !ok {
+			task.parent = 0
+			s.tasks[id] = task
+		}
+	}
+
+	return count, nil
+}
+
+// Search ищет задачи так же, как полнотекстовый поиск SQLite: без учёта регистра, каждое слово запроса - начало
+// какого-либо слова описания или даты. Чем больше совпадений, тем выше задача в выдаче.
+func (s *memoryStore) Search(query string, opts ListOptions) ([]Task, error) {
+
+	terms := searchWords(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	hits := make(map[int64]int)
+	match := func(task Task) bool {
+		words := append(searchWords(task.content), searchWords(task.date)...)
+		for _, term := range terms {
+			found := false
+			for _, word := range words {
+				if strings.HasPrefix(word, term) {
+					hits[task.id]++
+					found = true
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+
+	allTasks := s.filter(match, opts)
+	for i := range allTasks {
+		// как и у bm25 в SQLite, чем релевантнее задача, тем меньше rank
+		allTasks[i].rank = -float64(hits[allTasks[i].id])
+	}
+
+	allTasks = pageTasks(allTasks, opts)
+	for i := range allTasks {
+		allTasks[i].snippet = highlight(allTasks[i].content, terms)
+	}
+
+	return allTasks, nil
+}
+
+// AddReminders добавляет задаче напоминания, уже имеющиеся пропускаются
+func (s *memoryStore) AddReminders(id int64, offsets []time.Duration) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.live(id); !ok {
+		return errNotFound
+	}
+	s.addReminders(id, offsets)
+
+	return nil
+}
+
+// addReminders добавляет задаче id напоминания, уже имеющиеся пропускаются; вызывается под s.mu
+func (s *memoryStore) addReminders(id int64, offsets []time.Duration) {
+
+	for _, offset := range offsets {
+		offset = offset.Truncate(time.Minute)
+		if s.findReminder(id, offset) != 0 {
+			continue
+		}
+		s.reminders[s.nextReminderID] = Reminder{id: s.nextReminderID, task: Task{id: id}, offset: offset}
+		s.nextReminderID++
+	}
+}
+
+// RemoveReminders убирает у задачи напоминания
+func (s *memoryStore) RemoveReminders(id int64, offsets []time.Duration) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.live(id); !ok {
+		return errNotFound
+	}
+
+	for _, offset := range offsets {
+		delete(s.reminders, s.findReminder(id, offset.Truncate(time.Minute)))
+	}
+
+	return nil
+}
+
+// Reminders возвращает напоминания задачи, самые ранние (с наибольшим отступом от срока) - первыми
+func (s *memoryStore) Reminders(id int64) ([]Reminder, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.live(id)
+	if !ok {
+		return nil, errNotFound
+	}
+
+	var reminders []Reminder
+	for _, r := range s.reminders {
+		if r.task.id == id {
+			r.task = task
+			reminders = append(reminders, r)
+		}
+	}
+	sort.Slice(reminders, func(i, j int) bool { return reminders[i].offset > reminders[j].offset })
+
+	return reminders, nil
+}
+
+// DueReminders возвращает несработавшие напоминания открытых задач не из корзины, время которых не позже now
+func (s *memoryStore) DueReminders(now time.Time) ([]Reminder, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var reminders []Reminder
+	for _, r := range s.reminders {
+		task, ok := s.live(r.task.id)
+		if !ok || task.done || !r.firedAt.IsZero() {
+			continue
+		}
+		r.task = task
+		if !r.at().After(now) {
+			reminders = append(reminders, r)
+		}
+	}
+	sort.Slice(reminders, func(i, j int) bool {
+		a, b := reminders[i], reminders[j]
+		if !a.task.due.Equal(b.task.due) {
+			return a.task.due.Before(b.task.due)
+		}
+		if a.task.id != b.task.id {
+			return a.task.id < b.task.id
+		}
+		return a.offset > b.offset
+	})
+
+	return reminders, nil
+}
+
+// MarkFired отмечает напоминания сработавшими в момент at
+func (s *memoryStore) MarkFired(ids []int64, at time.Time) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		r, ok := s.reminders[id]
+		if ok {
+			r.firedAt = at.UTC().Truncate(time.Second)
+			s.reminders[id] = r
+		}
+	}
+
+	return nil
+}
+
+// History возвращает историю изменений задачи (в том числе из корзины), от старых к новым
+func (s *memoryStore) History(id int64) ([]Change, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tasks[id]; !ok {
+		return nil, errNotFound
+	}
+
+	return slices.Clone(s.history[id]), nil
+}
+
+// Revert возвращает задаче описание, срок, важность, выполнение, повторение, проект и метки её версии version
+func (s *memoryStore) Revert(id int64, version int) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.live(id)
+	if !ok {
+		return errNotFound
+	}
+
+	changes := s.history[id]
+	if version < 1 || version > len(changes) {
+		return invalidInputf("task %d has no version %d, see its history", id, version)
+	}
+
+	s.save(revertTo(task, changes[version-1].after), actionRevert)
+
+	return nil
+}
+
+// Lists возвращает списки задач с количеством задач в каждом
+func (s *memoryStore) Lists() ([]TaskList, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lists := slices.Clone(s.lists)
+	for i := range lists {
+		for _, task := range s.tasks {
+			switch {
+			case task.list != lists[i].name:
+			case task.deletedAt.IsZero():
+				lists[i].tasks++
+			default:
+				lists[i].trashed++
+			}
+		}
+	}
+
+	return lists, nil
+}
+
+// CreateList добавляет пустой список задач
+func (s *memoryStore) CreateList(name string) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.findList(name); err == nil {
+		return invalidInputf("list %q already exists", name)
+	}
+	s.lists = append(s.lists, TaskList{name: name})
+
+	return nil
+}
+
+// RenameList переименовывает список вместе с именем списка у его задач
+func (s *memoryStore) RenameList(name, newName string) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.findList(name)
+	if err != nil {
+		return err
+	}
+	if j, err := s.findList(newName); err == nil && j != i {
+		return invalidInputf("list %q already exists", newName)
+	}
+
+	// как и в БД, где задача ссылается на список по id, переименование не записывается в истории задач
+	old := s.lists[i].name
+	s.lists[i].name = newName
+	for id, task := range s.tasks {
+		if task.list == old {
+			task.list = newName
+			s.tasks[id] = task
+		}
+	}
+
+	return nil
+}
+
+// DeleteList удаляет список, задачи переносятся в список into
+func (s *memoryStore) DeleteList(name, into string) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.findList(name)
+	if err != nil {
+		return err
+	}
+	if s.lists[i].inbox {
+		return invalidInputf("list %q is the inbox, it can be renamed but not deleted", name)
+	}
+	name = s.lists[i].name
+
+	j := 0
+	if into != "" {
+		j, err = s.findList(into)
+		if err != nil {
+			return err
+		}
+		if j == i {
+			return invalidInputf("tasks of list %q cannot be moved to the list itself", name)
+		}
+	}
+
+	var moved []Task
+	for _, task := range s.tasks {
+		if task.list == name {
+			moved = append(moved, task)
+		}
+	}
+
+	if len(moved) > 0 {
+		if into == "" {
+			return invalidInputf("list %q has %d tasks (with the trash), name a list to move them to", name, len(moved))
+		}
+		for _, task := range moved {
+			task.list = s.lists[j].name
+			s.save(task, actionUpdate)
+		}
+	}
+
+	s.lists = slices.Delete(s.lists, i, i+1)
+
+	return nil
+}
+
+// MoveTask переносит задачу вместе с подзадачами в список list, подзадача становится задачей верхнего уровня
+func (s *memoryStore) MoveTask(id int64, list string) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.live(id)
+	if !ok {
+		return errNotFound
+	}
+
+	i, err := s.findList(list)
+	if err != nil {
+		return err
+	}
+	list = s.lists[i].name
+
+	if parent, ok := s.tasks[task.parent]; ok && parent.list != list {
+		task.parent = 0
+	}
+	s.setList(task, list)
+
+	return nil
+}
+
+// Replica возвращает идентификатор хранилища, он создаётся вместе с хранилищем
+func (s *memoryStore) Replica() (string, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.replica, nil
+}
+
+// ResetReplica даёт хранилищу новый идентификатор и возвращает его
+func (s *memoryStore) ResetReplica() (string, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.replica = uuid.NewString()
+
+	return s.replica, nil
+}
+
+// SyncedAt возвращает момент последней синхронизации с хранилищем replica, нулевой - синхронизации не было
+func (s *memoryStore) SyncedAt(replica string) (time.Time, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.syncedAt[replica], nil
+}
+
+// SetSyncedAt запоминает момент синхронизации с хранилищем replica
+func (s *memoryStore) SetSyncedAt(replica string, at time.Time) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.syncedAt[replica] = at
+
+	return nil
+}
+
+// ApplySync записывает версию задачи из другой БД так же, как sqliteStore.ApplySync
+func (s *memoryStore) ApplySync(task Task, parent string) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.findList(task.list)
+	if err != nil {
+		s.lists = append(s.lists, TaskList{name: task.list})
+		i = len(s.lists) - 1
+	}
+	task.list = s.lists[i].name
+
+	task.id, task.parent = 0, 0
+	for _, t := range s.tasks {
+		if t.uuid == task.uuid {
+			task.id = t.id
+		}
+	}
+	for _, t := range s.tasks {
+		if parent != "" && t.uuid == parent && !s.isAncestor(task.id, t.id) {
+			task.parent = t.id
+			task.list = t.list
+		}
+	}
+
+	action := actionSync
+	if task.id == 0 {
+		task.id = s.nextID
+		s.nextID++
+		action = actionCreate
+	}
+
+	updatedAt := task.updatedAt
+	task.tags = mergeTags(nil, task.tags)
+	s.save(task, action)
+
+	task = s.tasks[task.id]
+	task.updatedAt = updatedAt
+	s.tasks[task.id] = task
+
+	return nil
+}
+
+// Close ничего не делает, хранилищу в памяти нечего освобождать
+func (s *memoryStore) Close() error {
+
+	return nil
+}
+
+// live возвращает задачу по id, если она не в корзине. Вызывается под s.mu.
+func (s *memoryStore) live(id int64) (Task, bool) {
+
+	task, ok := s.tasks[id]
+	if !ok || !task.deletedAt.IsZero() {
+		return Task{}, false
+	}
+
+	return task, true
+}
+
+// withProgress заполняет у задачи количество подзадач и выполненных подзадач. Вызывается под s.mu.
+func (s *memoryStore) withProgress(task Task) Task {
+
+	task.subtasks, task.subtasksDone = 0, 0
+	for _, child := range s.tasks {
+		if child.parent == task.id && child.deletedAt.IsZero() {
+			task.subtasks++
+			if child.done {
+				task.subtasksDone++
+			}
+		}
+	}
+
+	return task
+}
+
+// setDeleted меняет момент удаления задачи task и её подзадач (на всю глубину), у которых он равен from, на to,
+// и записывает это в их истории как действие action. Вызывается под s.mu.
+func (s *memoryStore) setDeleted(task Task, from, to time.Time, action string) {
+
+	task.deletedAt = to
+	s.save(task, action)
+
+	for _, child := range s.tasks {
+		if child.parent == task.id && child.deletedAt.Equal(from) {
+			s.setDeleted(child, from, to, action)
+		}
+	}
+}
+
+// setList переносит задачу task и её подзадачи (на всю глубину, в том числе из корзины) в список list.
+// Вызывается под s.mu.
+func (s *memoryStore) setList(task Task, list string) {
+
+	task.list = list
+	s.save(task, actionUpdate)
+
+	for _, child := range s.tasks {
+		if child.parent == task.id {
+			s.setList(child, list)
+		}
+	}
+}
+
+// isAncestor сообщает, что задача id - одна из задач на пути от задачи parent вверх по иерархии (в том числе она сама).
+// Вызывается под s.mu.
+func (s *memoryStore) isAncestor(id, parent int64) bool {
+
+	for parent != 0 {
+		if parent == id {
+			return true
+		}
+		parent = s.tasks[parent].parent
+	}
+
+	return false
+}
+
+// findList возвращает индекс списка с именем name (без учёта регистра), пустое имя - входящие. Вызывается под s.mu.
+func (s *memoryStore) findList(name string) (int, error) {
+
+	if name == "" {
+		return 0, nil
+	}
+
+	for i, list := range s.lists {
+		if strings.EqualFold(list.name, name) {
+			return i, nil
+		}
+	}
+
+	return 0, invalidInputf("there is no list %q, see lists", name)
+}
+
+// save сохраняет задачу и записывает изменение в её историю (если задача действительно изменилась).
+// Вызывается под s.mu.
+func (s *memoryStore) save(task Task, action string) {
+
+	before := s.tasks[task.id]
+	s.tasks[task.id] = task
+
+	if action == actionCreate {
+		before = Task{id: task.id}
+	} else if sameState(before, task) {
+		return
+	}
+	task.updatedAt = time.Now().UTC()
+	s.tasks[task.id] = task
+
+	changes := s.history[task.id]
+	s.history[task.id] = append(changes, Change{
+		version: len(changes) + 1,
+		action:  action,
+		at:      time.Now().UTC().Truncate(time.Second),
+		before:  before,
+		after:   task,
+	})
+}
+
+// findReminder возвращает id напоминания задачи taskID с отступом offset, 0 - такого нет. Вызывается под s.mu.
+func (s *memoryStore) findReminder(taskID int64, offset time.Duration) int64 {
+
+	for id, r := range s.reminders {
+		if r.task.id == taskID && r.offset == offset {
+			return id
+		}
+	}
+
+	return 0
+}
+
+// filter отбирает подходящие задачи (без сортировки и лимита)
+func (s *memoryStore) filter(match func(Task) bool, opts ListOptions) []Task {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var allTasks []Task
+	for _, task := range s.tasks {
+		if opts.Trash == task.deletedAt.IsZero() {
+			continue
+		}
+		if opts.OnlyOpen && task.done {
+			continue
+		}
+		if (opts.DateFrom != "" && task.date < opts.DateFrom) || (opts.DateTo != "" && task.date > opts.DateTo) {
+			continue
+		}
+		if opts.Project != "" && task.project != opts.Project {
+			continue
+		}
+		if (opts.TopLevel && task.parent != 0) || (opts.Parent != 0 && task.parent != opts.Parent) {
+			continue
+		}
+		if opts.List != "" && !strings.EqualFold(task.list, opts.List) {
+			continue
+		}
+		if !hasAllTags(task, opts.Tags) || hasAnyTag(task, opts.ExcludeTags) {
+			continue
+		}
+		if match(task) {
+			allTasks = append(allTasks, s.withProgress(task))
+		}
+	}
+
+	return allTasks
+}
+
+// pageTasks сортирует задачи так же, как это делает БД, и оставляет страницу после курсора opts.After
+func pageTasks(allTasks []Task, opts ListOptions) []Task {
+
+	sort.Slice(allTasks, func(i, j int) bool {
+		return cursorLess(opts, cursorOf(allTasks[i]), cursorOf(allTasks[j]))
+	})
+
+	if opts.After != nil {
+		first := sort.Search(len(allTasks), func(i int) bool {
+			return cursorLess(opts, *opts.After, cursorOf(allTasks[i]))
+		})
+		allTasks = allTasks[first:]
+	}
+
+	if opts.Limit > 0 && len(allTasks) > opts.Limit {
+		allTasks = allTasks[:opts.Limit]
+	}
+
+	return allTasks
+}
+
+// cursorLess сравнивает позиции задач в порядке сортировки выборки: (важность), релевантность, срок, id
+func cursorLess(opts ListOptions, a, b Cursor) bool {
+
+	if opts.ByPriority && a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	if a.Rank != b.Rank {
+		return a.Rank < b.Rank
+	}
+	if a.Due != b.Due {
+		return a.Due < b.Due
+	}
+
+	return a.ID < b.ID
+}
+
+// mergeTags возвращает объединение меток без повторов в алфавитном порядке
+func mergeTags(tags, add []string) []string {
+
+	merged := slices.Concat(tags, add)
+	slices.Sort(merged)
+
+	return slices.Compact(merged)
+}
+
+// hasAllTags проверяет, что у задачи есть все метки tags
+func hasAllTags(task Task, tags []string) bool {
+
+	for _, tag := range tags {
+		if !slices.Contains(task.tags, tag) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hasAnyTag проверяет, что у задачи есть хотя бы одна из меток tags
+func hasAnyTag(task Task, tags []string) bool {
+
+	for _, tag := range tags {
+		if slices.Contains(task.tags, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// searchWords разбивает текст на слова в нижнем регистре так же, как токенизатор unicode61
+func searchWords(text string) []string {
+
+	return strings.FieldsFunc(strings.ToLower(yoFolder.Replace(text)), isNotWordRune)
+}
+
+// isNotWordRune проверяет, что символ разделяет слова
+func isNotWordRune(r rune) bool {
+
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// highlight обрамляет snippetMark слова текста, начинающиеся с одного из terms
+func highlight(text string, terms []string) string {
+
+	var b strings.Builder
+
+	for len(text) > 0 {
+		start := strings.IndexFunc(text, func(r rune) bool { return !isNotWordRune(r) })
+		if start < 0 {
+			b.WriteString(text)
+			break
+		}
+		b.WriteString(text[:start])
+		text = text[start:]
+
+		end := strings.IndexFunc(text, isNotWordRune)
+		if end < 0 {
+			end = len(text)
+		}
+		word := text[:end]
+		text = text[end:]
+
+		matched := false
+		for _, term := range terms {
+			if strings.HasPrefix(strings.ToLower(yoFolder.Replace(word)), term) {
+				matched = true
+				break
+			}
+		}
+		if matched {
+			word = snippetMark + word + snippetMark
+		}
+		b.WriteString(word)
+	}
+
+	return b.String()
+}
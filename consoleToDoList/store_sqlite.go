@@ -0,0 +1,1799 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite"
+)
+
+// taskColumns - столбцы dataTask в порядке, который ожидает scanTask, метки собираются в одну строку через запятую
+const taskColumns = `dataTask.id, dataTask.content, dataTask.date, dataTask.priority, dataTask.done, dataTask.done_at, dataTask.recur, dataTask.project, dataTask.deleted_at,
+dataTask.due, dataTask.all_day, dataTask.parent_id, (SELECT name FROM lists WHERE lists.id = dataTask.list_id),
+(SELECT count(*) FROM dataTask AS sub WHERE sub.parent_id = dataTask.id AND sub.deleted_at = ''),
+(SELECT count(*) FROM dataTask AS sub WHERE sub.parent_id = dataTask.id AND sub.deleted_at = '' AND sub.done = 1),
+(SELECT group_concat(tags.name) FROM task_tags JOIN tags ON tags.id = task_tags.tag_id WHERE task_tags.task_id = dataTask.id),
+dataTask.uuid, dataTask.updated_at`
+
+// sqliteStore хранит задачи в файле БД SQLite
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// openSQLiteStore открывает (и при необходимости создаёт) БД в файле dbFile и обновляет её схему до актуальной версии,
+// сроки старых задач при этом переводятся в часовой пояс пользователя loc
+func openSQLiteStore(dbFile string, loc *time.Location) (*sqliteStore, error) {
+
+	// внешние ключи в SQLite включаются отдельно для каждого соединения, поэтому - через параметры подключения.
+	// busy_timeout: фоновая проверка напоминаний (или todo remind --watch в другом процессе) может ненадолго
+	// занять БД, запрос в это время ждёт, а не падает с SQLITE_BUSY.
+	db, err := sql.Open("sqlite", dbFile+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("opening error %s: %w", dbFile, err)
+	}
+
+	err = migrate(db, loc)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error preparing schema of %s: %w", dbFile, err)
+	}
+
+	return &sqliteStore{db: db}, nil
+}
+
+// Create добавляет задачу вместе с метками в БД
+func (s *sqliteStore) Create(task Task) (id int64, err error) {
+
+	defer storageFailure(&err, "create task")
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err = insertTask(tx, task)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+// insertTask добавляет задачу вместе с метками в транзакции tx и записывает её создание в историю
+func insertTask(tx *sql.Tx, task Task) (int64, error) {
+
+	list, err := listID(tx, task.list)
+	if err != nil {
+		return 0, err
+	}
+
+	// подзадача всегда попадает в список своей родительской задачи
+	if task.parent != 0 {
+		err = taskExists(tx, task.parent)
+		if err != nil {
+			return 0, err
+		}
+		err = tx.QueryRow("SELECT list_id FROM dataTask WHERE id = :id", sql.Named("id", task.parent)).Scan(&list)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	query := "INSERT INTO dataTask (uuid, content, date, due, all_day, priority, done, done_at, recur, project, parent_id, list_id)" +
+		" VALUES (:uuid, :content, :date, :due, :all_day, :priority, :done, :done_at, :recur, :project, :parent_id, :list_id)"
+	res, err := tx.Exec(query,
+		sql.Named("uuid", uuid.NewString()),
+		sql.Named("content", task.content),
+		sql.Named("date", task.date),
+		sql.Named("due", task.due.UTC().Format(dueLayout)),
+		sql.Named("all_day", task.allDay),
+		sql.Named("priority", task.priority),
+		sql.Named("done", task.done),
+		sql.Named("done_at", formatDoneAt(task.done, task.doneAt)),
+		sql.Named("recur", task.recur),
+		sql.Named("project", task.project),
+		sql.Named("parent_id", parentID(task.parent)),
+		sql.Named("list_id", list))
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	err = insertTags(tx, id, task.tags)
+	if err != nil {
+		return 0, err
+	}
+
+	created, err := loadTask(tx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, recordChange(tx, actionCreate, Task{}, created)
+}
+
+// Get возвращает задачу по id
+func (s *sqliteStore) Get(id int64) (task Task, err error) {
+
+	defer storageFailure(&err, "get task")
+
+	row := s.db.QueryRow("SELECT "+taskColumns+" FROM dataTask WHERE dataTask.id = :id AND dataTask.deleted_at = ''",
+		sql.Named("id", id))
+	task, err = scanTask(row)
+	if err == sql.ErrNoRows {
+		return task, errNotFound
+	}
+
+	return task, err
+}
+
+// List возвращает задачи, отсортированные по сроку (или по важности и сроку)
+func (s *sqliteStore) List(opts ListOptions) (allTasks []Task, err error) {
+
+	defer storageFailure(&err, "list tasks")
+
+	where, order, args := listClauses(opts, false)
+
+	return s.query("SELECT "+taskColumns+" FROM dataTask WHERE "+where+" ORDER BY "+order+" LIMIT :limit",
+		append(args, sql.Named("limit", sqlLimit(opts.Limit)))...)
+}
+
+// Update обновляет описание, срок, важность, повторение и проект задачи
+func (s *sqliteStore) Update(task Task) (err error) {
+
+	defer storageFailure(&err, "update task")
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = changeTask(tx, task.id, actionUpdate, func() error {
+		return updateFields(tx, task)
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Reschedule переносит сроки задач: либо переносятся все, либо (если какой-то задачи нет) ни одна
+func (s *sqliteStore) Reschedule(tasks []Task) (err error) {
+
+	defer storageFailure(&err, "reschedule tasks")
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, task := range tasks {
+		err = changeTask(tx, task.id, actionUpdate, func() error {
+			res, err := tx.Exec("UPDATE dataTask SET date = :date, due = :due, all_day = :all_day WHERE id = :id AND deleted_at = ''",
+				sql.Named("date", task.date),
+				sql.Named("due", task.due.UTC().Format(dueLayout)),
+				sql.Named("all_day", task.allDay),
+				sql.Named("id", task.id))
+			if err != nil {
+				return err
+			}
+			return checkAffected(res)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SetDone отмечает задачу выполненной в момент at или снова открывает её
+func (s *sqliteStore) SetDone(id int64, done bool, at time.Time) (err error) {
+
+	defer storageFailure(&err, "set task status")
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = changeTask(tx, id, actionUpdate, func() error {
+		return setDone(tx, id, done, at)
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Complete отмечает задачу выполненной в момент at и добавляет её следующее повторение next (nil - не добавляет)
+// с напоминаниями задачи, всё в одной транзакции. Возвращает id повторения, 0 - повторение не добавлялось.
+func (s *sqliteStore) Complete(id int64, at time.Time, next *Task) (nextID int64, err error) {
+
+	defer storageFailure(&err, "complete task")
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	err = changeTask(tx, id, actionUpdate, func() error {
+		return setDone(tx, id, true, at)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if next == nil {
+		return 0, tx.Commit()
+	}
+
+	nextID, err = addNext(tx, id, *next)
+	if err != nil {
+		return 0, err
+	}
+
+	return nextID, tx.Commit()
+}
+
+// Edit изменяет задачу по edit в одной транзакции: все части изменения записываются одной записью истории
+// (подзадачи, перенесённые вместе с задачей в другой список, - своими записями). Возвращает id добавленного повторения.
+func (s *sqliteStore) Edit(id int64, edit TaskEdit) (nextID int64, err error) {
+
+	defer storageFailure(&err, "edit task")
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// задача переносится раньше, чтобы её можно было сделать подзадачей задачи из нового списка
+	err = changeTask(tx, id, actionUpdate, func() error {
+		if edit.List != nil {
+			err := moveTask(tx, id, *edit.List)
+			if err != nil {
+				return err
+			}
+		}
+		if edit.Parent != nil {
+			err := setParent(tx, id, *edit.Parent)
+			if err != nil {
+				return err
+			}
+		}
+		if edit.Fields != nil {
+			fields := *edit.Fields
+			fields.id = id
+			err := updateFields(tx, fields)
+			if err != nil {
+				return err
+			}
+		}
+		if edit.Tags != nil {
+			err := setTags(tx, id, *edit.Tags)
+			if err != nil {
+				return err
+			}
+		}
+		if edit.Done != nil {
+			return setDone(tx, id, *edit.Done, edit.At)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if edit.Tags != nil {
+		_, err = tx.Exec("DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM task_tags)")
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if edit.Next != nil {
+		task, err := loadTask(tx, id)
+		if err != nil {
+			return 0, err
+		}
+		next := *edit.Next
+		next.list = task.list
+		next.parent = task.parent
+		nextID, err = addNext(tx, id, next)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return nextID, tx.Commit()
+}
+
+// AddTags добавляет задаче метки, уже имеющиеся метки пропускаются
+func (s *sqliteStore) AddTags(id int64, tags []string) (err error) {
+
+	defer storageFailure(&err, "add tags")
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = taskExists(tx, id)
+	if err != nil {
+		return err
+	}
+
+	err = changeTask(tx, id, actionUpdate, func() error {
+		return insertTags(tx, id, tags)
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveTags снимает с задачи метки, метки, которые больше ни к чему не привязаны, удаляются
+func (s *sqliteStore) RemoveTags(id int64, tags []string) (err error) {
+
+	defer storageFailure(&err, "remove tags")
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = taskExists(tx, id)
+	if err != nil {
+		return err
+	}
+
+	err = changeTask(tx, id, actionUpdate, func() error {
+		for _, tag := range tags {
+			_, err := tx.Exec("DELETE FROM task_tags WHERE task_id = :id AND tag_id IN (SELECT id FROM tags WHERE name = :name)",
+				sql.Named("id", id),
+				sql.Named("name", tag))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM task_tags)")
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetParent делает задачу подзадачей задачи parent (0 - задачей верхнего уровня). Задачу нельзя сделать
+// подзадачей её самой, её собственной подзадачи или задачи из другого списка.
+func (s *sqliteStore) SetParent(id, parent int64) (err error) {
+
+	defer storageFailure(&err, "set parent task")
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = changeTask(tx, id, actionUpdate, func() error {
+		return setParent(tx, id, parent)
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete перемещает задачу в корзину в момент at. Подзадачи (со всеми их подзадачами) перемещаются туда же
+// в тот же момент или, если children - childrenReparent, переходят к родительской задаче удаляемой.
+func (s *sqliteStore) Delete(id int64, at time.Time, children childPolicy) (err error) {
+
+	defer storageFailure(&err, "delete task")
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = taskExists(tx, id)
+	if err != nil {
+		return err
+	}
+
+	// изменение каждой задачи записывается в её историю, поэтому задачи меняются по одной
+	if children == childrenReparent {
+		childIDs, err := queryIDs(tx, "SELECT id FROM dataTask WHERE parent_id = :id AND deleted_at = ''",
+			sql.Named("id", id))
+		if err != nil {
+			return err
+		}
+		for _, childID := range childIDs {
+			err = changeTask(tx, childID, actionUpdate, func() error {
+				_, err := tx.Exec("UPDATE dataTask SET parent_id = (SELECT parent_id FROM dataTask WHERE id = :parent) WHERE id = :id",
+					sql.Named("parent", id),
+					sql.Named("id", childID))
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	subtree, err := queryIDs(tx, `WITH RECURSIVE subtree (id) AS (
+SELECT :id UNION SELECT dataTask.id FROM dataTask JOIN subtree ON dataTask.parent_id = subtree.id WHERE dataTask.deleted_at = ''
+) SELECT id FROM subtree`,
+		sql.Named("id", id))
+	if err != nil {
+		return err
+	}
+	for _, taskID := range subtree {
+		err = changeTask(tx, taskID, actionDelete, func() error {
+			_, err := tx.Exec("UPDATE dataTask SET deleted_at = :deleted_at WHERE id = :id",
+				sql.Named("deleted_at", at.UTC().Format(time.RFC3339)),
+				sql.Named("id", taskID))
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Restore возвращает задачу из корзины вместе с подзадачами, удалёнными в тот же момент. Если родительская задача
+// восстановленной в корзине, восстановленная становится задачей верхнего уровня.
+func (s *sqliteStore) Restore(id int64) (err error) {
+
+	defer storageFailure(&err, "restore task")
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var deletedAt string
+	err = tx.QueryRow("SELECT deleted_at FROM dataTask WHERE id = :id AND deleted_at != ''",
+		sql.Named("id", id)).Scan(&deletedAt)
+	if err == sql.ErrNoRows {
+		return errNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	subtree, err := queryIDs(tx, `WITH RECURSIVE subtree (id) AS (
+SELECT :id UNION SELECT dataTask.id FROM dataTask JOIN subtree ON dataTask.parent_id = subtree.id WHERE dataTask.deleted_at = :deleted_at
+) SELECT id FROM subtree`,
+		sql.Named("deleted_at", deletedAt),
+		sql.Named("id", id))
+	if err != nil {
+		return err
+	}
+
+	for _, taskID := range subtree {
+		err = changeTask(tx, taskID, actionRestore, func() error {
+			_, err := tx.Exec("UPDATE dataTask SET deleted_at = '' WHERE id = :id",
+				sql.Named("id", taskID))
+			if err != nil || taskID != id {
+				return err
+			}
+			_, err = tx.Exec("UPDATE dataTask SET parent_id = NULL WHERE id = :id AND parent_id IN (SELECT id FROM dataTask WHERE deleted_at != '')",
+				sql.Named("id", id))
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Purge окончательно удаляет задачи, попавшие в корзину раньше before, и метки, которые больше ни к чему не привязаны
+func (s *sqliteStore) Purge(before time.Time) (count int, err error) {
+
+	defer storageFailure(&err, "purge trash")
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM dataTask WHERE deleted_at != '' AND deleted_at < :before",
+		sql.Named("before", before.UTC().Format(time.RFC3339)))
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec("DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM task_tags)")
+	if err != nil {
+		return 0, err
+	}
+
+	return int(purged), tx.Commit()
+}
+
+// Search возвращает задачи, найденные полнотекстовым поиском по описанию и дате, самые релевантные - первыми.
+// Регистр букв (в том числе кириллицы) не учитывается, каждое слово запроса ищется как начало слова.
+func (s *sqliteStore) Search(query string, opts ListOptions) (allTasks []Task, err error) {
+
+	defer storageFailure(&err, "search tasks")
+
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+
+	where, order, args := listClauses(opts, true)
+
+	rows, err := s.db.Query("SELECT "+taskColumns+", snippet(dataTask_fts, 0, :mark, :mark, '…', 12), dataTask_fts.rank"+
+		" FROM dataTask_fts JOIN dataTask ON dataTask.id = dataTask_fts.rowid"+
+		" WHERE dataTask_fts MATCH :match AND "+where+" ORDER BY "+order+" LIMIT :limit",
+		append(args,
+			sql.Named("match", match),
+			sql.Named("mark", snippetMark),
+			sql.Named("limit", sqlLimit(opts.Limit)))...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var snippet string
+		var rank float64
+		task, err := scanTask(rows, &snippet, &rank)
+		if err != nil {
+			return nil, err
+		}
+		task.snippet = snippet
+		task.rank = rank
+		allTasks = append(allTasks, task)
+	}
+
+	return allTasks, rows.Err()
+}
+
+// AddReminders добавляет задаче напоминания, уже имеющиеся пропускаются
+func (s *sqliteStore) AddReminders(id int64, offsets []time.Duration) (err error) {
+
+	defer storageFailure(&err, "add reminders")
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = taskExists(tx, id)
+	if err != nil {
+		return err
+	}
+
+	err = insertReminders(tx, id, offsets)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveReminders убирает у задачи напоминания
+func (s *sqliteStore) RemoveReminders(id int64, offsets []time.Duration) (err error) {
+
+	defer storageFailure(&err, "remove reminders")
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = taskExists(tx, id)
+	if err != nil {
+		return err
+	}
+
+	for _, offset := range offsets {
+		_, err = tx.Exec("DELETE FROM reminders WHERE task_id = :id AND offset_minutes = :offset",
+			sql.Named("id", id),
+			sql.Named("offset", int64(offset/time.Minute)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Reminders возвращает напоминания задачи, самые ранние (с наибольшим отступом от срока) - первыми
+func (s *sqliteStore) Reminders(id int64) (reminders []Reminder, err error) {
+
+	defer storageFailure(&err, "list reminders")
+
+	_, err = s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.queryReminders("SELECT "+taskColumns+", reminders.id, reminders.offset_minutes, reminders.fired_at"+
+		" FROM reminders JOIN dataTask ON dataTask.id = reminders.task_id"+
+		" WHERE reminders.task_id = :id ORDER BY reminders.offset_minutes DESC",
+		sql.Named("id", id))
+}
+
+// DueReminders возвращает несработавшие напоминания открытых задач не из корзины, время которых не позже now,
+// по сроку задачи, а у одной задачи - от самого раннего к самому позднему.
+// Время напоминания считается в SQL: срок задачи (для задачи на весь день - со сдвигом на allDayRemindHour) минус отступ.
+func (s *sqliteStore) DueReminders(now time.Time) (reminders []Reminder, err error) {
+
+	defer storageFailure(&err, "find due reminders")
+
+	return s.queryReminders("SELECT "+taskColumns+", reminders.id, reminders.offset_minutes, reminders.fired_at"+
+		" FROM reminders JOIN dataTask ON dataTask.id = reminders.task_id"+
+		" WHERE reminders.fired_at = '' AND dataTask.done = 0 AND dataTask.deleted_at = ''"+
+		" AND strftime('%Y-%m-%dT%H:%M:%SZ', dataTask.due,"+
+		" (dataTask.all_day * :all_day_shift - reminders.offset_minutes) || ' minutes') <= :now"+
+		" ORDER BY dataTask.due, dataTask.id, reminders.offset_minutes DESC",
+		sql.Named("all_day_shift", allDayRemindHour*60),
+		sql.Named("now", now.UTC().Format(dueLayout)))
+}
+
+// MarkFired отмечает напоминания сработавшими в момент at
+func (s *sqliteStore) MarkFired(ids []int64, at time.Time) (err error) {
+
+	defer storageFailure(&err, "mark reminders")
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		_, err = tx.Exec("UPDATE reminders SET fired_at = :fired_at WHERE id = :id",
+			sql.Named("fired_at", at.UTC().Format(time.RFC3339)),
+			sql.Named("id", id))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// History возвращает историю изменений задачи (в том числе из корзины), от старых к новым
+func (s *sqliteStore) History(id int64) (changes []Change, err error) {
+
+	defer storageFailure(&err, "read task history")
+
+	var exists bool
+	err = s.db.QueryRow("SELECT EXISTS (SELECT 1 FROM dataTask WHERE id = :id)", sql.Named("id", id)).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errNotFound
+	}
+
+	rows, err := s.db.Query("SELECT action, changed_at, old_state, new_state FROM task_history WHERE task_id = :id ORDER BY id",
+		sql.Named("id", id))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		c := Change{version: len(changes) + 1}
+		var at, before, after string
+		err = rows.Scan(&c.action, &at, &before, &after)
+		if err != nil {
+			return nil, err
+		}
+		c.at, err = time.Parse(time.RFC3339, at)
+		if err != nil {
+			return nil, fmt.Errorf("task %d: bad changed_at %q: %w", id, at, err)
+		}
+		c.before, err = decodeState(id, before)
+		if err != nil {
+			return nil, err
+		}
+		c.after, err = decodeState(id, after)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+
+	return changes, rows.Err()
+}
+
+// Revert возвращает задаче описание, срок, важность, выполнение, повторение, проект и метки её версии version
+// (состояния после изменения с этим номером). Возврат записывается в историю как ещё одно изменение.
+func (s *sqliteStore) Revert(id int64, version int) (err error) {
+
+	defer storageFailure(&err, "revert task")
+
+	changes, err := s.History(id)
+	if err != nil {
+		return err
+	}
+	if version < 1 || version > len(changes) {
+		return invalidInputf("task %d has no version %d, see its history", id, version)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = taskExists(tx, id)
+	if err != nil {
+		return err
+	}
+
+	target := changes[version-1].after
+	err = changeTask(tx, id, actionRevert, func() error {
+		_, err := tx.Exec("UPDATE dataTask SET content = :content, date = :date, due = :due, all_day = :all_day, priority = :priority,"+
+			" done = :done, done_at = :done_at, recur = :recur, project = :project WHERE id = :id",
+			sql.Named("content", target.content),
+			sql.Named("date", target.date),
+			sql.Named("due", target.due.UTC().Format(dueLayout)),
+			sql.Named("all_day", target.allDay),
+			sql.Named("priority", target.priority),
+			sql.Named("done", target.done),
+			sql.Named("done_at", formatDoneAt(target.done, target.doneAt)),
+			sql.Named("recur", target.recur),
+			sql.Named("project", target.project),
+			sql.Named("id", id))
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM task_tags WHERE task_id = :id", sql.Named("id", id))
+		if err != nil {
+			return err
+		}
+		return insertTags(tx, id, target.tags)
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM task_tags)")
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Lists возвращает списки задач с количеством задач в каждом, входящие - первыми
+func (s *sqliteStore) Lists() (lists []TaskList, err error) {
+
+	defer storageFailure(&err, "list task lists")
+
+	rows, err := s.db.Query("SELECT id, name, (SELECT count(*) FROM dataTask WHERE list_id = lists.id AND deleted_at = '')," +
+		" (SELECT count(*) FROM dataTask WHERE list_id = lists.id AND deleted_at != '') FROM lists ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var list TaskList
+		err = rows.Scan(&id, &list.name, &list.tasks, &list.trashed)
+		if err != nil {
+			return nil, err
+		}
+		list.inbox = id == inboxListID
+		lists = append(lists, list)
+	}
+
+	return lists, rows.Err()
+}
+
+// CreateList добавляет пустой список задач
+func (s *sqliteStore) CreateList(name string) (err error) {
+
+	defer storageFailure(&err, "create list")
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = listNameFree(tx, name, 0)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO lists (name) VALUES (:name)", sql.Named("name", name))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RenameList переименовывает список, задачи остаются в нём
+func (s *sqliteStore) RenameList(name, newName string) (err error) {
+
+	defer storageFailure(&err, "rename list")
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	id, err := listID(tx, name)
+	if err != nil {
+		return err
+	}
+
+	// имя можно сменить и на то же самое в другом регистре
+	err = listNameFree(tx, newName, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE lists SET name = :name WHERE id = :id",
+		sql.Named("name", newName),
+		sql.Named("id", id))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteList удаляет список. Задачи списка (в том числе из корзины) переносятся в список into, без него удалить
+// можно только пустой список. Входящие удалить нельзя.
+func (s *sqliteStore) DeleteList(name, into string) (err error) {
+
+	defer storageFailure(&err, "delete list")
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	id, err := listID(tx, name)
+	if err != nil {
+		return err
+	}
+	if id == inboxListID {
+		return invalidInputf("list %q is the inbox, it can be renamed but not deleted", name)
+	}
+
+	var intoID int64
+	if into != "" {
+		intoID, err = listID(tx, into)
+		if err != nil {
+			return err
+		}
+		if intoID == id {
+			return invalidInputf("tasks of list %q cannot be moved to the list itself", name)
+		}
+	}
+
+	taskIDs, err := queryIDs(tx, "SELECT id FROM dataTask WHERE list_id = :id", sql.Named("id", id))
+	if err != nil {
+		return err
+	}
+
+	if len(taskIDs) > 0 {
+		if into == "" {
+			return invalidInputf("list %q has %d tasks (with the trash), name a list to move them to", name, len(taskIDs))
+		}
+		for _, taskID := range taskIDs {
+			err = changeTask(tx, taskID, actionUpdate, func() error {
+				_, err := tx.Exec("UPDATE dataTask SET list_id = :list_id WHERE id = :id",
+					sql.Named("list_id", intoID),
+					sql.Named("id", taskID))
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = tx.Exec("DELETE FROM lists WHERE id = :id", sql.Named("id", id))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MoveTask переносит задачу вместе с подзадачами (на всю глубину, в том числе из корзины) в список list.
+// Перенесённая подзадача становится задачей верхнего уровня: её родительская задача остаётся в прежнем списке.
+func (s *sqliteStore) MoveTask(id int64, list string) (err error) {
+
+	defer storageFailure(&err, "move task")
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = changeTask(tx, id, actionUpdate, func() error {
+		return moveTask(tx, id, list)
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Replica возвращает идентификатор БД, его создаёт миграция "add sync identifiers"
+func (s *sqliteStore) Replica() (id string, err error) {
+
+	defer storageFailure(&err, "read replica id")
+
+	err = s.db.QueryRow("SELECT id FROM replica").Scan(&id)
+
+	return id, err
+}
+
+// ResetReplica даёт БД новый идентификатор и возвращает его
+func (s *sqliteStore) ResetReplica() (id string, err error) {
+
+	defer storageFailure(&err, "reset replica id")
+
+	err = s.db.QueryRow("UPDATE replica SET id = lower(hex(randomblob(16))) RETURNING id").Scan(&id)
+
+	return id, err
+}
+
+// SyncedAt возвращает момент последней синхронизации с БД replica, нулевой - синхронизации не было
+func (s *sqliteStore) SyncedAt(replica string) (at time.Time, err error) {
+
+	defer storageFailure(&err, "read sync time")
+
+	var syncedAt string
+	err = s.db.QueryRow("SELECT synced_at FROM sync_peers WHERE replica = :replica", sql.Named("replica", replica)).Scan(&syncedAt)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Parse(time.RFC3339, syncedAt)
+}
+
+// SetSyncedAt запоминает момент синхронизации с БД replica
+func (s *sqliteStore) SetSyncedAt(replica string, at time.Time) (err error) {
+
+	defer storageFailure(&err, "save sync time")
+
+	_, err = s.db.Exec("INSERT INTO sync_peers (replica, synced_at) VALUES (:replica, :at) ON CONFLICT (replica) DO UPDATE SET synced_at = :at",
+		sql.Named("replica", replica),
+		sql.Named("at", at.UTC().Format(time.RFC3339Nano)))
+
+	return err
+}
+
+// ApplySync записывает версию задачи из другой БД: задача с тем же uuid заменяется целиком (вместе с метками, корзиной
+// и списком, недостающий список создаётся), а если такой нет - добавляется. Родительская задача ищется по uuid parent
+// (если её нет или получился бы цикл - задача верхнего уровня), подзадача попадает в список родительской задачи.
+// Момент изменения берётся из task.updatedAt, чтобы версии в обеих БД совпадали и при следующей синхронизации.
+func (s *sqliteStore) ApplySync(task Task, parent string) (err error) {
+
+	defer storageFailure(&err, "apply synced task")
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	list, err := syncListID(tx, task.list)
+	if err != nil {
+		return err
+	}
+
+	var id int64
+	err = tx.QueryRow("SELECT id FROM dataTask WHERE uuid = :uuid", sql.Named("uuid", task.uuid)).Scan(&id)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	var parentRow struct {
+		id   sql.NullInt64
+		list int64
+	}
+	if parent != "" {
+		err = tx.QueryRow("SELECT id, list_id FROM dataTask WHERE uuid = :uuid", sql.Named("uuid", parent)).Scan(&parentRow.id, &parentRow.list)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+	}
+	if parentRow.id.Valid && id != 0 {
+		cycle, err := isAncestor(tx, id, parentRow.id.Int64)
+		if err != nil {
+			return err
+		}
+		parentRow.id.Valid = !cycle
+	}
+	if parentRow.id.Valid {
+		list = parentRow.list
+	}
+
+	write := func() error {
+		_, err := tx.Exec("UPDATE dataTask SET content = :content, date = :date, due = :due, all_day = :all_day, priority = :priority,"+
+			" done = :done, done_at = :done_at, recur = :recur, project = :project, parent_id = :parent_id, list_id = :list_id,"+
+			" deleted_at = :deleted_at WHERE id = :id",
+			sql.Named("content", task.content),
+			sql.Named("date", task.date),
+			sql.Named("due", task.due.UTC().Format(dueLayout)),
+			sql.Named("all_day", task.allDay),
+			sql.Named("priority", task.priority),
+			sql.Named("done", task.done),
+			sql.Named("done_at", formatDoneAt(task.done, task.doneAt)),
+			sql.Named("recur", task.recur),
+			sql.Named("project", task.project),
+			sql.Named("parent_id", parentRow.id),
+			sql.Named("list_id", list),
+			sql.Named("deleted_at", formatDeletedAt(task.deletedAt)),
+			sql.Named("id", id))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("DELETE FROM task_tags WHERE task_id = :id", sql.Named("id", id))
+		if err != nil {
+			return err
+		}
+
+		return insertTags(tx, id, task.tags)
+	}
+
+	if id != 0 {
+		err = changeTask(tx, id, actionSync, write)
+	} else {
+		var res sql.Result
+		res, err = tx.Exec("INSERT INTO dataTask (uuid) VALUES (:uuid)", sql.Named("uuid", task.uuid))
+		if err == nil {
+			id, err = res.LastInsertId()
+		}
+		if err == nil {
+			err = write()
+		}
+		if err == nil {
+			var created Task
+			created, err = loadTask(tx, id)
+			if err == nil {
+				err = recordChange(tx, actionCreate, Task{}, created)
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	err = setUpdatedAt(tx, id, task.updatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Close закрывает соединение с БД
+func (s *sqliteStore) Close() (err error) {
+
+	defer storageFailure(&err, "close database")
+
+	return s.db.Close()
+}
+
+// query выполняет запрос и собирает задачи из результата
+func (s *sqliteStore) query(query string, args ...any) ([]Task, error) {
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var allTasks []Task
+
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		allTasks = append(allTasks, task)
+	}
+
+	return allTasks, rows.Err()
+}
+
+// queryReminders выполняет запрос и собирает напоминания из результата: столбцы задачи из taskColumns,
+// за ними id, отступ в минутах и момент срабатывания напоминания
+func (s *sqliteStore) queryReminders(query string, args ...any) ([]Reminder, error) {
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []Reminder
+
+	for rows.Next() {
+		var r Reminder
+		var minutes int64
+		var firedAt string
+		r.task, err = scanTask(rows, &r.id, &minutes, &firedAt)
+		if err != nil {
+			return nil, err
+		}
+		r.offset = time.Duration(minutes) * time.Minute
+		if firedAt != "" {
+			r.firedAt, err = time.Parse(time.RFC3339, firedAt)
+			if err != nil {
+				return nil, fmt.Errorf("reminder %d: bad fired_at %q: %w", r.id, firedAt, err)
+			}
+		}
+		reminders = append(reminders, r)
+	}
+
+	return reminders, rows.Err()
+}
+
+// ftsQuery превращает пользовательский запрос в запрос FTS5: каждое слово берётся в кавычки (чтобы спецсимволы
+// не считались синтаксисом FTS5) и ищется как начало слова, все слова должны найтись
+func ftsQuery(query string) string {
+
+	var terms []string
+	for _, word := range strings.Fields(yoFolder.Replace(query)) {
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"*`)
+	}
+
+	return strings.Join(terms, " ")
+}
+
+// insertTags привязывает к задаче метки, создавая недостающие
+func insertTags(tx *sql.Tx, id int64, tags []string) error {
+
+	for _, tag := range tags {
+		_, err := tx.Exec("INSERT OR IGNORE INTO tags (name) VALUES (:name)",
+			sql.Named("name", tag))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("INSERT OR IGNORE INTO task_tags (task_id, tag_id) SELECT :id, id FROM tags WHERE name = :name",
+			sql.Named("id", id),
+			sql.Named("name", tag))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertReminders добавляет задаче напоминания, уже имеющиеся пропускаются
+func insertReminders(tx *sql.Tx, id int64, offsets []time.Duration) error {
+
+	for _, offset := range offsets {
+		_, err := tx.Exec("INSERT OR IGNORE INTO reminders (task_id, offset_minutes) VALUES (:id, :offset)",
+			sql.Named("id", id),
+			sql.Named("offset", int64(offset/time.Minute)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateFields записывает описание, срок, важность, повторение и проект задачи task.id
+func updateFields(tx *sql.Tx, task Task) error {
+
+	res, err := tx.Exec("UPDATE dataTask SET content = :content, date = :date, due = :due, all_day = :all_day,"+
+		" priority = :priority, recur = :recur, project = :project WHERE id = :id AND deleted_at = ''",
+		sql.Named("content", task.content),
+		sql.Named("date", task.date),
+		sql.Named("due", task.due.UTC().Format(dueLayout)),
+		sql.Named("all_day", task.allDay),
+		sql.Named("priority", task.priority),
+		sql.Named("recur", task.recur),
+		sql.Named("project", task.project),
+		sql.Named("id", task.id))
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// setTags заменяет метки задачи id набором tags (метки, которые больше ни к чему не привязаны, удаляет вызывающий)
+func setTags(tx *sql.Tx, id int64, tags []string) error {
+
+	_, err := tx.Exec("DELETE FROM task_tags WHERE task_id = :id", sql.Named("id", id))
+	if err != nil {
+		return err
+	}
+
+	return insertTags(tx, id, tags)
+}
+
+// setParent делает задачу id подзадачей задачи parent (0 - задачей верхнего уровня) после проверок SetParent.
+// Изменение самой задачи в историю не записывается, это делает вызывающий через changeTask.
+func setParent(tx *sql.Tx, id, parent int64) error {
+
+	if id == parent {
+		return invalidInputf("task %d cannot be a subtask of itself", id)
+	}
+
+	err := taskExists(tx, id)
+	if err != nil {
+		return err
+	}
+
+	if parent != 0 {
+		err = taskExists(tx, parent)
+		if err != nil {
+			return err
+		}
+
+		// задача не должна оказаться среди предков своей новой родительской задачи
+		cycle, err := isAncestor(tx, id, parent)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return invalidInputf("task %d cannot become a subtask of its own subtask %d", id, parent)
+		}
+
+		var sameList bool
+		err = tx.QueryRow("SELECT (SELECT list_id FROM dataTask WHERE id = :id) = (SELECT list_id FROM dataTask WHERE id = :parent)",
+			sql.Named("parent", parent),
+			sql.Named("id", id)).Scan(&sameList)
+		if err != nil {
+			return err
+		}
+		if !sameList {
+			return invalidInputf("task %d and task %d are in different lists, move task %d to the list of task %d first", id, parent, id, parent)
+		}
+	}
+
+	_, err = tx.Exec("UPDATE dataTask SET parent_id = :parent_id WHERE id = :id",
+		sql.Named("parent_id", parentID(parent)),
+		sql.Named("id", id))
+
+	return err
+}
+
+// moveTask переносит задачу id вместе с подзадачами в список list, задача из другого списка перестаёт быть её родительской.
+// Изменения подзадач записываются в их истории, а изменение самой задачи записывает вызывающий через changeTask.
+func moveTask(tx *sql.Tx, id int64, list string) error {
+
+	err := taskExists(tx, id)
+	if err != nil {
+		return err
+	}
+
+	target, err := listID(tx, list)
+	if err != nil {
+		return err
+	}
+
+	subtree, err := queryIDs(tx, `WITH RECURSIVE subtree (id) AS (
+SELECT :id UNION SELECT dataTask.id FROM dataTask JOIN subtree ON dataTask.parent_id = subtree.id
+) SELECT id FROM subtree`,
+		sql.Named("id", id))
+	if err != nil {
+		return err
+	}
+
+	for _, taskID := range subtree {
+		if taskID == id {
+			continue
+		}
+		err = changeTask(tx, taskID, actionUpdate, func() error {
+			_, err := tx.Exec("UPDATE dataTask SET list_id = :list_id WHERE id = :id",
+				sql.Named("list_id", target),
+				sql.Named("id", taskID))
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec("UPDATE dataTask SET list_id = :list_id WHERE id = :id",
+		sql.Named("list_id", target),
+		sql.Named("id", id))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE dataTask SET parent_id = NULL WHERE id = :id AND parent_id IN (SELECT id FROM dataTask WHERE list_id != :list_id)",
+		sql.Named("list_id", target),
+		sql.Named("id", id))
+
+	return err
+}
+
+// addNext добавляет следующее повторение next выполненной задачи id и переносит к нему напоминания задачи
+func addNext(tx *sql.Tx, id int64, next Task) (int64, error) {
+
+	nextID, err := insertTask(tx, next)
+	if err != nil {
+		return 0, err
+	}
+
+	// напоминания переходят к следующему повторению с теми же отступами от срока
+	_, err = tx.Exec("INSERT INTO reminders (task_id, offset_minutes) SELECT :next, offset_minutes FROM reminders WHERE task_id = :id",
+		sql.Named("next", nextID),
+		sql.Named("id", id))
+	if err != nil {
+		return 0, err
+	}
+
+	return nextID, nil
+}
+
+// setDone отмечает задачу id выполненной в момент at или снова открывает её
+func setDone(tx *sql.Tx, id int64, done bool, at time.Time) error {
+
+	res, err := tx.Exec("UPDATE dataTask SET done = :done, done_at = :done_at WHERE id = :id AND deleted_at = ''",
+		sql.Named("done", done),
+		sql.Named("done_at", formatDoneAt(done, at)),
+		sql.Named("id", id))
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// loadTask возвращает задачу по id, в том числе из корзины
+func loadTask(tx *sql.Tx, id int64) (Task, error) {
+
+	task, err := scanTask(tx.QueryRow("SELECT "+taskColumns+" FROM dataTask WHERE dataTask.id = :id", sql.Named("id", id)))
+	if err == sql.ErrNoRows {
+		return task, errNotFound
+	}
+
+	return task, err
+}
+
+// changeTask выполняет изменение задачи id и записывает его в историю задачи как действие action
+func changeTask(tx *sql.Tx, id int64, action string, change func() error) error {
+
+	before, err := loadTask(tx, id)
+	if err != nil {
+		return err
+	}
+
+	err = change()
+	if err != nil {
+		return err
+	}
+
+	after, err := loadTask(tx, id)
+	if err != nil {
+		return err
+	}
+
+	return recordChange(tx, action, before, after)
+}
+
+// recordChange записывает в историю задачи изменение: состояние before (нулевая задача - для создания) и after.
+// Изменение, которое ничего не изменило, не записывается.
+func recordChange(tx *sql.Tx, action string, before, after Task) error {
+
+	if action != actionCreate && sameState(before, after) {
+		return nil
+	}
+
+	oldState := ""
+	if action != actionCreate {
+		var err error
+		oldState, err = encodeState(before)
+		if err != nil {
+			return err
+		}
+	}
+
+	newState, err := encodeState(after)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	_, err = tx.Exec("INSERT INTO task_history (task_id, action, changed_at, old_state, new_state) VALUES (:id, :action, :at, :old, :new)",
+		sql.Named("id", after.id),
+		sql.Named("action", action),
+		sql.Named("at", now.Format(time.RFC3339)),
+		sql.Named("old", oldState),
+		sql.Named("new", newState))
+	if err != nil {
+		return err
+	}
+
+	return setUpdatedAt(tx, after.id, now)
+}
+
+// setUpdatedAt записывает момент последнего изменения задачи id
+func setUpdatedAt(tx *sql.Tx, id int64, at time.Time) error {
+
+	_, err := tx.Exec("UPDATE dataTask SET updated_at = :at WHERE id = :id",
+		sql.Named("at", at.UTC().Format(time.RFC3339Nano)),
+		sql.Named("id", id))
+
+	return err
+}
+
+// isAncestor сообщает, что задача id - одна из задач на пути от задачи parent вверх по иерархии (в том числе она сама)
+func isAncestor(tx *sql.Tx, id, parent int64) (bool, error) {
+
+	var found bool
+	err := tx.QueryRow(`WITH RECURSIVE ancestors (id) AS (
+SELECT :parent UNION SELECT dataTask.parent_id FROM dataTask JOIN ancestors ON dataTask.id = ancestors.id WHERE dataTask.parent_id IS NOT NULL
+) SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = :id)`,
+		sql.Named("parent", parent),
+		sql.Named("id", id)).Scan(&found)
+
+	return found, err
+}
+
+// queryIDs выполняет запрос, возвращающий один столбец id
+func queryIDs(tx *sql.Tx, query string, args ...any) ([]int64, error) {
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// inboxListID - id входящих, их создаёт миграция "add task lists"
+const inboxListID int64 = 1
+
+// listID возвращает id списка по имени (без учёта регистра), пустое имя - входящие
+func listID(tx *sql.Tx, name string) (int64, error) {
+
+	if name == "" {
+		return inboxListID, nil
+	}
+
+	var id int64
+	err := tx.QueryRow("SELECT id FROM lists WHERE name = :name", sql.Named("name", name)).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, invalidInputf("there is no list %q, see lists", name)
+	}
+
+	return id, err
+}
+
+// listNameFree возвращает ошибку ввода, если имя name уже занято другим списком (не списком с id self)
+func listNameFree(tx *sql.Tx, name string, self int64) error {
+
+	var taken bool
+	err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM lists WHERE name = :name AND id != :id)",
+		sql.Named("name", name),
+		sql.Named("id", self)).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return invalidInputf("list %q already exists", name)
+	}
+
+	return nil
+}
+
+// syncListID возвращает id списка по имени, как listID, но недостающий список создаёт
+func syncListID(tx *sql.Tx, name string) (int64, error) {
+
+	id, err := listID(tx, name)
+	if !errors.Is(err, errInvalidInput) {
+		return id, err
+	}
+
+	res, err := tx.Exec("INSERT INTO lists (name) VALUES (:name)", sql.Named("name", name))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+// taskExists возвращает errNotFound, если задачи с указанным id нет (или она в корзине)
+func taskExists(tx *sql.Tx, id int64) error {
+
+	var exists bool
+	err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM dataTask WHERE id = :id AND deleted_at = '')",
+		sql.Named("id", id)).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errNotFound
+	}
+
+	return nil
+}
+
+// rowScanner - общее у *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanTask считывает задачу из строки результата, столбцы перечислены в taskColumns, за ними могут идти столбцы extra
+func scanTask(row rowScanner, extra ...any) (Task, error) {
+
+	var task Task
+	var doneAt, deletedAt, due string
+	var tags sql.NullString
+	var parent sql.NullInt64
+	var updatedAt string
+
+	dest := []any{&task.id, &task.content, &task.date, &task.priority, &task.done, &doneAt, &task.recur, &task.project, &deletedAt,
+		&due, &task.allDay, &parent, &task.list, &task.subtasks, &task.subtasksDone, &tags, &task.uuid, &updatedAt}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return task, err
+	}
+	task.parent = parent.Int64
+
+	task.due, err = time.Parse(dueLayout, due)
+	if err != nil {
+		return task, fmt.Errorf("task %d: bad due %q: %w", task.id, due, err)
+	}
+
+	if tags.String != "" {
+		task.tags = strings.Split(tags.String, ",")
+		sort.Strings(task.tags)
+	}
+
+	if doneAt != "" {
+		task.doneAt, err = time.Parse(time.RFC3339, doneAt)
+		if err != nil {
+			return task, fmt.Errorf("task %d: bad done_at %q: %w", task.id, doneAt, err)
+		}
+	}
+
+	if deletedAt != "" {
+		task.deletedAt, err = time.Parse(time.RFC3339, deletedAt)
+		if err != nil {
+			return task, fmt.Errorf("task %d: bad deleted_at %q: %w", task.id, deletedAt, err)
+		}
+	}
+
+	if updatedAt != "" {
+		task.updatedAt, err = time.Parse(time.RFC3339, updatedAt)
+		if err != nil {
+			return task, fmt.Errorf("task %d: bad updated_at %q: %w", task.id, updatedAt, err)
+		}
+	}
+
+	return task, nil
+}
+
+// parentID возвращает id родительской задачи в виде, в котором он хранится в БД (NULL для задачи верхнего уровня)
+func parentID(parent int64) sql.NullInt64 {
+
+	return sql.NullInt64{Int64: parent, Valid: parent != 0}
+}
+
+// formatDoneAt возвращает момент выполнения в виде, в котором он хранится в БД (пустая строка для невыполненной задачи)
+func formatDoneAt(done bool, at time.Time) string {
+
+	if !done {
+		return ""
+	}
+
+	return at.UTC().Format(time.RFC3339)
+}
+
+// formatDeletedAt возвращает момент удаления в виде, в котором он хранится в БД (пустая строка для задачи не из корзины)
+func formatDeletedAt(at time.Time) string {
+
+	if at.IsZero() {
+		return ""
+	}
+
+	return at.UTC().Format(time.RFC3339)
+}
+
+// sortKey описывает столбец, по которому сортируется выборка
+type sortKey struct {
+	column string
+	desc   bool
+	after  any // значение столбца у задачи, на которую указывает курсор
+}
+
+// listClauses строит условие отбора, порядок сортировки и параметры запроса для параметров выборки.
+// search - выборка полнотекстового поиска, она сортируется ещё и по релевантности.
+func listClauses(opts ListOptions, search bool) (where, order string, args []any) {
+
+	conditions := []string{"dataTask.deleted_at = ''"}
+	if opts.Trash {
+		conditions = []string{"dataTask.deleted_at != ''"}
+	}
+	if opts.OnlyOpen {
+		conditions = append(conditions, "dataTask.done = 0")
+	}
+	if opts.DateFrom != "" {
+		conditions = append(conditions, "dataTask.date >= :date_from")
+		args = append(args, sql.Named("date_from", opts.DateFrom))
+	}
+	if opts.DateTo != "" {
+		conditions = append(conditions, "dataTask.date <= :date_to")
+		args = append(args, sql.Named("date_to", opts.DateTo))
+	}
+	if opts.Project != "" {
+		conditions = append(conditions, "dataTask.project = :project")
+		args = append(args, sql.Named("project", opts.Project))
+	}
+	if opts.TopLevel {
+		conditions = append(conditions, "dataTask.parent_id IS NULL")
+	}
+	if opts.List != "" {
+		conditions = append(conditions, "dataTask.list_id = (SELECT id FROM lists WHERE name = :list)")
+		args = append(args, sql.Named("list", opts.List))
+	}
+	if opts.Parent != 0 {
+		conditions = append(conditions, "dataTask.parent_id = :parent")
+		args = append(args, sql.Named("parent", opts.Parent))
+	}
+
+	hasTag := "EXISTS (SELECT 1 FROM task_tags JOIN tags ON tags.id = task_tags.tag_id WHERE task_tags.task_id = dataTask.id AND tags.name = :%s)"
+	for i, tag := range opts.Tags {
+		name := fmt.Sprintf("tag_%d", i)
+		conditions = append(conditions, fmt.Sprintf(hasTag, name))
+		args = append(args, sql.Named(name, tag))
+	}
+	for i, tag := range opts.ExcludeTags {
+		name := fmt.Sprintf("not_tag_%d", i)
+		conditions = append(conditions, "NOT "+fmt.Sprintf(hasTag, name))
+		args = append(args, sql.Named(name, tag))
+	}
+
+	var after Cursor
+	if opts.After != nil {
+		after = *opts.After
+	}
+
+	var keys []sortKey
+	if opts.ByPriority {
+		keys = append(keys, sortKey{column: "dataTask.priority", desc: true, after: after.Priority})
+	}
+	if search {
+		keys = append(keys, sortKey{column: "dataTask_fts.rank", after: after.Rank})
+	}
+	keys = append(keys,
+		sortKey{column: "dataTask.due", after: after.Due},
+		sortKey{column: "dataTask.id", after: after.ID})
+
+	if opts.After != nil {
+		condition, keyArgs := keysetCondition(keys)
+		conditions = append(conditions, condition)
+		args = append(args, keyArgs...)
+	}
+
+	var columns []string
+	for _, key := range keys {
+		if key.desc {
+			columns = append(columns, key.column+" DESC")
+		} else {
+			columns = append(columns, key.column)
+		}
+	}
+
+	return strings.Join(conditions, " AND "), strings.Join(columns, ", "), args
+}
+
+// keysetCondition строит условие "строка идёт после курсора" для сортировки по keys:
+// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ... (для сортировки по убыванию - "<")
+func keysetCondition(keys []sortKey) (string, []any) {
+
+	var alternatives []string
+	var args []any
+
+	for i, key := range keys {
+		var parts []string
+		for j, prev := range keys[:i] {
+			parts = append(parts, fmt.Sprintf("%s = :after_%d", prev.column, j))
+		}
+
+		op := ">"
+		if key.desc {
+			op = "<"
+		}
+		parts = append(parts, fmt.Sprintf("%s %s :after_%d", key.column, op, i))
+
+		alternatives = append(alternatives, "("+strings.Join(parts, " AND ")+")")
+		args = append(args, sql.Named(fmt.Sprintf("after_%d", i), key.after))
+	}
+
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+// checkAffected возвращает errNotFound, если запрос не затронул ни одной строки
+func checkAffected(res sql.Result) error {
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return errNotFound
+	}
+
+	return nil
+}
+
+// sqlLimit переводит лимит выборки в значение для LIMIT (-1 в SQLite - без ограничения)
+func sqlLimit(limit int) int {
+
+	if limit <= 0 {
+		return -1
+	}
+
+	return limit
+}
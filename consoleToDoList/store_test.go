@@ -0,0 +1,324 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// forEachStore выполняет test над пустым хранилищем каждой реализации: в памяти и SQLite во временной папке
+func forEachStore(t *testing.T, test func(t *testing.T, store TaskStore)) {
+
+	t.Run("memory", func(t *testing.T) {
+		test(t, newMemoryStore())
+	})
+
+	t.Run("sqlite", func(t *testing.T) {
+		store, err := openSQLiteStore(filepath.Join(t.TempDir(), "tasks.db"), time.UTC)
+		if err != nil {
+			t.Fatalf("openSQLiteStore: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		test(t, store)
+	})
+}
+
+// createTestTask добавляет во входящие задачу на весь день через days дней от сегодняшнего (по UTC)
+func createTestTask(t *testing.T, store TaskStore, content string, days int) int64 {
+
+	t.Helper()
+
+	task := Task{content: content, list: inboxList}
+	dayDeadline(time.Now().UTC().AddDate(0, 0, days), time.UTC).apply(&task)
+	id, err := store.Create(task)
+	if err != nil {
+		t.Fatalf("Create %q: %v", content, err)
+	}
+
+	return id
+}
+
+func TestStoreCompleteRecurring(t *testing.T) {
+
+	forEachStore(t, func(t *testing.T, store TaskStore) {
+
+		id := createTestTask(t, store, "standup", 1)
+		task, _ := store.Get(id)
+		task.recur = "daily"
+		store.Update(task)
+		store.AddReminders(id, []time.Duration{15 * time.Minute, time.Hour})
+
+		nextID, err := completeTask(store, id, time.Now().UTC())
+		if err != nil || nextID == 0 {
+			t.Fatalf("completeTask: %d, %v", nextID, err)
+		}
+		if task, _ = store.Get(id); !task.done {
+			t.Error("task is not done")
+		}
+		next, err := store.Get(nextID)
+		if err != nil || next.done || next.recur != "daily" || next.content != "standup" {
+			t.Errorf("next occurrence %+v, %v", next, err)
+		}
+		reminders, _ := store.Reminders(nextID)
+		if len(reminders) != 2 {
+			t.Errorf("next occurrence has %d reminders, want 2", len(reminders))
+		}
+	})
+}
+
+func TestStoreCompleteAtomic(t *testing.T) {
+
+	forEachStore(t, func(t *testing.T, store TaskStore) {
+
+		id := createTestTask(t, store, "standup", 1)
+
+		// повторение в несуществующий список добавить нельзя - задача остаётся невыполненной
+		_, err := store.Complete(id, time.Now(), &Task{content: "standup", list: "missing"})
+		if err == nil {
+			t.Fatal("completion with a bad next occurrence succeeded")
+		}
+		task, _ := store.Get(id)
+		if task.done {
+			t.Error("task is done although its next occurrence was not added")
+		}
+		if tasks, _ := store.List(ListOptions{}); len(tasks) != 1 {
+			t.Errorf("store has %d tasks, want 1", len(tasks))
+		}
+	})
+}
+
+func TestStoreEditAtomic(t *testing.T) {
+
+	forEachStore(t, func(t *testing.T, store TaskStore) {
+
+		err := store.CreateList("work")
+		if err != nil {
+			t.Fatalf("CreateList: %v", err)
+		}
+		id := createTestTask(t, store, "trip", 1)
+		other := createTestTask(t, store, "deploy", 1)
+
+		// родительская задача осталась во входящих, поэтому правка отклоняется целиком
+		task, _ := store.Get(id)
+		task.content = "holiday"
+		list, parent, tags := "work", other, []string{"travel"}
+		_, err = store.Edit(id, TaskEdit{Fields: &task, Tags: &tags, List: &list, Parent: &parent})
+		if err == nil {
+			t.Fatal("edit with a parent from another list succeeded")
+		}
+		task, _ = store.Get(id)
+		if task.content != "trip" || task.list != inboxList || task.parent != 0 || len(task.tags) != 0 {
+			t.Errorf("rejected edit changed the task: %+v", task)
+		}
+		if changes, _ := store.History(id); len(changes) != 1 {
+			t.Errorf("rejected edit left %d history entries, want 1", len(changes))
+		}
+
+		// удачная правка записывается в историю одним изменением
+		task.content = "holiday"
+		task.recur = "weekly"
+		done := true
+		nextID, err := store.Edit(id, TaskEdit{Fields: &task, Tags: &tags, List: &list, Done: &done, At: time.Now(),
+			Next: &Task{content: "holiday", date: task.date, recur: "weekly"}})
+		if err != nil || nextID == 0 {
+			t.Fatalf("Edit: %d, %v", nextID, err)
+		}
+		task, _ = store.Get(id)
+		if task.content != "holiday" || task.list != "work" || !task.done || len(task.tags) != 1 {
+			t.Errorf("edited task %+v", task)
+		}
+		if changes, _ := store.History(id); len(changes) != 2 {
+			t.Errorf("edit left %d history entries, want 2", len(changes))
+		}
+		if next, _ := store.Get(nextID); next.list != "work" || next.done {
+			t.Errorf("next occurrence %+v", next)
+		}
+	})
+}
+
+func TestStoreSearchFoldsYo(t *testing.T) {
+
+	forEachStore(t, func(t *testing.T, store TaskStore) {
+
+		id := createTestTask(t, store, "Ёлка и ёжик", 1)
+		createTestTask(t, store, "ежевика", 1)
+
+		for _, c := range []struct {
+			query   string
+			found   int
+			snippet string
+		}{
+			{"елка", 1, "*Ёлка* и ёжик"},
+			{"ЁЖИК", 1, "Ёлка и *ёжик*"},
+			{"ёж", 2, "Ёлка и *ёжик*"},
+		} {
+			tasks, err := store.Search(c.query, ListOptions{})
+			if err != nil {
+				t.Fatalf("Search %q: %v", c.query, err)
+			}
+			if len(tasks) != c.found {
+				t.Errorf("Search %q: %d tasks, want %d", c.query, len(tasks), c.found)
+				continue
+			}
+			for _, task := range tasks {
+				if task.id == id && task.snippet != c.snippet {
+					t.Errorf("Search %q: snippet %q, want %q", c.query, task.snippet, c.snippet)
+				}
+			}
+		}
+
+		// индекс следует за изменением описания
+		task, _ := store.Get(id)
+		task.content = "Ёмкость"
+		err := store.Update(task)
+		if err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if tasks, _ := store.Search("елка", ListOptions{}); len(tasks) != 0 {
+			t.Errorf("old content is still found: %+v", tasks)
+		}
+		if tasks, _ := store.Search("емк", ListOptions{}); len(tasks) != 1 {
+			t.Errorf("new content is found %d times, want 1", len(tasks))
+		}
+	})
+}
+
+func TestStorePaging(t *testing.T) {
+
+	forEachStore(t, func(t *testing.T, store TaskStore) {
+
+		// сроки повторяются, чтобы порядок внутри одного срока решал id
+		for i, priority := range []int{0, 2, 1, 2, 0, 1, 2} {
+			id := createTestTask(t, store, fmt.Sprintf("task %d", i), 1+i%3)
+			task, _ := store.Get(id)
+			task.priority = priority
+			store.Update(task)
+		}
+
+		for _, byPriority := range []bool{false, true} {
+			all, err := store.List(ListOptions{ByPriority: byPriority})
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+
+			var paged []int64
+			opts := ListOptions{Limit: 3, ByPriority: byPriority}
+			for {
+				page, err := store.List(opts)
+				if err != nil {
+					t.Fatalf("List page: %v", err)
+				}
+				for _, task := range page {
+					paged = append(paged, task.id)
+				}
+				if len(page) < opts.Limit {
+					break
+				}
+				after := cursorOf(page[len(page)-1])
+				opts.After = &after
+			}
+
+			if len(paged) != len(all) {
+				t.Fatalf("by priority %v: pages have %d tasks, want %d", byPriority, len(paged), len(all))
+			}
+			for i, task := range all {
+				if paged[i] != task.id {
+					t.Fatalf("by priority %v: pages %v differ from the full list at %d", byPriority, paged, i)
+				}
+			}
+		}
+	})
+}
+
+func TestStoreSearch(t *testing.T) {
+
+	forEachStore(t, func(t *testing.T, store TaskStore) {
+
+		createTestTask(t, store, "Молоко и хлеб", 1)
+		milk := createTestTask(t, store, "milk, milk and more milk", 2)
+		createTestTask(t, store, "buy milk", 3)
+		done := createTestTask(t, store, "milkshake", 4)
+		store.SetDone(done, true, time.Now())
+
+		for _, c := range []struct {
+			query string
+			opts  ListOptions
+			found int
+		}{
+			{"мол", ListOptions{}, 1},
+			{"МОЛОКО хлеб", ListOptions{}, 1},
+			{"молоко соль", ListOptions{}, 0},
+			{"milk", ListOptions{}, 3},
+			{"milk", ListOptions{OnlyOpen: true}, 2},
+			{"  ", ListOptions{}, 0},
+		} {
+			tasks, err := store.Search(c.query, c.opts)
+			if err != nil {
+				t.Fatalf("Search %q: %v", c.query, err)
+			}
+			if len(tasks) != c.found {
+				t.Errorf("Search %q: %d tasks, want %d", c.query, len(tasks), c.found)
+			}
+		}
+
+		// самая релевантная задача - первая, следующая страница продолжает с неё
+		first, err := store.Search("milk", ListOptions{Limit: 1, OnlyOpen: true})
+		if err != nil || len(first) != 1 || first[0].id != milk {
+			t.Fatalf("first page %+v, %v", first, err)
+		}
+		after := cursorOf(first[0])
+		rest, err := store.Search("milk", ListOptions{OnlyOpen: true, After: &after})
+		if err != nil || len(rest) != 1 || rest[0].content != "buy milk" || rest[0].snippet != "buy *milk*" {
+			t.Errorf("second page %+v, %v", rest, err)
+		}
+	})
+}
+
+func TestStoreHistoryRevert(t *testing.T) {
+
+	forEachStore(t, func(t *testing.T, store TaskStore) {
+
+		id := createTestTask(t, store, "draft", 1)
+		task, _ := store.Get(id)
+		task.content = "final"
+		task.priority = 2
+		store.Update(task)
+		store.AddTags(id, []string{"work"})
+		// то же состояние ещё раз - не изменение
+		store.Update(task)
+
+		changes, err := store.History(id)
+		if err != nil || len(changes) != 3 {
+			t.Fatalf("History: %d entries, %v", len(changes), err)
+		}
+		for i, action := range []string{actionCreate, actionUpdate, actionUpdate} {
+			if changes[i].version != i+1 || changes[i].action != action {
+				t.Errorf("change %d: version %d, action %q", i, changes[i].version, changes[i].action)
+			}
+		}
+		if changes[1].before.content != "draft" || changes[1].after.content != "final" {
+			t.Errorf("change 2: %q -> %q", changes[1].before.content, changes[1].after.content)
+		}
+
+		err = store.Revert(id, 1)
+		if err != nil {
+			t.Fatalf("Revert: %v", err)
+		}
+		task, _ = store.Get(id)
+		if task.content != "draft" || task.priority != 0 || len(task.tags) != 0 {
+			t.Errorf("reverted task %+v", task)
+		}
+		if changes, _ = store.History(id); len(changes) != 4 || changes[3].action != actionRevert {
+			t.Errorf("revert is not in the history: %+v", changes)
+		}
+
+		if err = store.Revert(id, 9); !errors.Is(err, errInvalidInput) {
+			t.Errorf("Revert to a missing version: %v", err)
+		}
+		if err = store.Revert(404, 1); !errors.Is(err, errNotFound) {
+			t.Errorf("Revert of a missing task: %v", err)
+		}
+	})
+}
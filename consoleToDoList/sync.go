@@ -0,0 +1,390 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// syncTask - задача из снимка БД для синхронизации
+type syncTask struct {
+	task   Task
+	parent string // uuid родительской задачи, пустая строка - задача верхнего уровня
+	depth  int    // глубина в иерархии: родительские задачи записываются раньше подзадач
+}
+
+// syncChoice - чем разрешён конфликт синхронизации
+type syncChoice int
+
+const (
+	syncSkip      syncChoice = iota // оставить обе версии как есть, конфликт останется до следующей синхронизации
+	syncKeepLocal                   // оставить версию этой БД и записать её в другую
+	syncKeepOther                   // взять версию из другой БД
+)
+
+// syncChoiceNames - описания решений для отчёта о синхронизации, индекс совпадает со значением syncChoice
+var syncChoiceNames = []string{"skipped", "kept this version", "took the other version"}
+
+// syncConflict - задача, которая изменилась в обеих БД после их прошлой синхронизации
+type syncConflict struct {
+	local  syncTask
+	other  syncTask
+	choice syncChoice
+}
+
+// syncResolver решает, какую версию задачи оставить при конфликте
+type syncResolver func(conflict syncConflict) (syncChoice, error)
+
+// syncPlan - что надо записать в обе БД, чтобы их задачи совпали
+type syncPlan struct {
+	localReplica string     // идентификаторы БД, под ними БД запоминают момент синхронизации друг с другом
+	otherReplica string     //
+	resetOther   bool       // другая БД - копия этой (с тем же идентификатором), при записи она получает новый идентификатор
+	base         time.Time  // момент прошлой синхронизации этих БД, нулевой - синхронизации не было
+	toLocal      []syncTask // версии задач из другой БД, которые записываются в эту
+	toOther      []syncTask // версии задач из этой БД, которые записываются в другую
+	conflicts    []syncConflict
+}
+
+// parseSyncPolicy возвращает решение конфликтов по политике: newer - оставить версию, изменённую позже,
+// local - версию этой БД, other - версию другой БД, skip - не трогать задачу до следующей синхронизации
+func parseSyncPolicy(in string) (syncResolver, error) {
+
+	var choose func(conflict syncConflict) syncChoice
+
+	switch strings.ToLower(strings.TrimSpace(in)) {
+	case "newer":
+		choose = func(conflict syncConflict) syncChoice {
+			if conflict.other.task.updatedAt.After(conflict.local.task.updatedAt) {
+				return syncKeepOther
+			}
+			return syncKeepLocal
+		}
+	case "local":
+		choose = func(syncConflict) syncChoice { return syncKeepLocal }
+	case "other":
+		choose = func(syncConflict) syncChoice { return syncKeepOther }
+	case "skip":
+		choose = func(syncConflict) syncChoice { return syncSkip }
+	default:
+		return nil, invalidInputf("bad sync policy %q, expected newer, local, other or skip", in)
+	}
+
+	return func(conflict syncConflict) (syncChoice, error) {
+		return choose(conflict), nil
+	}, nil
+}
+
+// syncDatabases синхронизирует хранилище store (БД в файле dbPath) с БД в файле otherPath: задачи сопоставляются
+// по uuid, задача, изменённая после прошлой синхронизации только в одной БД, переписывается в другую, а изменённая
+// в обеих - конфликт, его решает resolve. Перед записью сохраняются резервные копии обеих БД. Возвращает выполненный план.
+func syncDatabases(store TaskStore, dbPath, otherPath string, resolve syncResolver, now time.Time) (syncPlan, error) {
+
+	otherInfo, err := os.Stat(otherPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return syncPlan{}, invalidInputf("there is no database %s", otherPath)
+	}
+	if err != nil {
+		return syncPlan{}, err
+	}
+	info, err := os.Stat(dbPath)
+	if err == nil && os.SameFile(info, otherInfo) {
+		return syncPlan{}, invalidInputf("%s is the current database", otherPath)
+	}
+
+	other, err := openSQLiteStore(otherPath, now.Location())
+	if err != nil {
+		return syncPlan{}, err
+	}
+	defer other.Close()
+
+	plan, err := planSync(store, other, resolve)
+	if err != nil {
+		return plan, err
+	}
+
+	if len(plan.toLocal) > 0 || len(plan.toOther) > 0 {
+		_, err = createBackup(store, dbPath, now)
+		if err != nil {
+			return plan, fmt.Errorf("sync is cancelled, backup failed: %w", err)
+		}
+		_, err = createBackup(other, otherPath, now)
+		if err != nil {
+			return plan, fmt.Errorf("sync is cancelled, backup of %s failed: %w", otherPath, err)
+		}
+	}
+
+	return plan, plan.apply(store, other, time.Now())
+}
+
+// planSync сравнивает задачи двух хранилищ и решает, какие версии куда записать. Задача, которой нет в одной БД,
+// записывается в неё. Если версии различаются, побеждает та, что изменилась после прошлой синхронизации;
+// если изменились обе, но одна из них есть в истории другой (другая получена из неё изменениями), побеждает другая,
+// иначе это конфликт, его решает resolve.
+func planSync(local, other TaskStore, resolve syncResolver) (plan syncPlan, err error) {
+
+	plan.localReplica, err = local.Replica()
+	if err != nil {
+		return plan, err
+	}
+	plan.otherReplica, err = other.Replica()
+	if err != nil {
+		return plan, err
+	}
+	// копия файла БД получает идентификатор оригинала, а моменты синхронизации с ними надо различать.
+	// Новый идентификатор копия получает только в apply: план ещё может быть отменён, а резервной копии пока нет.
+	// Копия с этой БД ещё не синхронизировалась, поэтому base остаётся нулевым.
+	plan.resetOther = plan.otherReplica == plan.localReplica
+	if !plan.resetOther {
+		plan.base, err = local.SyncedAt(plan.otherReplica)
+		if err != nil {
+			return plan, err
+		}
+	}
+
+	localTasks, err := syncSnapshot(local)
+	if err != nil {
+		return plan, err
+	}
+	otherTasks, err := syncSnapshot(other)
+	if err != nil {
+		return plan, err
+	}
+
+	for _, id := range sortedUUIDs(localTasks, otherTasks) {
+		l, inLocal := localTasks[id]
+		o, inOther := otherTasks[id]
+
+		switch {
+		case !inOther:
+			plan.toOther = append(plan.toOther, l)
+			continue
+		case !inLocal:
+			plan.toLocal = append(plan.toLocal, o)
+			continue
+		case sameSyncState(l, o):
+			continue
+		}
+
+		localChanged := l.task.updatedAt.After(plan.base)
+		otherChanged := o.task.updatedAt.After(plan.base)
+		if localChanged == otherChanged {
+			localChanged, otherChanged, err = compareHistories(local, other, l, o)
+			if err != nil {
+				return plan, err
+			}
+		}
+
+		choice := syncKeepLocal
+		if otherChanged && !localChanged {
+			choice = syncKeepOther
+		}
+		if localChanged == otherChanged {
+			conflict := syncConflict{local: l, other: o}
+			choice, err = resolve(conflict)
+			if err != nil {
+				return plan, err
+			}
+			conflict.choice = choice
+			plan.conflicts = append(plan.conflicts, conflict)
+		}
+
+		switch choice {
+		case syncKeepLocal:
+			plan.toOther = append(plan.toOther, l)
+		case syncKeepOther:
+			plan.toLocal = append(plan.toLocal, o)
+		}
+	}
+
+	return plan, nil
+}
+
+// compareHistories уточняет, какая из различающихся версий задачи изменилась: если версия одной БД встречается
+// в истории задачи другой, изменилась только другая. Иначе считается, что изменились обе.
+func compareHistories(local, other TaskStore, l, o syncTask) (localChanged, otherChanged bool, err error) {
+
+	oInLocal, err := inHistory(local, l.task.id, o.task)
+	if err != nil || oInLocal {
+		return true, false, err
+	}
+
+	lInOther, err := inHistory(other, o.task.id, l.task)
+	if err != nil || lInOther {
+		return false, true, err
+	}
+
+	return true, true, nil
+}
+
+// inHistory сообщает, что версия version встречается среди прежних состояний задачи id хранилища store
+func inHistory(store TaskStore, id int64, version Task) (bool, error) {
+
+	changes, err := store.History(id)
+	if err != nil {
+		return false, err
+	}
+
+	for _, c := range changes {
+		if sameState(syncComparable(c.after), syncComparable(version)) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// apply записывает версии задач в обе БД (родительские задачи - раньше подзадач) и, если ни один конфликт
+// не пропущен, запоминает в обеих момент синхронизации now. Копия этой БД сначала получает новый идентификатор.
+func (plan syncPlan) apply(local, other TaskStore, now time.Time) error {
+
+	if plan.resetOther {
+		var err error
+		plan.otherReplica, err = other.ResetReplica()
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, write := range []struct {
+		store TaskStore
+		tasks []syncTask
+	}{{local, plan.toLocal}, {other, plan.toOther}} {
+		slices.SortStableFunc(write.tasks, func(a, b syncTask) int { return a.depth - b.depth })
+		for _, t := range write.tasks {
+			err := write.store.ApplySync(t.task, t.parent)
+			if err != nil {
+				return fmt.Errorf("task %q: %w", t.task.content, err)
+			}
+		}
+	}
+
+	// пропущенные конфликты должны остаться конфликтами и при следующей синхронизации
+	if plan.skipped() > 0 {
+		return nil
+	}
+
+	err := local.SetSyncedAt(plan.otherReplica, now)
+	if err != nil {
+		return err
+	}
+
+	return other.SetSyncedAt(plan.localReplica, now)
+}
+
+// skipped возвращает количество пропущенных конфликтов
+func (plan syncPlan) skipped() int {
+
+	count := 0
+	for _, c := range plan.conflicts {
+		if c.choice == syncSkip {
+			count++
+		}
+	}
+
+	return count
+}
+
+// syncSnapshot возвращает все задачи хранилища (в том числе выполненные и из корзины) по их uuid
+func syncSnapshot(store TaskStore) (map[string]syncTask, error) {
+
+	allTasks, err := store.List(ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	trash, err := store.List(ListOptions{Trash: true})
+	if err != nil {
+		return nil, err
+	}
+	allTasks = append(allTasks, trash...)
+
+	byID := make(map[int64]Task, len(allTasks))
+	for _, task := range allTasks {
+		byID[task.id] = task
+	}
+
+	snapshot := make(map[string]syncTask, len(allTasks))
+	for _, task := range allTasks {
+		t := syncTask{task: task}
+		for parent, ok := byID[task.parent]; ok; parent, ok = byID[parent.parent] {
+			if t.depth == 0 {
+				t.parent = parent.uuid
+			}
+			t.depth++
+		}
+		snapshot[task.uuid] = t
+	}
+
+	return snapshot, nil
+}
+
+// sortedUUIDs возвращает uuid задач обоих снимков без повторов в алфавитном порядке
+func sortedUUIDs(a, b map[string]syncTask) []string {
+
+	ids := make([]string, 0, len(a)+len(b))
+	for id := range a {
+		ids = append(ids, id)
+	}
+	for id := range b {
+		if _, ok := a[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	return ids
+}
+
+// sameSyncState сообщает, что версии задачи в двух БД совпадают. id родительских задач в разных БД разные,
+// поэтому сравниваются их uuid.
+func sameSyncState(a, b syncTask) bool {
+
+	return a.parent == b.parent && sameState(syncComparable(a.task), syncComparable(b.task))
+}
+
+// syncComparable возвращает задачу без полей, которые в разных БД различаются и у одинаковых версий:
+// id родительской задачи и регистра букв в имени списка
+func syncComparable(task Task) Task {
+
+	task.parent = 0
+	task.list = strings.ToLower(task.list)
+
+	return task
+}
+
+// describeConflict описывает конфликт: описание задачи, когда она изменилась в каждой БД и чем различаются версии
+// ("было здесь -> стало там"), сроки - в часовом поясе loc
+func describeConflict(conflict syncConflict, loc *time.Location) string {
+
+	l, o := conflict.local.task, conflict.other.task
+	diff := describeChange(Change{action: actionSync, before: syncComparable(l), after: syncComparable(o)}, loc)
+	if conflict.local.parent != conflict.other.parent {
+		diff = strings.TrimPrefix(diff+", parent task differs", ", ")
+	}
+
+	return fmt.Sprintf("task %q changed here at %s and there at %s: %s", l.content,
+		l.updatedAt.In(loc).Format(dateLayout+" "+timeFormat), o.updatedAt.In(loc).Format(dateLayout+" "+timeFormat), diff)
+}
+
+// printSyncReport выводит итог синхронизации с БД name: конфликты с принятыми решениями и сколько задач куда записано
+func printSyncReport(w io.Writer, plan syncPlan, name string, loc *time.Location) {
+
+	for _, c := range plan.conflicts {
+		fmt.Fprintf(w, "conflict: %s; %s\n", describeConflict(c, loc), syncChoiceNames[c.choice])
+	}
+
+	if len(plan.toLocal) == 0 && len(plan.toOther) == 0 && len(plan.conflicts) == 0 {
+		fmt.Fprintf(w, "Nothing to sync, the tasks in %s are the same.\n", name)
+		return
+	}
+
+	fmt.Fprintf(w, "Synced with %s: %d tasks written there, %d tasks written here, %d conflicts.\n",
+		name, len(plan.toOther), len(plan.toLocal), len(plan.conflicts))
+	if skipped := plan.skipped(); skipped > 0 {
+		fmt.Fprintf(w, "%d conflicts skipped, they will come up again on the next sync.\n", skipped)
+	}
+}
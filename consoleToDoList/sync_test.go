@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSyncCopyGetsReplicaOnApply(t *testing.T) {
+
+	dir := t.TempDir()
+	path, copyPath := filepath.Join(dir, "tasks.db"), filepath.Join(dir, "copy.db")
+	local, err := openSQLiteStore(path, time.UTC)
+	if err != nil {
+		t.Fatalf("openSQLiteStore: %v", err)
+	}
+	defer local.Close()
+	createTestTask(t, local, "shared", 1)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	err = os.WriteFile(copyPath, data, 0o600)
+	if err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	other, err := openSQLiteStore(copyPath, time.UTC)
+	if err != nil {
+		t.Fatalf("openSQLiteStore: %v", err)
+	}
+	defer other.Close()
+	createTestTask(t, other, "only in copy", 1)
+
+	// планирование ничего не меняет: синхронизацию ещё можно отменить
+	replica, _ := local.Replica()
+	plan, err := planSync(local, other, func(syncConflict) (syncChoice, error) { return syncSkip, nil })
+	if err != nil {
+		t.Fatalf("planSync: %v", err)
+	}
+	if got, _ := other.Replica(); got != replica {
+		t.Fatalf("planning changed the replica of the copy to %s", got)
+	}
+
+	err = plan.apply(local, other, time.Now())
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	otherReplica, _ := other.Replica()
+	if otherReplica == replica {
+		t.Error("the copy kept the replica of the original")
+	}
+	if at, _ := local.SyncedAt(otherReplica); at.IsZero() {
+		t.Error("the sync with the copy is not recorded")
+	}
+	if tasks, _ := local.List(ListOptions{}); len(tasks) != 2 {
+		t.Errorf("local store has %d tasks, want 2", len(tasks))
+	}
+}
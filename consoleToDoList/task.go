@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// уровни важности задачи
+const (
+	priorityNone   = iota // важность не указана
+	priorityLow           // низкая
+	priorityMedium        // средняя
+	priorityHigh          // высокая
+)
+
+// priorityNames - названия уровней важности, индекс совпадает со значением уровня
+var priorityNames = []string{"none", "low", "medium", "high"}
+
+// parsePriority разбирает важность, заданную названием или числом (пустая строка - важность не указана)
+func parsePriority(in string) (int, error) {
+
+	in = strings.ToLower(strings.TrimSpace(in))
+	if in == "" {
+		return priorityNone, nil
+	}
+
+	for level, name := range priorityNames {
+		if in == name || in == name[:1] {
+			return level, nil
+		}
+	}
+
+	level, err := strconv.Atoi(in)
+	if err != nil || level < priorityNone || level > priorityHigh {
+		return 0, invalidInputf("bad priority %q, expected one of %s or 0-%d", in, strings.Join(priorityNames, ", "), priorityHigh)
+	}
+
+	return level, nil
+}
+
+// priorityMark возвращает краткое обозначение важности для вывода в таблице
+func priorityMark(level int) string {
+
+	if level <= priorityNone {
+		return ""
+	}
+
+	return strings.Repeat("!", level)
+}
+
+// normalizeTags приводит метки к нижнему регистру, убирает повторы и необязательный "+" в начале.
+// Метка не может быть пустой, содержать пробелы или запятые и начинаться с "-".
+func normalizeTags(in []string) ([]string, error) {
+
+	var tags []string
+
+	for _, tag := range in {
+		tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "+"))
+		if tag == "" || strings.HasPrefix(tag, "-") || strings.ContainsAny(tag, ", \t") {
+			return nil, invalidInputf("bad tag %q, tags are single words without commas", tag)
+		}
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	slices.Sort(tags)
+
+	return tags, nil
+}
+
+// normalizeProject проверяет название проекта: одно слово, пустая строка - без проекта
+func normalizeProject(in string) (string, error) {
+
+	project := strings.TrimSpace(in)
+	if strings.ContainsAny(project, " \t") {
+		return "", invalidInputf("bad project %q, project name is a single word", project)
+	}
+
+	return project, nil
+}
+
+// completeTask отмечает задачу выполненной. Для повторяющейся задачи создаётся следующее повторение
+// (не раньше сегодняшнего дня, в то же время суток, с теми же напоминаниями), его id возвращается; для обычной задачи возвращается 0.
+// Сегодняшний день и время суток определяются по часовому поясу момента now.
+func completeTask(store TaskStore, id int64, now time.Time) (int64, error) {
+
+	task, err := store.Get(id)
+	if err != nil {
+		return 0, err
+	}
+
+	if task.done {
+		return 0, nil
+	}
+
+	next, err := nextOccurrence(task, now)
+	if err != nil {
+		return 0, err
+	}
+
+	// выполнение и следующее повторение записываются вместе: сбой не должен оставить выполненную задачу без повторения
+	return store.Complete(id, now, next)
+}
+
+// nextOccurrence возвращает следующее повторение задачи, которое создаётся при её выполнении в момент now:
+// не раньше сегодняшнего дня, в то же время суток. nil - задача не повторяется.
+func nextOccurrence(task Task, now time.Time) (*Task, error) {
+
+	if task.recur == "" {
+		return nil, nil
+	}
+
+	rule, err := parseRecurrence(task.recur)
+	if err != nil {
+		return nil, fmt.Errorf("task %d: %w", task.id, err)
+	}
+
+	date, err := time.Parse(dateFormfat, task.date)
+	if err != nil {
+		return nil, fmt.Errorf("task %d: bad date %q: %w", task.id, task.date, err)
+	}
+
+	today := dayOf(now)
+	next := rule.next(date)
+	for next.Before(today) {
+		next = rule.next(next)
+	}
+
+	nextTask := Task{
+		content:  task.content,
+		priority: task.priority,
+		recur:    task.recur,
+		project:  task.project,
+		tags:     task.tags,
+		parent:   task.parent,
+		list:     task.list,
+	}
+	deadlineOf(task).onDay(next, now.Location()).apply(&nextTask)
+
+	return &nextTask, nil
+}
+
+// replaceTags заменяет метки задачи набором tags: лишние снимаются, недостающие добавляются
+func replaceTags(store TaskStore, id int64, tags []string) error {
+
+	current, err := store.Get(id)
+	if err != nil {
+		return err
+	}
+
+	var stale []string
+	for _, tag := range current.tags {
+		if !slices.Contains(tags, tag) {
+			stale = append(stale, tag)
+		}
+	}
+
+	if len(stale) > 0 {
+		err = store.RemoveTags(id, stale)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(tags) == 0 {
+		return nil
+	}
+
+	return store.AddTags(id, tags)
+}
+
+// parseParent разбирает id родительской задачи: число или none (0, пустая строка) - задача верхнего уровня
+func parseParent(in string) (int64, error) {
+
+	in = strings.TrimSpace(in)
+	if in == "" || strings.EqualFold(in, "none") {
+		return 0, nil
+	}
+
+	parent, err := strconv.ParseInt(in, 10, 64)
+	if err != nil || parent < 0 {
+		return 0, invalidInputf("bad parent task %q, expected task id or none", in)
+	}
+
+	return parent, nil
+}
+
+// parseChildPolicy разбирает, что делать с подзадачами удаляемой задачи task: delete - удалить вместе с ней,
+// reparent - поднять на её уровень. Если подзадачи есть, выбор обязателен.
+func parseChildPolicy(in string, task Task) (childPolicy, error) {
+
+	switch strings.ToLower(strings.TrimSpace(in)) {
+	case "delete":
+		return childrenDelete, nil
+	case "reparent":
+		return childrenReparent, nil
+	case "":
+		if task.subtasks == 0 {
+			return childrenDelete, nil
+		}
+		return 0, invalidInputf("task %d has %d subtasks, choose whether to delete them too or to reparent them", task.id, task.subtasks)
+	}
+
+	return 0, invalidInputf("bad subtasks action %q, expected delete or reparent", in)
+}
+
+// formatProgress возвращает, сколько подзадач выполнено: "3/5", пустая строка - подзадач нет
+func formatProgress(task Task) string {
+
+	if task.subtasks == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%d/%d", task.subtasksDone, task.subtasks)
+}
+
+// reopenTask снимает с задачи отметку о выполнении
+func reopenTask(store TaskStore, id int64) error {
+
+	return store.SetDone(id, false, time.Time{})
+}
+
+// occurrence описывает строку представления upcoming: саму задачу или её будущее повторение
+type occurrence struct {
+	task    Task
+	date    string // дата повторения в формате dateFormfat
+	planned bool   // повторение ещё не создано, оно появится после выполнения предыдущего
+}
+
+// upcomingTasks возвращает невыполненные задачи на ближайшие days дней, начиная с сегодняшнего,
+// вместе с повторениями повторяющихся задач, которые придутся на этот период
+func upcomingTasks(store TaskStore, list string, now time.Time, days int) ([]occurrence, error) {
+
+	today := dayOf(now)
+	end := today.AddDate(0, 0, days-1)
+
+	// просроченные задачи в представление не попадают, но повторения просроченной повторяющейся задачи - попадают
+	allTasks, err := store.List(ListOptions{
+		OnlyOpen: true,
+		DateTo:   end.Format(dateFormfat),
+		List:     list,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var upcoming []occurrence
+
+	for _, task := range allTasks {
+		if task.date >= today.Format(dateFormfat) {
+			upcoming = append(upcoming, occurrence{task: task, date: task.date})
+		}
+
+		if task.recur == "" {
+			continue
+		}
+
+		rule, err := parseRecurrence(task.recur)
+		if err != nil {
+			return nil, fmt.Errorf("task %d: %w", task.id, err)
+		}
+		date, err := time.Parse(dateFormfat, task.date)
+		if err != nil {
+			return nil, fmt.Errorf("task %d: bad date %q: %w", task.id, task.date, err)
+		}
+
+		for _, next := range rule.occurrences(date, end)[1:] {
+			if next.Before(today) {
+				continue
+			}
+			upcoming = append(upcoming, occurrence{task: task, date: next.Format(dateFormfat), planned: true})
+		}
+	}
+
+	// по дню, задачи одного дня - по времени срока, задачи на весь день - первыми
+	sort.SliceStable(upcoming, func(i, j int) bool {
+		if upcoming[i].date != upcoming[j].date {
+			return upcoming[i].date < upcoming[j].date
+		}
+		return dueClock(upcoming[i].task, now.Location()) < dueClock(upcoming[j].task, now.Location())
+	})
+
+	return upcoming, nil
+}
+
+// dueClock возвращает время срока задачи "hh:mm" в часовом поясе loc, пустая строка - задача на весь день
+func dueClock(task Task, loc *time.Location) string {
+
+	if task.allDay {
+		return ""
+	}
+
+	return task.due.In(loc).Format(timeFormat)
+}
+
+// dayOf возвращает начало календарного дня момента t в виде, в котором time.Parse возвращает даты задач
+func dayOf(t time.Time) time.Time {
+
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,712 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// управляющие последовательности терминала
+const (
+	escAltScreen  = "\x1b[?1049h" // переключиться на отдельный экран (после выхода терминал вернётся к прежнему выводу)
+	escMainScreen = "\x1b[?1049l" // вернуться на основной экран
+	escHideCursor = "\x1b[?25l"
+	escShowCursor = "\x1b[?25h"
+	escHome       = "\x1b[H"  // курсор в левый верхний угол
+	escClearLine  = "\x1b[K"  // стереть строку до конца
+	escReverse    = "\x1b[7m" // инверсия цвета для выбранной строки
+	escBold       = "\x1b[1m"
+	escRed        = "\x1b[31m" // красный текст: просроченные задачи в таблице интерактивного режима
+	escReset      = "\x1b[0m"
+)
+
+// tuiDetailLines - высота панели с подробностями о выбранной задаче, строк
+const tuiDetailLines = 7
+
+// tuiHelp - подсказка по клавишам в строке состояния
+const tuiHelp = "↑↓ move  a add  e edit  x done/reopen  d delete  / search  esc clear  q quit"
+
+// tuiMode - что сейчас делает полноэкранный режим
+type tuiMode int
+
+const (
+	tuiBrowse  tuiMode = iota // просмотр списка
+	tuiSearch                 // ввод поискового запроса
+	tuiForm                   // ввод полей новой или изменяемой задачи
+	tuiConfirm                // подтверждение удаления
+)
+
+// tuiKey - нажатая клавиша: символ r или название специальной клавиши
+type tuiKey struct {
+	r    rune
+	name string // up, down, pgup, pgdn, home, end, enter, esc, backspace, ctrl-c; пустое - символ r
+}
+
+// formField - поле формы добавления или изменения задачи
+type formField struct {
+	label string
+	value []rune
+	check func(in string) error // проверяет значение перед переходом к следующему полю
+}
+
+// tui - полноэкранный режим: список задач по сроку, панель подробностей, строка поиска и формы.
+// Работает с тем же хранилищем, что и остальные режимы.
+type tui struct {
+	store TaskStore
+	list  string // список задач, с которым работает режим
+	loc   *time.Location
+
+	tasks  []Task // задачи списка (все или найденные по query), по сроку
+	cursor int    // индекс выбранной задачи
+	top    int    // индекс первой видимой задачи
+	query  []rune // поисковый запрос, пустой - все задачи
+
+	mode     tuiMode
+	form     []formField
+	field    int    // поле формы, которое сейчас вводится
+	editID   int64  // id изменяемой задачи, 0 - форма добавления
+	editDate string // срок изменяемой задачи в том виде, в каком он подставлен в форму
+
+	status        string // сообщение в строке состояния до следующего нажатия
+	width, height int
+}
+
+// runTUI запускает полноэкранный режим со списком задач list в терминале in/out, сроки показываются в часовом поясе loc
+func runTUI(store TaskStore, list string, loc *time.Location, in, out *os.File) error {
+
+	fd := int(in.Fd())
+	if !term.IsTerminal(fd) {
+		return invalidInputf("tui needs an interactive terminal")
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	fmt.Fprint(out, escAltScreen+escHideCursor)
+	defer fmt.Fprint(out, escShowCursor+escMainScreen)
+
+	t := &tui{store: store, list: list, loc: loc}
+	err = t.reload(0)
+	if err != nil {
+		return err
+	}
+
+	keys := make(chan tuiKey)
+	go readKeys(in, keys)
+
+	// размер окна проверяется периодически: сигнал SIGWINCH есть не во всех системах
+	resize := time.NewTicker(250 * time.Millisecond)
+	defer resize.Stop()
+
+	for {
+		width, height, err := term.GetSize(int(out.Fd()))
+		if err != nil {
+			width, height = 80, 24
+		}
+		if width != t.width || height != t.height {
+			t.width, t.height = width, height
+			t.draw(out)
+		}
+
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			quit := t.handle(key)
+			if quit {
+				return nil
+			}
+			t.draw(out)
+		case <-resize.C:
+		}
+	}
+}
+
+// readKeys читает нажатия из r и отправляет их в keys, канал закрывается, когда ввод заканчивается
+func readKeys(r io.Reader, keys chan<- tuiKey) {
+
+	defer close(keys)
+
+	buf := make([]byte, 256)
+	for {
+		n, err := r.Read(buf)
+		for _, key := range parseKeys(buf[:n]) {
+			keys <- key
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// parseKeys разбирает прочитанные из терминала байты на нажатия: символы UTF-8, управляющие клавиши
+// и последовательности ESC [ ... для стрелок и клавиш перемещения
+func parseKeys(b []byte) []tuiKey {
+
+	var keys []tuiKey
+
+	for len(b) > 0 {
+		switch {
+		case b[0] == 0x1b && len(b) > 2 && (b[1] == '[' || b[1] == 'O'):
+			end := 2
+			for end < len(b) && (b[end] < 0x40 || b[end] > 0x7e) {
+				end++
+			}
+			if end < len(b) {
+				end++
+			}
+			if name := escapeKeys[string(b[2:end])]; name != "" {
+				keys = append(keys, tuiKey{name: name})
+			}
+			b = b[end:]
+		case b[0] == 0x1b:
+			keys = append(keys, tuiKey{name: "esc"})
+			b = b[1:]
+		case b[0] == '\r' || b[0] == '\n':
+			keys = append(keys, tuiKey{name: "enter"})
+			b = b[1:]
+		case b[0] == 0x7f || b[0] == 0x08:
+			keys = append(keys, tuiKey{name: "backspace"})
+			b = b[1:]
+		case b[0] == 0x03:
+			keys = append(keys, tuiKey{name: "ctrl-c"})
+			b = b[1:]
+		case b[0] < 0x20:
+			b = b[1:]
+		default:
+			r, size := utf8.DecodeRune(b)
+			if r != utf8.RuneError {
+				keys = append(keys, tuiKey{r: r})
+			}
+			b = b[size:]
+		}
+	}
+
+	return keys
+}
+
+// escapeKeys - клавиши перемещения по окончаниям последовательностей ESC [ и ESC O
+var escapeKeys = map[string]string{
+	"A": "up", "B": "down", "H": "home", "F": "end",
+	"1~": "home", "7~": "home", "4~": "end", "8~": "end",
+	"5~": "pgup", "6~": "pgdn",
+}
+
+// reload заново выбирает задачи списка и выбирает задачу с id selectID (0 - оставляет позицию курсора)
+func (t *tui) reload(selectID int64) error {
+
+	var allTasks []Task
+	var err error
+
+	query := strings.TrimSpace(string(t.query))
+	if query == "" {
+		allTasks, err = t.store.List(ListOptions{List: t.list})
+	} else {
+		allTasks, err = t.store.Search(query, ListOptions{List: t.list})
+		// найденные задачи показываются в том же порядке, что и весь список
+		slices.SortFunc(allTasks, func(a, b Task) int {
+			return cmp.Or(a.due.Compare(b.due), cmp.Compare(a.id, b.id))
+		})
+	}
+	if err != nil {
+		return err
+	}
+	t.tasks = allTasks
+
+	if selectID != 0 {
+		for i, task := range t.tasks {
+			if task.id == selectID {
+				t.cursor = i
+			}
+		}
+	}
+	t.cursor = max(0, min(t.cursor, len(t.tasks)-1))
+
+	return nil
+}
+
+// selected возвращает выбранную задачу, ok == false - список пуст
+func (t *tui) selected() (Task, bool) {
+
+	if len(t.tasks) == 0 {
+		return Task{}, false
+	}
+
+	return t.tasks[t.cursor], true
+}
+
+// listLines возвращает, сколько строк экрана отведено под задачи
+func (t *tui) listLines() int {
+
+	// заголовок, шапка таблицы, разделитель, панель подробностей и строка состояния
+	return max(1, t.height-4-tuiDetailLines)
+}
+
+// handle обрабатывает нажатие, true - пора выходить
+func (t *tui) handle(key tuiKey) bool {
+
+	t.status = ""
+
+	switch t.mode {
+	case tuiSearch:
+		t.handleSearch(key)
+	case tuiForm:
+		t.handleForm(key)
+	case tuiConfirm:
+		t.handleConfirm(key)
+	default:
+		return t.handleBrowse(key)
+	}
+
+	return false
+}
+
+// handleBrowse обрабатывает нажатие при просмотре списка
+func (t *tui) handleBrowse(key tuiKey) bool {
+
+	page := t.listLines()
+
+	switch {
+	case key.name == "ctrl-c" || key.r == 'q':
+		return true
+	case key.name == "up" || key.r == 'k':
+		t.move(-1)
+	case key.name == "down" || key.r == 'j':
+		t.move(1)
+	case key.name == "pgup":
+		t.move(-page)
+	case key.name == "pgdn" || key.r == ' ':
+		t.move(page)
+	case key.name == "home" || key.r == 'g':
+		t.move(-len(t.tasks))
+	case key.name == "end" || key.r == 'G':
+		t.move(len(t.tasks))
+	case key.r == '/':
+		t.mode = tuiSearch
+	case key.name == "esc":
+		if len(t.query) > 0 {
+			t.query = nil
+			t.report(t.reload(0))
+		}
+	case key.r == 'a':
+		t.openForm(Task{})
+	case key.r == 'e' || key.name == "enter":
+		if task, ok := t.selected(); ok {
+			t.openForm(task)
+		}
+	case key.r == 'd':
+		if _, ok := t.selected(); ok {
+			t.mode = tuiConfirm
+		}
+	case key.r == 'x':
+		t.toggleDone()
+	case key.r == 'r':
+		t.report(t.reload(0))
+	}
+
+	return false
+}
+
+// move сдвигает курсор на delta задач
+func (t *tui) move(delta int) {
+
+	t.cursor = max(0, min(t.cursor+delta, len(t.tasks)-1))
+}
+
+// handleSearch обрабатывает нажатие в строке поиска: список обновляется с каждым символом
+func (t *tui) handleSearch(key tuiKey) {
+
+	switch key.name {
+	case "enter":
+		t.mode = tuiBrowse
+		return
+	case "esc", "ctrl-c":
+		t.mode = tuiBrowse
+		t.query = nil
+	case "backspace":
+		if len(t.query) > 0 {
+			t.query = t.query[:len(t.query)-1]
+		}
+	case "":
+		t.query = append(t.query, key.r)
+	default:
+		return
+	}
+
+	t.cursor = 0
+	t.report(t.reload(0))
+}
+
+// handleConfirm обрабатывает ответ на вопрос об удалении выбранной задачи: y - удалить (вместе с подзадачами),
+// m - удалить, подняв подзадачи на её уровень
+func (t *tui) handleConfirm(key tuiKey) {
+
+	t.mode = tuiBrowse
+
+	task, ok := t.selected()
+	children := childrenDelete
+	switch {
+	case ok && key.r == 'y':
+	case ok && key.r == 'm' && task.subtasks > 0:
+		children = childrenReparent
+	default:
+		t.status = "Not deleted."
+		return
+	}
+
+	err := t.store.Delete(task.id, time.Now().In(t.loc), children)
+	if err != nil {
+		t.report(err)
+		return
+	}
+
+	t.status = fmt.Sprintf("Task %d moved to trash, restore %d brings it back.", task.id, task.id)
+	t.report(t.reload(0))
+}
+
+// toggleDone отмечает выбранную задачу выполненной (для повторяющейся создаётся следующее повторение) или снова открывает её
+func (t *tui) toggleDone() {
+
+	task, ok := t.selected()
+	if !ok {
+		return
+	}
+
+	if task.done {
+		err := reopenTask(t.store, task.id)
+		if err != nil {
+			t.report(err)
+			return
+		}
+		t.status = fmt.Sprintf("Task %d reopened.", task.id)
+		t.report(t.reload(task.id))
+		return
+	}
+
+	nextID, err := completeTask(t.store, task.id, time.Now().In(t.loc))
+	if err != nil {
+		t.report(err)
+		return
+	}
+
+	t.status = fmt.Sprintf("Task %d completed.", task.id)
+	if nextID != 0 {
+		t.status += fmt.Sprintf(" Next occurrence: task %d.", nextID)
+	}
+	t.report(t.reload(task.id))
+}
+
+// openForm открывает форму добавления (task.id == 0) или изменения задачи task
+func (t *tui) openForm(task Task) {
+
+	now := func() time.Time { return time.Now().In(t.loc) }
+
+	date := task.date
+	if task.id != 0 && !task.allDay {
+		date = formatDue(task, t.loc)
+	}
+	priority := ""
+	if task.priority != priorityNone {
+		priority = priorityNames[task.priority]
+	}
+
+	t.editID = task.id
+	t.editDate = date
+	t.field = 0
+	t.mode = tuiForm
+	t.form = []formField{
+		{label: "Content", value: []rune(task.content), check: func(in string) error {
+			if strings.TrimSpace(in) == "" {
+				return invalidInputf("task content is empty")
+			}
+			return nil
+		}},
+		{label: "Date (" + datePattern() + " [hh:mm], tomorrow, +3d...)", value: []rune(date), check: func(in string) error {
+			// неизменённый срок задачи принимается, даже если он уже прошёл
+			if t.dateKept(in) {
+				return nil
+			}
+			d, err := validateDeadline(in, now())
+			if err == nil {
+				t.status = fmt.Sprintf(resolvedDateMessage.String(), d.describe(t.loc))
+			}
+			return err
+		}},
+		{label: "Priority (none, low, medium, high)", value: []rune(priority), check: func(in string) error {
+			_, err := parsePriority(in)
+			return err
+		}},
+		{label: "Project", value: []rune(task.project), check: func(in string) error {
+			_, err := normalizeProject(in)
+			return err
+		}},
+		{label: "Tags", value: []rune(strings.Join(task.tags, " ")), check: func(in string) error {
+			_, err := normalizeTags(strings.Fields(in))
+			return err
+		}},
+	}
+}
+
+// handleForm обрабатывает нажатие в форме: Enter проверяет поле и переходит к следующему, после последнего
+// задача сохраняется; Esc закрывает форму без сохранения
+func (t *tui) handleForm(key tuiKey) {
+
+	field := &t.form[t.field]
+
+	switch key.name {
+	case "esc", "ctrl-c":
+		t.mode = tuiBrowse
+		t.status = "Cancelled."
+	case "backspace":
+		if len(field.value) > 0 {
+			field.value = field.value[:len(field.value)-1]
+		}
+	case "enter":
+		err := field.check(string(field.value))
+		if err != nil {
+			t.status = "error: " + err.Error()
+			return
+		}
+		if t.field < len(t.form)-1 {
+			t.field++
+			return
+		}
+		t.mode = tuiBrowse
+		t.saveForm()
+	case "":
+		field.value = append(field.value, key.r)
+	}
+}
+
+// saveForm сохраняет задачу из заполненной и проверенной формы
+func (t *tui) saveForm() {
+
+	value := func(i int) string { return strings.TrimSpace(string(t.form[i].value)) }
+	now := time.Now().In(t.loc)
+
+	task := Task{}
+	var err error
+	if t.editID != 0 {
+		task, err = t.store.Get(t.editID)
+		if err != nil {
+			t.report(err)
+			return
+		}
+	}
+
+	// поля уже проверены, ошибки здесь возможны только если время прошло, пока форма заполнялась
+	if !t.dateKept(value(1)) {
+		var due deadline
+		due, err = validateDeadline(value(1), now)
+		if err != nil {
+			t.report(err)
+			return
+		}
+		due.apply(&task)
+	}
+	task.content = value(0)
+	task.priority, _ = parsePriority(value(2))
+	task.project, _ = normalizeProject(value(3))
+	tags, _ := normalizeTags(strings.Fields(value(4)))
+
+	id := task.id
+	if id == 0 {
+		task.tags = tags
+		task.list = t.list
+		id, err = t.store.Create(task)
+		t.status = fmt.Sprintf("Task %d added.", id)
+	} else {
+		err = t.store.Update(task)
+		if err == nil {
+			err = replaceTags(t.store, id, tags)
+		}
+		t.status = fmt.Sprintf("Task %d updated.", id)
+	}
+	if err != nil {
+		t.report(err)
+		return
+	}
+
+	t.report(t.reload(id))
+}
+
+// dateKept сообщает, что в форме изменения задачи срок оставлен прежним: тогда он не проверяется и не меняется
+func (t *tui) dateKept(in string) bool {
+
+	return t.editID != 0 && strings.TrimSpace(in) == t.editDate
+}
+
+// report показывает ошибку в строке состояния
+func (t *tui) report(err error) {
+
+	switch {
+	case err == nil:
+	case errors.Is(err, errNotFound):
+		t.status = errorIdMessage.String()
+	case errors.Is(err, errStorage):
+		t.status = fmt.Sprintf(errorStorageMessage.String(), err)
+	default:
+		t.status = "error: " + err.Error()
+	}
+}
+
+// draw перерисовывает экран целиком
+func (t *tui) draw(out io.Writer) {
+
+	var screen bytes.Buffer
+	screen.WriteString(escHome)
+
+	line := func(style, text string) {
+		screen.WriteString(style + fitWidth(text, t.width) + escReset + escClearLine + "\r\n")
+	}
+
+	title := fmt.Sprintf("TO DO List - %s - %d tasks", t.list, len(t.tasks))
+	if len(t.query) > 0 || t.mode == tuiSearch {
+		title += fmt.Sprintf(" matching %q", string(t.query))
+	}
+	line(escBold, title)
+
+	var header bytes.Buffer
+	printHeader(&header)
+	line(escBold, strings.TrimRight(header.String(), "\n"))
+
+	// список прокручивается так, чтобы выбранная задача была видна
+	rows := t.listLines()
+	if t.cursor < t.top {
+		t.top = t.cursor
+	}
+	if t.cursor >= t.top+rows {
+		t.top = t.cursor - rows + 1
+	}
+	for i := t.top; i < t.top+rows; i++ {
+		if i >= len(t.tasks) {
+			line("", "")
+			continue
+		}
+		var row bytes.Buffer
+		printRow(&row, t.tasks[i], formatDue(t.tasks[i], t.loc), taskStatus(t.tasks[i]), 0, t.loc, time.Time{})
+		style := ""
+		if i == t.cursor {
+			style = escReverse
+		}
+		line(style, strings.TrimRight(row.String(), "\n"))
+	}
+
+	line("", strings.Repeat("─", t.width))
+	details := t.details()
+	for i := 0; i < tuiDetailLines; i++ {
+		text := ""
+		if i < len(details) {
+			text = details[i]
+		}
+		line("", text)
+	}
+
+	// строка состояния: поле ввода, вопрос, сообщение или подсказка (последняя строка без перевода строки,
+	// чтобы экран не прокручивался)
+	var bottom string
+	switch {
+	case t.mode == tuiSearch:
+		bottom = "Search: " + string(t.query) + "_"
+	case t.mode == tuiForm:
+		field := t.form[t.field]
+		bottom = fmt.Sprintf("[%d/%d] %s: %s_", t.field+1, len(t.form), field.label, string(field.value))
+		if t.status != "" {
+			bottom = t.status + " | " + bottom
+		}
+	case t.mode == tuiConfirm:
+		task, _ := t.selected()
+		bottom = fmt.Sprintf("Move task %d to trash? (y/n)", task.id)
+		if task.subtasks > 0 {
+			bottom = fmt.Sprintf("Move task %d to trash with its %d subtasks (y), move the subtasks up a level (m) or cancel (n)?", task.id, task.subtasks)
+		}
+	case t.status != "":
+		bottom = t.status
+	default:
+		bottom = tuiHelp
+	}
+	screen.WriteString(escBold + fitWidth(bottom, t.width) + escReset + escClearLine)
+
+	out.Write(screen.Bytes())
+}
+
+// details возвращает строки панели подробностей о выбранной задаче
+func (t *tui) details() []string {
+
+	task, ok := t.selected()
+	if !ok {
+		return []string{"No tasks. Press a to add one."}
+	}
+
+	status := "open"
+	if task.done {
+		status = "done"
+		if !task.doneAt.IsZero() {
+			status += " at " + task.doneAt.In(t.loc).Format(dateLayout+" "+timeFormat)
+		}
+	}
+	priority := "none"
+	if task.priority != priorityNone {
+		priority = priorityNames[task.priority]
+	}
+
+	lines := []string{
+		fmt.Sprintf("Task %d: %s", task.id, task.content),
+		fmt.Sprintf("Due: %s    Priority: %s    Status: %s", deadlineOf(task).describe(t.loc), priority, status),
+	}
+
+	var extra []string
+	if task.parent != 0 {
+		extra = append(extra, fmt.Sprintf("Subtask of: %d", task.parent))
+	}
+	if progress := formatProgress(task); progress != "" {
+		extra = append(extra, "Subtasks done: "+progress)
+	}
+	if task.recur != "" {
+		extra = append(extra, "Repeat: "+describeRecurrence(task.recur))
+	}
+	if task.project != "" {
+		extra = append(extra, "Project: "+task.project)
+	}
+	if len(task.tags) > 0 {
+		extra = append(extra, "Tags: +"+strings.Join(task.tags, " +"))
+	}
+	if len(extra) > 0 {
+		lines = append(lines, strings.Join(extra, "    "))
+	}
+
+	reminders, err := t.store.Reminders(task.id)
+	if err == nil && len(reminders) > 0 {
+		offsets := make([]string, len(reminders))
+		for i, r := range reminders {
+			offsets[i] = formatOffset(r.offset)
+		}
+		lines = append(lines, "Reminders before due: "+strings.Join(offsets, ", "))
+	}
+
+	return lines
+}
+
+// fitWidth обрезает или дополняет пробелами строку до width символов
+func fitWidth(text string, width int) string {
+
+	runes := []rune(text)
+	if len(runes) > width {
+		return string(runes[:max(0, width-1)]) + "…"
+	}
+
+	return text + strings.Repeat(" ", width-len(runes))
+}
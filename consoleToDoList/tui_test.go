@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTUIFormKeepsPastDate(t *testing.T) {
+
+	store := newMemoryStore()
+	task := Task{content: "report", list: inboxList}
+	dayDeadline(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), time.UTC).apply(&task)
+	id, err := store.Create(task)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	task, _ = store.Get(id)
+
+	ui := &tui{store: store, list: inboxList, loc: time.UTC}
+
+	// прежний срок принимается, хотя он уже прошёл
+	ui.openForm(task)
+	ui.handleForm(tuiKey{r: '!'})
+	for range ui.form {
+		ui.handleForm(tuiKey{name: "enter"})
+	}
+	if ui.mode != tuiBrowse {
+		t.Fatalf("form was not saved: %s", ui.status)
+	}
+	saved, _ := store.Get(id)
+	if saved.content != "report!" || saved.date != task.date || !saved.due.Equal(task.due) {
+		t.Errorf("saved task %+v", saved)
+	}
+
+	// изменённый срок в прошлом отклоняется
+	ui.openForm(saved)
+	ui.field = 1
+	ui.form[1].value = []rune("2000.01.02")
+	ui.handleForm(tuiKey{name: "enter"})
+	if ui.field != 1 || ui.mode != tuiForm {
+		t.Errorf("past date was accepted: %s", ui.status)
+	}
+}